@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/coding-wepack/carctl/pkg/action"
 	"github.com/coding-wepack/carctl/pkg/log"
 	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/signalutil"
 )
 
 func main() {
@@ -19,8 +21,12 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := cmd.Execute(); err != nil {
+	// the first interrupt stops migrating new artifacts, and the second aborts the in-flight ones
+	ctx, stop := signalutil.WithInterrupt(context.Background())
+	defer stop()
+	if err := cmd.ExecuteContext(ctx); err != nil {
 		// debug("%+v", err)
+		stop()
 		os.Exit(1)
 	}
 }
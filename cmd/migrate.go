@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/coding-wepack/carctl/cmd/require"
 	"github.com/coding-wepack/carctl/pkg/action"
+	"github.com/coding-wepack/carctl/pkg/settings"
 )
 
 const migrateHelp = `
@@ -53,7 +57,74 @@ func newMigrateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		newMigrateNpmCmd(cfg, out),
 		newMigratePypiCmd(cfg, out),
 		newMigrateComposerCmd(cfg, out),
+		newMigrateApplyCmd(cfg, out),
+		newMigrateBatchCmd(cfg, out),
+		newMigrateVerifyCmd(cfg, out),
 	)
 
 	return cmd
 }
+
+// addMigrateCommonFlags adds the flags shared by all `carctl migrate` subcommands.
+func addMigrateCommonFlags(cmd *cobra.Command) {
+	addMigrateTransferFlags(cmd)
+	addMigrateFilterFlags(cmd)
+}
+
+// addMigrateFilterFlags adds the flags of which artifacts are selected, which are shared by `carctl migrate verify`.
+func addMigrateFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&settings.Include, "include", nil, "e.g., --include='com/example/**' --include='re:^lodash@4'. Only migrate artifacts whose path or coordinate matches a glob, or a regex prefixed by re:, repeatable")
+	cmd.Flags().StringArrayVar(&settings.Exclude, "exclude", nil, "e.g., --exclude='*-SNAPSHOT*'. Do not migrate artifacts whose path or coordinate matches a glob, or a regex prefixed by re:, repeatable")
+	cmd.Flags().StringVar(&settings.ModifiedAfter, "modified-after", "", "e.g., --modified-after=2023-01-02 or --modified-after=30d. Only migrate artifacts modified after the date, RFC3339 time, or the duration ago")
+	cmd.Flags().StringVar(&settings.ModifiedBefore, "modified-before", "", "e.g., --modified-before=2023-01-02T15:04:05Z or --modified-before=720h. Only migrate artifacts modified before the date, RFC3339 time, or the duration ago")
+	cmd.Flags().StringVar(&settings.DownloadedSince, "downloaded-since", "", "e.g., --downloaded-since=90d. Only migrate artifacts downloaded since the date, RFC3339 time, or the duration ago")
+}
+
+// addMigrateTransferFlags adds the flags of how artifacts are transferred, which are shared by `carctl migrate apply`.
+func addMigrateTransferFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&settings.Journal, "journal", "", "e.g., --journal=./maven.journal. File to record the migration progress, a new file in ~/.carctl/journals/ by default")
+	cmd.Flags().StringVar(&settings.Resume, "resume", "", "e.g., --resume=./maven.journal. Continue an interrupted migration from its journal")
+	cmd.Flags().StringVar(&settings.ReportFile, "report-file", "", "e.g., --report-file=report.json. File to write the migration report with per-item results")
+	cmd.Flags().StringVar(&settings.ReportFormat, "report-format", "json", "e.g., --report-format=junit. Format of --report-file, [json,csv,junit]")
+	addMigrateNetworkFlags(cmd)
+}
+
+// addMigrateNetworkFlags adds the flags of verification, retries and limits, which are shared by `carctl sync`.
+func addMigrateNetworkFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&settings.Verify, "verify", false, "verify checksums of the uploaded files with the source and the destination repository, mismatches are failures")
+	cmd.Flags().IntVar(&settings.Retries, "retries", 2, "e.g., --retries=5. Max retries of a failed request on 5xx, 429 or connection errors")
+	cmd.Flags().DurationVar(&settings.RetryBackoff, "retry-backoff", time.Second, "e.g., --retry-backoff=2s. Delay before the first retry, it doubles on every next retry with a jitter, Retry-After is honoured")
+	cmd.Flags().StringVar(&settings.MaxBandwidth, "max-bandwidth", "", "e.g., --max-bandwidth=50MiB/s. Max bandwidth of downloads and uploads shared by all workers, unlimited by default")
+	cmd.Flags().Float64Var(&settings.MaxRPS, "max-rps", 0, "e.g., --max-rps=20. Max requests per second to each host shared by all workers, unlimited by default")
+}
+
+// planFunc writes the plan of a migration to path.
+type planFunc func(ctx context.Context, cfg *action.Configuration, out io.Writer, path string) error
+
+// addMigratePlanCmd adds the `plan` subcommand to a `carctl migrate <type>` command,
+// it must be called after all the flags are added, since they are shared by the subcommand.
+func addMigratePlanCmd(cmd *cobra.Command, cfg *action.Configuration, out io.Writer, plan planFunc) {
+	var planOut string
+	planCmd := &cobra.Command{
+		Use:   "plan",
+		Short: fmt.Sprintf("write the plan of the %s migration without migrating anything.", cmd.Name()),
+		Long: fmt.Sprintf(`
+This command scans the source and the destination repository, and writes every artifact with its action
+(upload, skip-exists or skip-filtered), size and checksums to a plan file. Review the plan, and then
+migrate exactly the artifacts to upload of it by 'carctl migrate apply'.
+
+Examples:
+
+    $ carctl migrate %[1]s plan --out=plan.json --src=... --dst=...
+    $ carctl migrate apply plan.json
+`, cmd.Name()),
+		Args:   require.NoArgs,
+		PreRun: PreRun,
+		RunE: func(c *cobra.Command, args []string) error {
+			return plan(c.Context(), cfg, out, planOut)
+		},
+	}
+	planCmd.Flags().AddFlagSet(cmd.Flags())
+	planCmd.Flags().StringVar(&planOut, "out", "plan.json", "e.g., --out=plan.json. File to write the plan")
+	cmd.AddCommand(planCmd)
+}
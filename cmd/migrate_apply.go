@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coding-wepack/carctl/cmd/require"
+	"github.com/coding-wepack/carctl/pkg/action"
+	"github.com/coding-wepack/carctl/pkg/constants"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	composer "github.com/coding-wepack/carctl/pkg/migrate/composer"
+	"github.com/coding-wepack/carctl/pkg/migrate/docker"
+	"github.com/coding-wepack/carctl/pkg/migrate/generic"
+	"github.com/coding-wepack/carctl/pkg/migrate/maven"
+	"github.com/coding-wepack/carctl/pkg/migrate/npm"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/migrate/pypi"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/pkg/errors"
+)
+
+const migrateApplyHelp = `
+This command migrates exactly the artifacts to upload of a plan written by 'carctl migrate <type> plan',
+the source and the destination repository are the ones of the plan.
+
+Examples:
+
+    # Write the plan, review it, and then apply it:
+    $ carctl migrate maven plan --out=plan.json \
+          --src="http://127.0.0.1:8081/repository/maven-releases/" \
+          --src-username="test" \
+          --src-password="test123" \
+          --dst="https://demo-maven.pkg.coding.net/repository/test-project/dst-repo/"
+    $ carctl migrate apply plan.json --src-username="test" --src-password="test123" -c 4
+`
+
+// migrations are the migrations of the artifact types which support plans.
+var migrations = map[string]func() *pipeline.Migration{
+	constants.TypeMaven:    maven.NewMigration,
+	constants.TypeNpm:      npm.NewMigration,
+	constants.TypePypi:     pypi.NewMigration,
+	constants.TypeComposer: composer.NewMigration,
+	constants.TypeGeneric:  generic.NewMigration,
+	constants.TypeDocker:   docker.NewMigration,
+}
+
+func newMigrateApplyCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "apply PLAN",
+		Short:  "migrate the artifacts of a plan to a CODING Artifact Repository.",
+		Long:   migrateApplyHelp,
+		Args:   require.ExactArgs(1),
+		PreRun: PreRun,
+		RunE: func(c *cobra.Command, args []string) error {
+			plan, err := pipeline.ReadPlan(args[0])
+			if err != nil {
+				return err
+			}
+			newMigration, ok := migrations[plan.Type]
+			if !ok {
+				return errors.Errorf("unsupported artifact type %s of the plan", plan.Type)
+			}
+			settings.Src, settings.SrcType, settings.Dst = plan.Src, plan.SrcType, plan.Dst
+			log.Info("Apply the plan", logfields.String("plan", args[0]), logfields.String("type", plan.Type),
+				logfields.String("src", plan.Src), logfields.String("dst", plan.Dst))
+			plan.Render(out)
+			return pipeline.Apply(c.Context(), cfg, out, newMigration(), plan)
+		},
+	}
+
+	cmd.Flags().StringVar(&settings.SrcUsername, "src-username", "", "e.g., --src-username=test")
+	cmd.Flags().StringVar(&settings.SrcPassword, "src-password", "", "e.g., --src-password=test123")
+	cmd.Flags().DurationVar(&settings.Sleep, "sleep", 0, "e.g., --sleep=3s. The default is 0, which means there will be no time to sleep")
+	cmd.Flags().IntVarP(&settings.Concurrency, "concurrency", "c", 1, "e.g., -c=2. Concurrency controls for how many artifacts can be pushed concurrently")
+	cmd.Flags().BoolVar(&settings.FailFast, "failFast", false, "exit directly if there was an error found during migration")
+	cmd.Flags().BoolVar(&settings.DryRun, "dryRun", false, "check need migrate artifacts.")
+
+	addMigrateTransferFlags(cmd)
+
+	return cmd
+}
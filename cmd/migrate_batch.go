@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/coding-wepack/carctl/cmd/require"
+	"github.com/coding-wepack/carctl/pkg/action"
+	"github.com/coding-wepack/carctl/pkg/migrate/batch"
+	"github.com/coding-wepack/carctl/pkg/settings"
+)
+
+const migrateBatchHelp = `
+This command migrates many repositories declared by a manifest file, in sequence or in parallel,
+and writes a combined report of all the migrations.
+
+Every migration of the manifest takes the options of 'carctl migrate <type>', and the source
+credentials are referenced by name, whose values may be environment variables like ${NEXUS_PASSWORD}:
+
+    parallel: 2
+    failFast: false
+    report:
+      file: batch-report.json
+      format: json
+    credentials:
+      nexus:
+        username: admin
+        password: ${NEXUS_PASSWORD}
+    defaults:
+      srcType: nexus
+      credentials: nexus
+      concurrency: 4
+    migrations:
+      - name: maven-releases
+        type: maven
+        src: http://127.0.0.1:8081/repository/maven-releases/
+        dst: https://demo-maven.pkg.coding.net/repository/test-project/maven-releases/
+        exclude: ['*-SNAPSHOT*']
+      - name: npm-hosted
+        type: npm
+        src: http://127.0.0.1:8081/repository/npm-hosted/
+        dst: https://demo-npm.pkg.coding.net/test-project/npm-hosted/
+
+When 'parallel' is greater than 1, every migration runs in a carctl process of its own,
+and its output is written to a log file in ~/.carctl/batch/.
+
+Examples:
+
+    $ carctl migrate batch -f migration.yaml
+`
+
+func newMigrateBatchCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	var (
+		file       string
+		job        string
+		jobReport  string
+		parallel   int
+		failFast   bool
+		reportFile string
+	)
+	cmd := &cobra.Command{
+		Use:    "batch",
+		Short:  "migrate the repositories of a manifest file to CODING Artifact Repositories.",
+		Long:   migrateBatchHelp,
+		Args:   require.NoArgs,
+		PreRun: PreRun,
+		RunE: func(c *cobra.Command, args []string) error {
+			m, err := batch.ReadManifest(file)
+			if err != nil {
+				return err
+			}
+			// a single migration spawned by a parallel batch
+			if job != "" {
+				j := m.Job(job)
+				if j == nil {
+					return errors.Errorf("migration %s is not found in the manifest", job)
+				}
+				return batch.RunJob(c.Context(), cfg, out, m, j, jobReport)
+			}
+
+			if c.Flags().Changed("parallel") {
+				m.Parallel = parallel
+			}
+			if c.Flags().Changed("failFast") {
+				m.FailFast = failFast
+			}
+			if reportFile != "" {
+				m.Report.File = reportFile
+			}
+			return batch.Run(c.Context(), cfg, out, m, spawnBatchJob(file))
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "e.g., -f migration.yaml. The manifest file of the migrations")
+	_ = cmd.MarkFlagRequired("file")
+	cmd.Flags().IntVar(&parallel, "parallel", 1, "e.g., --parallel=4. How many migrations run at the same time, overrides 'parallel' of the manifest")
+	cmd.Flags().BoolVar(&failFast, "failFast", false, "do not start new migrations once a migration failed, overrides 'failFast' of the manifest")
+	cmd.Flags().StringVar(&reportFile, "report-file", "", "e.g., --report-file=report.json. File to write the combined report, overrides 'report.file' of the manifest")
+	cmd.Flags().BoolVar(&settings.DryRun, "dryRun", false, "check need migrate artifacts of every migration.")
+
+	// flags of the processes spawned by a parallel batch
+	cmd.Flags().StringVar(&job, "job", "", "the name of the single migration to run")
+	cmd.Flags().StringVar(&jobReport, "job-report", "", "the file to write the JSON report of the single migration")
+	_ = cmd.Flags().MarkHidden("job")
+	_ = cmd.Flags().MarkHidden("job-report")
+
+	return cmd
+}
+
+// spawnBatchJob returns a batch.SpawnFunc which runs a migration of the manifest file by a new carctl process.
+func spawnBatchJob(file string) batch.SpawnFunc {
+	return func(ctx context.Context, job *batch.Job, reportPath string, out io.Writer) error {
+		executable, err := os.Executable()
+		if err != nil {
+			return errors.Wrap(err, "failed to find the carctl executable")
+		}
+		args := []string{"migrate", "batch", "--file", file, "--job", job.Name, "--job-report", reportPath}
+		if settings.Verbose {
+			args = append(args, "--verbose")
+		}
+		if settings.DryRun {
+			args = append(args, "--dryRun")
+		}
+		// interrupts are delivered to the whole process group, so the process drains by itself
+		cmd := exec.Command(executable, args...)
+		cmd.Stdout, cmd.Stderr = out, out
+		if err = cmd.Run(); err != nil {
+			return errors.Wrapf(err, "migration %s exited", job.Name)
+		}
+		return nil
+	}
+}
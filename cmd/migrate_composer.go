@@ -22,6 +22,14 @@ Examples:
           --src-username="test" \
           --src-password="test123" \
           --dst="https://demo-pypi.pkg.coding.net/test-project/dst-composer-repo/"
+
+    # Migrate with 8 concurrent uploads:
+    $ carctl migrate composer -c 8 \
+          --src-type=nexus \
+          --src="http://127.0.0.1:8081/repository/composer-releases/" \
+          --src-username="test" \
+          --src-password="test123" \
+          --dst="https://demo-pypi.pkg.coding.net/test-project/dst-composer-repo/"
 `
 
 func newMigrateComposerCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
@@ -31,7 +39,7 @@ func newMigrateComposerCmd(cfg *action.Configuration, out io.Writer) *cobra.Comm
 		Long:   migrateComposerHelp,
 		PreRun: PreRun,
 		RunE: func(c *cobra.Command, args []string) error {
-			return composer.Migrate(cfg, out)
+			return composer.Migrate(c.Context(), cfg, out)
 		},
 	}
 
@@ -52,5 +60,9 @@ func newMigrateComposerCmd(cfg *action.Configuration, out io.Writer) *cobra.Comm
 	cmd.Flags().BoolVar(&settings.FailFast, "failFast", false, "exit directly if there was an error found during migration")
 	cmd.Flags().IntVar(&settings.MaxFiles, "max-files", -1, "Maximum number of files to be pushed. Negative number means unlimited.")
 
+	// common flags
+	addMigrateCommonFlags(cmd)
+	addMigratePlanCmd(cmd, cfg, out, composer.Plan)
+
 	return cmd
 }
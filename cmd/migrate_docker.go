@@ -15,8 +15,23 @@ This command migrates docker repository from local or remote to a CODING Artifac
 
 Examples:
 
-    # Migrate local docker repository:
-    $ carctl migrate docker --dst="https://yourteam-docker.pkg.coding.net/repository/project/docker-repo/"
+    # Migrate local image tarballs (docker save) and OCI image layouts:
+    $ carctl migrate docker --src="./images/" --dst="https://yourteam-docker.pkg.coding.net/repository/project/docker-repo/"
+
+    # Migrate remote nexus repository with authentication:
+    $ carctl migrate docker \
+          --src="http://127.0.0.1:8081/repository/docker-hosted/" \
+          --src-username="test" \
+          --src-password="test123" \
+          --dst="https://demo-docker.pkg.coding.net/repository/test-project/dst-repo/"
+
+    # Migrate the images of a Harbor project, or any Registry v2 compatible registry:
+    $ carctl migrate docker \
+          --src="https://harbor.example.com/project/" \
+          --src-type="registry" \
+          --src-username="test" \
+          --src-password="test123" \
+          --dst="https://demo-docker.pkg.coding.net/repository/test-project/dst-repo/"
 
     # Migrate remote jfrog repository with authentication:
     $ carctl migrate docker \
@@ -34,13 +49,13 @@ func newMigrateDockerCmd(cfg *action.Configuration, out io.Writer) *cobra.Comman
 		Long:   migrateDockerHelp,
 		PreRun: PreRun,
 		RunE: func(c *cobra.Command, args []string) error {
-			return docker.Migrate(cfg, out)
+			return docker.Migrate(c.Context(), cfg, out)
 		},
 	}
 
 	// required flags
-	cmd.Flags().StringVar(&settings.Src, "src", "", `e.g., --src="https://demo-docker.pkg.coding.net/repository/test-project/src-repo/"`)
-	cmd.Flags().StringVar(&settings.SrcType, "src-type", "nexus", "e.g., --src-type=jfrog, or --src-type=coding")
+	cmd.Flags().StringVar(&settings.Src, "src", "", `e.g., --src="https://demo-docker.pkg.coding.net/repository/test-project/src-repo/", or a local archive or dir of archives`)
+	cmd.Flags().StringVar(&settings.SrcType, "src-type", "nexus", "e.g., --src-type=jfrog, or --src-type=registry for any Registry v2, e.g. Harbor")
 	cmd.Flags().StringVar(&settings.SrcUsername, "src-username", "", "e.g., --src-username=test")
 	cmd.Flags().StringVar(&settings.SrcPassword, "src-password", "", "e.g., --src-password=test123")
 	cmd.Flags().StringVar(&settings.Dst, "dst", "", `e.g., --dst="https://demo-docker.pkg.coding.net/repository/test-project/dst-repo/"`)
@@ -57,6 +72,15 @@ func newMigrateDockerCmd(cfg *action.Configuration, out io.Writer) *cobra.Comman
 	cmd.Flags().BoolVarP(&settings.Force, "force", "f", false, "whether push is forced. if exists does no push.")
 	cmd.Flags().StringVar(&settings.Prefix, "prefix", "", "e.g., --prefix=dir/. only name that match the prefix are migrated.")
 	cmd.Flags().BoolVar(&settings.DryRun, "dryRun", false, "check need migrate artifacts.")
+	cmd.Flags().StringArrayVar(&settings.TagInclude, "tag-include", nil, "e.g., --tag-include='^v?[0-9]'. Only migrate tags which match a regex, repeatable")
+	cmd.Flags().StringArrayVar(&settings.TagExclude, "tag-exclude", nil, "e.g., --tag-exclude='-SNAPSHOT$'. Do not migrate tags which match a regex, repeatable")
+	cmd.Flags().StringVar(&settings.TagSemver, "tag-semver", "", `e.g., --tag-semver=">=1.0.0 <2 || ^3.1". Migrate tags in the semver range`)
+	cmd.Flags().StringVar(&settings.NameMap, "name-map", "", "e.g., --name-map=names.yaml. The rules file which maps source image names to destination image names")
+	cmd.Flags().IntVar(&settings.KeepLast, "keep-last", 0, "e.g., --keep-last=20. Migrate the newest N tags of each image besides the --tag-semver ones, 0 means all. The tags are ordered by the created time, and by semver (lexicographically if not semver) without it")
+
+	// common flags
+	addMigrateCommonFlags(cmd)
+	addMigratePlanCmd(cmd, cfg, out, docker.Plan)
 
 	// TODO: --max-arts
 	// TODO: --generate-sha1
@@ -34,7 +34,7 @@ func newMigrateGenericCmd(cfg *action.Configuration, out io.Writer) *cobra.Comma
 		Long:   migrateGenericHelp,
 		PreRun: PreRun,
 		RunE: func(c *cobra.Command, args []string) error {
-			return generic.Migrate(cfg, out)
+			return generic.Migrate(c.Context(), cfg, out)
 		},
 	}
 
@@ -57,7 +57,14 @@ func newMigrateGenericCmd(cfg *action.Configuration, out io.Writer) *cobra.Comma
 	cmd.Flags().BoolVarP(&settings.Force, "force", "f", false, "whether push is forced. if exists does no push.")
 	cmd.Flags().StringVar(&settings.Prefix, "prefix", "", "e.g., --prefix=dir/. only name that match the prefix are migrated.")
 	cmd.Flags().BoolVar(&settings.DryRun, "dryRun", false, "check need migrate artifacts.")
-	cmd.Flags().BoolVar(&settings.LargeFileMode, "largeFileMode", false, "The large file migration mode relies on the npm operation coding-generic")
+	cmd.Flags().BoolVar(&settings.LargeFileMode, "largeFileMode", false, "upload all the files in chunks, regardless of --chunk-threshold")
+	cmd.Flags().StringVar(&settings.ChunkThreshold, "chunk-threshold", generic.DefaultChunkThreshold, "e.g., --chunk-threshold=1GiB. Files larger than it are uploaded in chunks, 0 means never unless --largeFileMode")
+	cmd.Flags().StringVar(&settings.ChunkSize, "chunk-size", generic.DefaultChunkSize, "e.g., --chunk-size=16MiB. Size of a chunk of the files uploaded in chunks")
+	cmd.Flags().IntVar(&settings.ChunkConcurrency, "chunk-concurrency", generic.DefaultChunkConcurrency, "e.g., --chunk-concurrency=8. Number of chunks of a file uploaded in parallel")
+
+	// common flags
+	addMigrateCommonFlags(cmd)
+	addMigratePlanCmd(cmd, cfg, out, generic.Plan)
 
 	// TODO: --max-arts
 	// TODO: --generate-sha1
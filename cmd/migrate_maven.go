@@ -33,7 +33,7 @@ func newMigrateMavenCmd(cfg *action.Configuration, out io.Writer) *cobra.Command
 		Long:   migrateMavenHelp,
 		PreRun: PreRun,
 		RunE: func(c *cobra.Command, args []string) error {
-			return maven.Migrate(cfg, out)
+			return maven.Migrate(c.Context(), cfg, out)
 		},
 	}
 
@@ -56,6 +56,10 @@ func newMigrateMavenCmd(cfg *action.Configuration, out io.Writer) *cobra.Command
 	cmd.Flags().BoolVarP(&settings.Force, "force", "f", false, "whether push is forced. if exists does no push.")
 	cmd.Flags().BoolVar(&settings.DryRun, "dryRun", false, "check need migrate artifacts.")
 
+	// common flags
+	addMigrateCommonFlags(cmd)
+	addMigratePlanCmd(cmd, cfg, out, maven.Plan)
+
 	// TODO: --max-arts
 	// TODO: --generate-sha1
 	// TODO: --save=/asdfa
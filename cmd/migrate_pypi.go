@@ -21,6 +21,13 @@ Examples:
           --src-username="test" \
           --src-password="test123" \
           --dst="https://demo-pypi.pkg.coding.net/test-project/dst-pypi-repo"
+
+    # Migrate with 8 concurrent uploads:
+    $ carctl migrate pypi -c 8 \
+          --src="http://127.0.0.1:8081/repository/pypi-releases/" \
+          --src-username="test" \
+          --src-password="test123" \
+          --dst="https://demo-pypi.pkg.coding.net/test-project/dst-pypi-repo"
 `
 
 func newMigratePypiCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
@@ -30,7 +37,7 @@ func newMigratePypiCmd(cfg *action.Configuration, out io.Writer) *cobra.Command
 		Long:   migratePypiHelp,
 		PreRun: PreRun,
 		RunE: func(c *cobra.Command, args []string) error {
-			return pypi.Migrate(cfg, out)
+			return pypi.Migrate(c.Context(), cfg, out)
 		},
 	}
 
@@ -52,5 +59,9 @@ func newMigratePypiCmd(cfg *action.Configuration, out io.Writer) *cobra.Command
 	cmd.Flags().IntVar(&settings.MaxFiles, "max-files", -1, "Maximum number of files to be pushed. Negative number means unlimited.")
 	cmd.Flags().BoolVarP(&settings.Force, "force", "f", false, "whether push is forced. if exists does no push.")
 
+	// common flags
+	addMigrateCommonFlags(cmd)
+	addMigratePlanCmd(cmd, cfg, out, pypi.Plan)
+
 	return cmd
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/coding-wepack/carctl/cmd/require"
+	"github.com/coding-wepack/carctl/pkg/action"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/settings"
+)
+
+const migrateVerifyHelp = `
+This command compares a source repository with a CODING Artifact Repository after a migration,
+and reports the files or artifacts which are missing in the destination, extra in the destination,
+or whose checksums mismatch. Nothing is uploaded, and it exits non-zero if any difference is found.
+
+Maven and generic repositories are compared by files and their checksums, the other types
+are compared by the versions of the packages.
+
+Examples:
+
+    $ carctl migrate verify maven \
+          --src="http://127.0.0.1:8081/repository/maven-releases/" \
+          --src-username="test" \
+          --src-password="test123" \
+          --dst="https://demo-maven.pkg.coding.net/repository/test-project/dst-repo/" \
+          --report-file=verify.xml --report-format=junit
+`
+
+func newMigrateVerifyCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "verify TYPE",
+		Short:  "compare a source repository with a CODING Artifact Repository without migrating anything.",
+		Long:   migrateVerifyHelp,
+		Args:   require.ExactArgs(1),
+		PreRun: PreRun,
+		RunE: func(c *cobra.Command, args []string) error {
+			newMigration, ok := migrations[args[0]]
+			if !ok {
+				return errors.Errorf("unsupported artifact type %s", args[0])
+			}
+			// all the files of the source are compared
+			settings.MaxFiles = -1
+			return pipeline.Compare(c.Context(), cfg, out, newMigration())
+		},
+	}
+
+	cmd.Flags().StringVar(&settings.Src, "src", "", `e.g., --src="https://demo-maven.pkg.coding.net/repository/test-project/src-repo/", or --src="$HOME/.m2/repository"`)
+	cmd.Flags().StringVar(&settings.SrcType, "src-type", "nexus", "e.g., --src-type=nexus, or --src-type=jfrog")
+	cmd.Flags().StringVar(&settings.SrcUsername, "src-username", "", "e.g., --src-username=test")
+	cmd.Flags().StringVar(&settings.SrcPassword, "src-password", "", "e.g., --src-password=test123")
+	cmd.Flags().StringVar(&settings.Dst, "dst", "", `e.g., --dst="https://demo-maven.pkg.coding.net/repository/test-project/dst-repo/"`)
+	_ = cmd.MarkFlagRequired("src")
+	_ = cmd.MarkFlagRequired("dst")
+
+	cmd.Flags().StringVar(&settings.Prefix, "prefix", "", "e.g., --prefix=dir/. only name that match the prefix are compared.")
+	cmd.Flags().StringVar(&settings.ReportFile, "report-file", "", "e.g., --report-file=report.json. File to write the report with the result of every file or artifact")
+	cmd.Flags().StringVar(&settings.ReportFormat, "report-format", "json", "e.g., --report-format=junit. Format of --report-file, [json,csv,junit]")
+	cmd.Flags().IntVar(&settings.Retries, "retries", 2, "e.g., --retries=5. Max retries of a failed request on 5xx, 429 or connection errors")
+	cmd.Flags().DurationVar(&settings.RetryBackoff, "retry-backoff", time.Second, "e.g., --retry-backoff=2s. Delay before the first retry, it doubles on every next retry with a jitter, Retry-After is honoured")
+	cmd.Flags().Float64Var(&settings.MaxRPS, "max-rps", 0, "e.g., --max-rps=20. Max requests per second to each host, unlimited by default")
+	addMigrateFilterFlags(cmd)
+
+	return cmd
+}
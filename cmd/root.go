@@ -20,6 +20,7 @@ Common actions for carctl:
 - carctl logout:     logout from a CODING Artifact Registry
 - carctl repo:       handle and control artifact repository
 - carctl migrate:    migrate artifacts from local or remote to a CODING Artifact Repository
+- carctl sync:       sync new or changed artifacts to a CODING Artifact Repository continuously
 `
 
 func newRootCmd(cfg *action.Configuration, out io.Writer, args []string) (*cobra.Command, error) {
@@ -32,6 +33,8 @@ func newRootCmd(cfg *action.Configuration, out io.Writer, args []string) (*cobra
 
 	cmd.CompletionOptions.DisableDefaultCmd = true
 
+	settings.Version = Version
+
 	cmd.PersistentFlags().BoolVarP(&settings.Verbose, "verbose", "v", false, "Make the operation more talkative")
 
 	// registry client
@@ -48,6 +51,7 @@ func newRootCmd(cfg *action.Configuration, out io.Writer, args []string) (*cobra
 
 	cmd.AddCommand(
 		newMigrateCmd(cfg, out),
+		newSyncCmd(cfg, out),
 		newVersionCmd(),
 		newLoginCmd(cfg, out),
 		newLogoutCmd(cfg, out),
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/coding-wepack/carctl/cmd/require"
+	"github.com/coding-wepack/carctl/pkg/action"
+	"github.com/coding-wepack/carctl/pkg/migrate/syncer"
+	"github.com/coding-wepack/carctl/pkg/settings"
+)
+
+const syncHelp = `
+This command keeps a CODING Artifact Repository in sync with a legacy repository which is still being
+published to, e.g. during a cut-over period. It runs as a long-lived process, and on every interval it
+migrates the artifacts modified after the high-water mark of the previous rounds, with the same exists
+check as 'carctl migrate'.
+
+The high-water mark and the result of every round are persisted to a status file, a restarted process
+continues from it. Press Ctrl-C to stop the sync after the in-flight artifacts are migrated.
+
+The first round migrates the whole repository, or the artifacts modified after --modified-after.
+Artifacts whose modified time is unknown are only migrated by the first round.
+
+Examples:
+
+    $ carctl sync maven --interval=5m \
+          --src="http://127.0.0.1:8081/repository/maven-releases/" \
+          --src-username="test" \
+          --src-password="test123" \
+          --dst="https://demo-maven.pkg.coding.net/repository/test-project/dst-repo/"
+`
+
+func newSyncCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	var opts syncer.Options
+	cmd := &cobra.Command{
+		Use:    "sync TYPE",
+		Short:  "Sync new or changed artifacts to a CODING Artifact Repository continuously.",
+		Long:   syncHelp,
+		Args:   require.ExactArgs(1),
+		PreRun: PreRun,
+		RunE: func(c *cobra.Command, args []string) error {
+			newMigration, ok := migrations[args[0]]
+			if !ok {
+				return errors.Errorf("unsupported artifact type %s", args[0])
+			}
+			if opts.Interval <= 0 {
+				return errors.New("--interval must be positive")
+			}
+			settings.MaxFiles = -1
+			return syncer.Run(c.Context(), cfg, out, newMigration(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&settings.Src, "src", "", `e.g., --src="https://demo-maven.pkg.coding.net/repository/test-project/src-repo/"`)
+	cmd.Flags().StringVar(&settings.SrcType, "src-type", "nexus", "e.g., --src-type=nexus, or --src-type=jfrog")
+	cmd.Flags().StringVar(&settings.SrcUsername, "src-username", "", "e.g., --src-username=test")
+	cmd.Flags().StringVar(&settings.SrcPassword, "src-password", "", "e.g., --src-password=test123")
+	cmd.Flags().StringVar(&settings.Dst, "dst", "", `e.g., --dst="https://demo-maven.pkg.coding.net/repository/test-project/dst-repo/"`)
+	_ = cmd.MarkFlagRequired("src")
+	_ = cmd.MarkFlagRequired("dst")
+
+	cmd.Flags().DurationVar(&opts.Interval, "interval", 5*time.Minute, "e.g., --interval=10m. Time between the start of two sync rounds")
+	cmd.Flags().DurationVar(&opts.Overlap, "overlap", 10*time.Minute, "e.g., --overlap=1h. Artifacts modified this long before the high-water mark are checked again, for the ones indexed late by the source")
+	cmd.Flags().StringVar(&opts.StatusPath, "status-file", "", "e.g., --status-file=./maven.sync.json. File to persist the sync status and the high-water mark, a file in ~/.carctl/sync/ by default")
+	cmd.Flags().BoolVar(&opts.Once, "once", false, "run a single sync round and exit, e.g. from a cron job")
+
+	cmd.Flags().DurationVar(&settings.Sleep, "sleep", 0, "e.g., --sleep=3s. The default is 0, which means there will be no time to sleep")
+	cmd.Flags().IntVarP(&settings.Concurrency, "concurrency", "c", 1, "e.g., -c=2. Concurrency controls for how many artifacts can be pushed concurrently")
+	cmd.Flags().StringVar(&settings.Prefix, "prefix", "", "e.g., --prefix=dir/. only name that match the prefix are synced.")
+	addMigrateFilterFlags(cmd)
+	addMigrateNetworkFlags(cmd)
+
+	return cmd
+}
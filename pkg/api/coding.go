@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -24,7 +25,7 @@ const (
 )
 
 // FindDstExistsArtifacts 查询目标仓库已存在的制品
-func FindDstExistsArtifacts(cfg *config.AuthConfig, dst, artifactType string) (result map[string]bool, err error) {
+func FindDstExistsArtifacts(ctx context.Context, cfg *config.AuthConfig, dst, artifactType string) (result map[string]bool, err error) {
 	// 解析目标 URL，获取域名以及项目名、仓库名
 	openApiUrl, project, repo, err := parseDst(dst, artifactType)
 	if err != nil {
@@ -51,7 +52,7 @@ func FindDstExistsArtifacts(cfg *config.AuthConfig, dst, artifactType string) (r
 	for {
 		// 发起分页请求
 		req.PageNumber = pageNumber
-		err = execute(cfg, openApiUrl, req, resp)
+		err = execute(ctx, cfg, openApiUrl, req, resp)
 		if err != nil {
 			return nil, err
 		}
@@ -75,7 +76,20 @@ func FindDstExistsArtifacts(cfg *config.AuthConfig, dst, artifactType string) (r
 }
 
 // FindDstExistsFiles 查询目标仓库已存在的制品文件
-func FindDstExistsFiles(cfg *config.AuthConfig, dst, artifactType string) (data map[string]bool, err error) {
+func FindDstExistsFiles(ctx context.Context, cfg *config.AuthConfig, dst, artifactType string) (data map[string]bool, err error) {
+	data = make(map[string]bool)
+	err = EachDstFile(ctx, cfg, dst, artifactType, func(f *RepoFile) error {
+		data[f.Path] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// EachDstFile 分页遍历目标仓库的制品文件，不会把全部文件保存在内存中
+func EachDstFile(ctx context.Context, cfg *config.AuthConfig, dst, artifactType string, fn func(f *RepoFile) error) (err error) {
 	// 解析目标 URL，获取域名以及项目名、仓库名
 	openApiUrl, project, repo, err := parseDst(dst, artifactType)
 	if err != nil {
@@ -96,20 +110,18 @@ func FindDstExistsFiles(cfg *config.AuthConfig, dst, artifactType string) (data
 			openApiUrl, cfg.Username, cfg.Password, project, repo)
 	}
 
-	data = make(map[string]bool)
 	resp := &DescribeRepoFileListResp{}
 	continuationToken := ""
 	for {
 		// 发起分页请求
 		req.ContinuationToken = continuationToken
-		err = execute(cfg, openApiUrl, req, resp)
+		err = execute(ctx, cfg, openApiUrl, req, resp)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		respRsl := resp.Response
 		if respRsl.Error != nil {
-			err = errors.Errorf("failed to find exists files: %s", respRsl.Error.Code)
-			return
+			return errors.Errorf("failed to find exists files: %s", respRsl.Error.Code)
 		}
 		respData := respRsl.Data
 		if settings.Verbose {
@@ -120,19 +132,21 @@ func FindDstExistsFiles(cfg *config.AuthConfig, dst, artifactType string) (data
 			break
 		}
 		for _, f := range respData.InstanceSet {
-			data[f.Path] = true
+			if err = fn(f); err != nil {
+				return err
+			}
 		}
 		if len(respData.ContinuationToken) == 0 {
 			break
 		}
 		continuationToken = respData.ContinuationToken
 	}
-	return data, nil
+	return nil
 }
 
 // AddProperties 用于给制品增加标签
 func AddProperties(
-	cfg *config.AuthConfig, dst, artifactType, pkg, version, propName, propValue string,
+	ctx context.Context, cfg *config.AuthConfig, dst, artifactType, pkg, version, propName, propValue string,
 ) (err error) {
 	// 解析目标 URL，获取域名以及项目名、仓库名
 	openApiUrl, project, repo, err := parseDst(dst, artifactType)
@@ -156,7 +170,7 @@ func AddProperties(
 	}
 
 	resp := &CreateArtPropertiesResp{}
-	err = execute(cfg, openApiUrl, req, resp)
+	err = execute(ctx, cfg, openApiUrl, req, resp)
 	if err != nil {
 		return err
 	}
@@ -167,14 +181,14 @@ func AddProperties(
 	return
 }
 
-func execute[T any, R any](cfg *config.AuthConfig, url string, req T, resp R) (err error) {
+func execute[T any, R any](ctx context.Context, cfg *config.AuthConfig, url string, req T, resp R) (err error) {
 	marshal, err := json.Marshal(req)
 	if err != nil {
 		err = errors.Wrapf(err, "failed to marshal describe team artifacts reqeust")
 		return
 	}
 
-	openApiResp, err := httputil.DefaultClient.PostJson(url, bytes.NewReader(marshal), cfg.Username, cfg.Password)
+	openApiResp, err := httputil.DefaultClient.PostJson(ctx, url, bytes.NewReader(marshal), cfg.Username, cfg.Password)
 	if err != nil {
 		err = errors.Wrapf(err, "failed to describe team artifacts")
 		return
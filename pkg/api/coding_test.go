@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"testing"
 
 	"github.com/coding-wepack/carctl/pkg/config"
@@ -18,6 +19,6 @@ func TestAddArtiProperty(t *testing.T) {
 		Username: "docker-1689150199311",
 		Password: "fd88921bec09c1395986f4780985e28e59a54629",
 	}
-	err := AddProperties(cfg, dst, artifactType, pkg, version, propName, propValue)
+	err := AddProperties(context.Background(), cfg, dst, artifactType, pkg, version, propName, propValue)
 	require.NoError(t, err)
 }
@@ -0,0 +1,243 @@
+package batch
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/action"
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/constants"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/migrate/composer"
+	"github.com/coding-wepack/carctl/pkg/migrate/docker"
+	"github.com/coding-wepack/carctl/pkg/migrate/generic"
+	"github.com/coding-wepack/carctl/pkg/migrate/journal"
+	"github.com/coding-wepack/carctl/pkg/migrate/maven"
+	"github.com/coding-wepack/carctl/pkg/migrate/npm"
+	"github.com/coding-wepack/carctl/pkg/migrate/pypi"
+	reportutil "github.com/coding-wepack/carctl/pkg/report"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/signalutil"
+	"github.com/pkg/errors"
+)
+
+// MigrateFunc migrates the repository of settings.Src to settings.Dst.
+type MigrateFunc func(ctx context.Context, cfg *action.Configuration, out io.Writer) error
+
+// migrateFuncs are the migrations keyed by artifact type.
+var migrateFuncs = map[string]MigrateFunc{
+	constants.TypeMaven:    maven.Migrate,
+	constants.TypeNpm:      npm.Migrate,
+	constants.TypePypi:     pypi.Migrate,
+	constants.TypeComposer: composer.Migrate,
+	constants.TypeGeneric:  generic.Migrate,
+	constants.TypeDocker:   docker.Migrate,
+}
+
+// SpawnFunc runs the job in a new process, which writes its JSON report to reportPath
+// and its output to out. The migrations of a batch run in processes of their own when
+// they run in parallel, since the options of a migration are process wide settings.
+type SpawnFunc func(ctx context.Context, job *Job, reportPath string, out io.Writer) error
+
+// Run runs the migrations of the manifest, and writes the combined report.
+// The migrations run in this process one by one, or by spawn if m.Parallel > 1.
+// An error is returned if any migration failed.
+// Cancel the signalutil.Drain context of ctx to stop starting new migrations.
+func Run(ctx context.Context, cfg *action.Configuration, out io.Writer, m *Manifest, spawn SpawnFunc) error {
+	reportDir, err := os.MkdirTemp("", "carctl-batch-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create report dir")
+	}
+	defer func() { _ = os.RemoveAll(reportDir) }()
+
+	var logDir string
+	if m.Parallel > 1 {
+		logDir = filepath.Join(config.Dir(), "batch", time.Now().Format("20060102150405"))
+		if err = os.MkdirAll(logDir, 0755); err != nil {
+			return errors.Wrap(err, "failed to create log dir")
+		}
+		log.Info("Run migrations in parallel, the output of every migration is written to its log",
+			logfields.Int("parallel", m.Parallel), logfields.String("logs", logDir))
+	}
+
+	b := &reportutil.Batch{
+		StartTime:  time.Now(),
+		Version:    settings.Version,
+		Migrations: make([]*reportutil.BatchMigration, len(m.Migrations)),
+	}
+	for i, job := range m.Migrations {
+		b.Migrations[i] = &reportutil.BatchMigration{
+			Name:   job.Name,
+			Type:   job.Type,
+			Src:    job.Src,
+			Dst:    job.Dst,
+			Status: reportutil.StatusSkipped,
+		}
+	}
+
+	drain := signalutil.Drain(ctx)
+	var mu sync.Mutex
+	var failed bool
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, m.Parallel)
+	run := func(job *Job, result *reportutil.BatchMigration) {
+		defer func() { <-sem }()
+		runJob(ctx, cfg, out, m, job, result, reportDir, logDir, spawn)
+		if result.Status == reportutil.StatusFailed {
+			mu.Lock()
+			failed = true
+			mu.Unlock()
+		}
+	}
+	for i, job := range m.Migrations {
+		select {
+		case sem <- struct{}{}:
+		case <-drain.Done():
+		}
+		mu.Lock()
+		stop := drain.Err() != nil || (failed && m.FailFast)
+		mu.Unlock()
+		if stop {
+			log.Warn("Stop starting new migrations", logfields.Int("notStarted", len(m.Migrations)-i))
+			break
+		}
+
+		if m.Parallel == 1 {
+			// the migrations in this process share the settings, so they can't overlap
+			run(job, b.Migrations[i])
+			continue
+		}
+		wg.Add(1)
+		go func(job *Job, result *reportutil.BatchMigration) {
+			defer wg.Done()
+			run(job, result)
+		}(job, b.Migrations[i])
+	}
+	wg.Wait()
+	b.EndTime = time.Now()
+
+	log.Info("Batch migration result:")
+	b.Render(out)
+	if m.Report.File != "" && !settings.DryRun {
+		if err = b.WriteFile(m.Report.File, m.Report.Format); err != nil {
+			log.Error("failed to write report", logfields.String("file", m.Report.File), logfields.Error(err))
+			return err
+		}
+	}
+	if n := b.Failed(); n > 0 {
+		return errors.Errorf("%d of %d migrations failed", n, len(b.Migrations))
+	}
+	return nil
+}
+
+// runJob runs the job and records its result.
+func runJob(ctx context.Context, cfg *action.Configuration, out io.Writer, m *Manifest, job *Job,
+	result *reportutil.BatchMigration, reportDir, logDir string, spawn SpawnFunc) {
+	reportPath := filepath.Join(reportDir, job.Name+".json")
+	log.Info("Begin to migrate", logfields.String("migration", job.Name), logfields.String("type", job.Type),
+		logfields.String("src", job.Src), logfields.String("dst", job.Dst))
+	start := time.Now()
+
+	var err error
+	if logDir == "" {
+		err = RunJob(ctx, cfg, out, m, job, reportPath)
+	} else {
+		err = spawnJob(ctx, job, reportPath, filepath.Join(logDir, job.Name+".log"), spawn)
+	}
+
+	result.Status = reportutil.StatusSucceeded
+	if r, rErr := reportutil.ReadFile(reportPath); rErr == nil {
+		result.Report = r
+		if len(r.FailedResult) > 0 {
+			result.Status = reportutil.StatusFailed
+		}
+	} else if !os.IsNotExist(rErr) {
+		log.Warn("failed to read the report of the migration", logfields.String("migration", job.Name), logfields.Error(rErr))
+	}
+	if err != nil {
+		result.Status = reportutil.StatusFailed
+		result.Error = err.Error()
+	}
+
+	fields := []log.Field{
+		logfields.String("migration", job.Name),
+		logfields.String("status", result.Status),
+		logfields.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		log.Warn("Failed to migrate", append(fields, logfields.Error(err))...)
+	} else {
+		log.Info("End to migrate", fields...)
+	}
+}
+
+func spawnJob(ctx context.Context, job *Job, reportPath, logPath string, spawn SpawnFunc) error {
+	if spawn == nil {
+		return errors.New("running migrations in parallel is not supported")
+	}
+	f, err := os.Create(logPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to create log file")
+	}
+	defer f.Close()
+	return spawn(ctx, job, reportPath, f)
+}
+
+// RunJob runs the job of the manifest in this process, the JSON report is written to reportPath if it is not empty.
+// All the options of a migration are set from the job, so the settings of a previous job don't leak.
+func RunJob(ctx context.Context, cfg *action.Configuration, out io.Writer, m *Manifest, job *Job, reportPath string) error {
+	cred := m.Credential(job)
+
+	settings.Src = job.Src
+	settings.SrcType = job.SrcType
+	if settings.SrcType == "" {
+		settings.SrcType = "nexus"
+	}
+	settings.SrcUsername, settings.SrcPassword = cred.Username, cred.Password
+	settings.Dst = job.Dst
+	settings.Concurrency = job.Concurrency
+	if settings.Concurrency < 1 {
+		settings.Concurrency = 1
+	}
+	settings.Sleep = job.Sleep
+	settings.FailFast = job.FailFast != nil && *job.FailFast
+	settings.Force = job.Force != nil && *job.Force
+	settings.MaxFiles = -1
+	if job.MaxFiles != nil {
+		settings.MaxFiles = *job.MaxFiles
+	}
+	settings.Prefix = job.Prefix
+	settings.LargeFileMode = false
+	settings.ChunkThreshold, settings.ChunkSize, settings.ChunkConcurrency = "", "", 0
+	settings.DropInvalidKey = nil
+	settings.Include, settings.Exclude = job.Include, job.Exclude
+	settings.TagInclude, settings.TagExclude, settings.TagSemver, settings.KeepLast = nil, nil, "", 0
+	settings.NameMap = ""
+	settings.ModifiedAfter, settings.ModifiedBefore = job.ModifiedAfter, job.ModifiedBefore
+	settings.DownloadedSince = job.DownloadedSince
+	settings.Verify = job.Verify != nil && *job.Verify
+	settings.Retries = 2
+	if job.Retries != nil {
+		settings.Retries = *job.Retries
+	}
+	settings.RetryBackoff = job.RetryBackoff
+	if settings.RetryBackoff == 0 {
+		settings.RetryBackoff = time.Second
+	}
+	settings.MaxBandwidth = job.MaxBandwidth
+	settings.MaxRPS = job.MaxRPS
+	// the journals of the migrations of a type are told apart by the names
+	settings.Journal = job.Journal
+	if settings.Journal == "" {
+		settings.Journal = journal.DefaultPath(job.Name)
+	}
+	settings.Resume = job.Resume
+	settings.ReportFile, settings.ReportFormat = reportPath, reportutil.FormatJSON
+
+	return migrateFuncs[job.Type](ctx, cfg, out)
+}
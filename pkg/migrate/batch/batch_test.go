@@ -0,0 +1,76 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/action"
+	"github.com/coding-wepack/carctl/pkg/config"
+	reportutil "github.com/coding-wepack/carctl/pkg/report"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMigrate records the settings of every migration, and fails the ones whose src is "fail".
+func fakeMigrate(srcs *[]string) MigrateFunc {
+	return func(ctx context.Context, cfg *action.Configuration, out io.Writer) error {
+		*srcs = append(*srcs, settings.Src+" "+settings.SrcUsername)
+		if settings.Src == "fail" {
+			return errors.New("unauthorized")
+		}
+		r := reportutil.NewReport()
+		r.AddSucceededResultV2(settings.Src, settings.Src, "Succeeded", 1, 1)
+		return r.WriteFile(settings.ReportFile, settings.ReportFormat)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	config.SetDir(dir)
+	var srcs []string
+	saved := migrateFuncs["generic"]
+	migrateFuncs["generic"] = fakeMigrate(&srcs)
+	defer func() { migrateFuncs["generic"] = saved }()
+
+	m, err := ParseManifest([]byte(`
+credentials:
+  raw: {username: admin, password: admin123}
+defaults: {type: generic}
+migrations:
+  - {name: a, src: a, dst: https://demo-generic.pkg.coding.net/p/a/, credentials: raw}
+  - {name: b, src: fail, dst: https://demo-generic.pkg.coding.net/p/b/}
+  - {name: c, src: c, dst: https://demo-generic.pkg.coding.net/p/c/}
+`))
+	require.NoError(t, err)
+	m.Report.File = filepath.Join(dir, "report.json")
+	m.Report.Format = reportutil.FormatJSON
+
+	err = Run(context.Background(), &action.Configuration{}, io.Discard, m, nil)
+	assert.EqualError(t, err, "1 of 3 migrations failed")
+	assert.Equal(t, []string{"a admin", "fail ", "c "}, srcs)
+
+	data, err := os.ReadFile(m.Report.File)
+	require.NoError(t, err)
+	var b reportutil.Batch
+	require.NoError(t, json.Unmarshal(data, &b))
+	require.Len(t, b.Migrations, 3)
+	assert.Equal(t, reportutil.StatusSucceeded, b.Migrations[0].Status)
+	require.NotNil(t, b.Migrations[0].Report)
+	assert.Len(t, b.Migrations[0].Report.SucceededResult, 1)
+	assert.Equal(t, reportutil.StatusFailed, b.Migrations[1].Status)
+	assert.Equal(t, "unauthorized", b.Migrations[1].Error)
+	assert.Nil(t, b.Migrations[1].Report)
+	assert.Equal(t, reportutil.StatusSucceeded, b.Migrations[2].Status)
+
+	// no more migrations are started after a failure with failFast
+	srcs = nil
+	m.FailFast = true
+	assert.Error(t, Run(context.Background(), &action.Configuration{}, io.Discard, m, nil))
+	assert.Equal(t, []string{"a admin", "fail "}, srcs)
+}
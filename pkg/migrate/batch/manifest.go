@@ -0,0 +1,246 @@
+// Package batch runs the migrations of many repositories declared by a manifest file,
+// in sequence or in parallel, and combines their reports.
+//
+// A manifest looks like:
+//
+//	parallel: 2
+//	report:
+//	  file: batch-report.json
+//	credentials:
+//	  nexus:
+//	    username: admin
+//	    password: ${NEXUS_PASSWORD}
+//	defaults:
+//	  srcType: nexus
+//	  credentials: nexus
+//	  concurrency: 4
+//	migrations:
+//	  - name: maven-releases
+//	    type: maven
+//	    src: http://127.0.0.1:8081/repository/maven-releases/
+//	    dst: https://demo-maven.pkg.coding.net/repository/test-project/maven-releases/
+//	    exclude: ['*-SNAPSHOT*']
+package batch
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/constants"
+	reportutil "github.com/coding-wepack/carctl/pkg/report"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest declares the migrations of a batch.
+type Manifest struct {
+	// Parallel is how many migrations run at the same time, 1 by default
+	Parallel int `yaml:"parallel"`
+
+	// FailFast stops starting new migrations once a migration failed
+	FailFast bool `yaml:"failFast"`
+
+	// Report is where the combined report of all the migrations is written
+	Report Report `yaml:"report"`
+
+	// Credentials are the source credentials referenced by name by the migrations
+	Credentials map[string]Credential `yaml:"credentials"`
+
+	// Defaults are the options of every migration which doesn't set them
+	Defaults Job `yaml:"defaults"`
+
+	// Migrations are the src→dst pairs to migrate, in order
+	Migrations []*Job `yaml:"migrations"`
+}
+
+type Report struct {
+	File   string `yaml:"file"`
+	Format string `yaml:"format"`
+}
+
+// Credential is a username and a password of a source, both are expanded
+// with the environment variables, e.g. ${NEXUS_PASSWORD}.
+type Credential struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Job is a single migration of a manifest, the fields are the flags of `carctl migrate <type>`.
+type Job struct {
+	// Name identifies the migration in the logs and the report, the type and the index by default
+	Name string `yaml:"name"`
+
+	Type    string `yaml:"type"`
+	Src     string `yaml:"src"`
+	SrcType string `yaml:"srcType"`
+	Dst     string `yaml:"dst"`
+
+	// Credentials is the name of the credential of the source in Manifest.Credentials
+	Credentials string `yaml:"credentials"`
+
+	Concurrency     int           `yaml:"concurrency"`
+	Sleep           time.Duration `yaml:"sleep"`
+	FailFast        *bool         `yaml:"failFast"`
+	Force           *bool         `yaml:"force"`
+	MaxFiles        *int          `yaml:"maxFiles"`
+	Prefix          string        `yaml:"prefix"`
+	Include         []string      `yaml:"include"`
+	Exclude         []string      `yaml:"exclude"`
+	ModifiedAfter   string        `yaml:"modifiedAfter"`
+	ModifiedBefore  string        `yaml:"modifiedBefore"`
+	DownloadedSince string        `yaml:"downloadedSince"`
+	Verify          *bool         `yaml:"verify"`
+	Retries         *int          `yaml:"retries"`
+	RetryBackoff    time.Duration `yaml:"retryBackoff"`
+	MaxBandwidth    string        `yaml:"maxBandwidth"`
+	MaxRPS          float64       `yaml:"maxRps"`
+	Journal         string        `yaml:"journal"`
+	Resume          string        `yaml:"resume"`
+}
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ReadManifest reads the manifest file, the defaults are merged into every migration.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read manifest")
+	}
+	return ParseManifest(data)
+}
+
+// ParseManifest parses and validates a YAML manifest, JSON is valid YAML as well.
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, errors.Wrap(err, "invalid manifest")
+	}
+	if m.Parallel < 1 {
+		m.Parallel = 1
+	}
+	if m.Report.File != "" {
+		if m.Report.Format == "" {
+			m.Report.Format = reportutil.FormatJSON
+		}
+		if err := reportutil.CheckFormat(m.Report.Format); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.Migrations) == 0 {
+		return nil, errors.New("no migrations in the manifest")
+	}
+
+	names := make(map[string]bool, len(m.Migrations))
+	for i, job := range m.Migrations {
+		if job == nil {
+			return nil, errors.Errorf("migration #%d is empty", i+1)
+		}
+		job.merge(&m.Defaults)
+		if job.Name == "" {
+			job.Name = fmt.Sprintf("%s-%d", job.Type, i+1)
+		}
+		if err := job.validate(m); err != nil {
+			return nil, errors.Wrapf(err, "invalid migration %s", job.Name)
+		}
+		if names[job.Name] {
+			return nil, errors.Errorf("duplicate migration name %s", job.Name)
+		}
+		names[job.Name] = true
+	}
+	return m, nil
+}
+
+// Job returns the migration of the name, nil if not found.
+func (m *Manifest) Job(name string) *Job {
+	for _, job := range m.Migrations {
+		if job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// Credential returns the expanded credential of the job, empty if it references none.
+func (m *Manifest) Credential(job *Job) Credential {
+	if job.Credentials == "" {
+		return Credential{}
+	}
+	cred := m.Credentials[job.Credentials]
+	return Credential{
+		Username: os.ExpandEnv(cred.Username),
+		Password: os.ExpandEnv(cred.Password),
+	}
+}
+
+// merge sets the fields of the job which are not set from the defaults.
+func (j *Job) merge(d *Job) {
+	setString := func(v *string, dv string) {
+		if *v == "" {
+			*v = dv
+		}
+	}
+	setString(&j.Type, d.Type)
+	setString(&j.SrcType, d.SrcType)
+	setString(&j.Credentials, d.Credentials)
+	setString(&j.Prefix, d.Prefix)
+	setString(&j.ModifiedAfter, d.ModifiedAfter)
+	setString(&j.ModifiedBefore, d.ModifiedBefore)
+	setString(&j.DownloadedSince, d.DownloadedSince)
+	setString(&j.MaxBandwidth, d.MaxBandwidth)
+	if j.Concurrency == 0 {
+		j.Concurrency = d.Concurrency
+	}
+	if j.Sleep == 0 {
+		j.Sleep = d.Sleep
+	}
+	if j.FailFast == nil {
+		j.FailFast = d.FailFast
+	}
+	if j.Force == nil {
+		j.Force = d.Force
+	}
+	if j.MaxFiles == nil {
+		j.MaxFiles = d.MaxFiles
+	}
+	if j.Include == nil {
+		j.Include = d.Include
+	}
+	if j.Exclude == nil {
+		j.Exclude = d.Exclude
+	}
+	if j.Verify == nil {
+		j.Verify = d.Verify
+	}
+	if j.Retries == nil {
+		j.Retries = d.Retries
+	}
+	if j.RetryBackoff == 0 {
+		j.RetryBackoff = d.RetryBackoff
+	}
+	if j.MaxRPS == 0 {
+		j.MaxRPS = d.MaxRPS
+	}
+}
+
+func (j *Job) validate(m *Manifest) error {
+	if !nameRegex.MatchString(j.Name) {
+		return errors.Errorf("name must match %s", nameRegex)
+	}
+	if _, ok := migrateFuncs[j.Type]; !ok {
+		return errors.Errorf("unsupported artifact type %q", j.Type)
+	}
+	if j.Src == "" && j.Type != constants.TypeMaven {
+		return errors.New("src must be set")
+	}
+	if j.Dst == "" {
+		return errors.New("dst must be set")
+	}
+	if j.Credentials != "" {
+		if _, ok := m.Credentials[j.Credentials]; !ok {
+			return errors.Errorf("credentials %s are not declared", j.Credentials)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package batch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `
+parallel: 2
+report:
+  file: report.xml
+  format: junit
+credentials:
+  nexus:
+    username: admin
+    password: ${CARCTL_TEST_PASSWORD}
+defaults:
+  srcType: nexus
+  credentials: nexus
+  concurrency: 4
+  exclude: ['*-SNAPSHOT*']
+  retries: 5
+migrations:
+  - name: maven-releases
+    type: maven
+    src: http://127.0.0.1:8081/repository/maven-releases/
+    dst: https://demo-maven.pkg.coding.net/repository/p/maven-releases/
+    sleep: 2s
+  - type: npm
+    src: http://127.0.0.1:8082/artifactory/npm-local/
+    srcType: jfrog
+    dst: https://demo-npm.pkg.coding.net/p/npm/
+    concurrency: 1
+    exclude: []
+    retries: 0
+`
+
+func TestParseManifest(t *testing.T) {
+	t.Setenv("CARCTL_TEST_PASSWORD", "secret")
+
+	m, err := ParseManifest([]byte(testManifest))
+	require.NoError(t, err)
+	assert.Equal(t, 2, m.Parallel)
+	assert.Equal(t, "junit", m.Report.Format)
+	require.Len(t, m.Migrations, 2)
+
+	mvn := m.Job("maven-releases")
+	require.NotNil(t, mvn)
+	assert.Equal(t, "nexus", mvn.SrcType)
+	assert.Equal(t, 4, mvn.Concurrency)
+	assert.Equal(t, 2*time.Second, mvn.Sleep)
+	assert.Equal(t, []string{"*-SNAPSHOT*"}, mvn.Exclude)
+	assert.Equal(t, 5, *mvn.Retries)
+	assert.Equal(t, Credential{Username: "admin", Password: "secret"}, m.Credential(mvn))
+
+	npm := m.Job("npm-2")
+	require.NotNil(t, npm)
+	assert.Equal(t, "jfrog", npm.SrcType)
+	assert.Equal(t, 1, npm.Concurrency)
+	assert.Empty(t, npm.Exclude)
+	assert.Equal(t, 0, *npm.Retries)
+	assert.Nil(t, m.Job("npm-1"))
+}
+
+func TestParseManifestInvalid(t *testing.T) {
+	for name, manifest := range map[string]string{
+		"empty":            `parallel: 2`,
+		"type":             "migrations:\n  - {type: helm, src: a, dst: b}",
+		"dst":              "migrations:\n  - {type: npm, src: a}",
+		"credentials":      "migrations:\n  - {type: npm, src: a, dst: b, credentials: nexus}",
+		"duplicate":        "migrations:\n  - {name: a, type: npm, src: a, dst: b}\n  - {name: a, type: pypi, src: a, dst: b}",
+		"name":             "migrations:\n  - {name: a/b, type: npm, src: a, dst: b}",
+		"report format":    "report: {file: r.xml, format: xml}\nmigrations:\n  - {type: npm, src: a, dst: b}",
+		"invalid duration": "migrations:\n  - {type: npm, src: a, dst: b, sleep: soon}",
+	} {
+		_, err := ParseManifest([]byte(manifest))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,107 @@
+package composer
+
+import (
+	"context"
+	"io"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/migrate/composer/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/composer/types/nexus"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/remote"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/pkg/errors"
+)
+
+type nexusSource struct {
+	c *pipeline.Context
+
+	repository *types.Repository
+}
+
+func newNexusSource(c *pipeline.Context) (pipeline.Source, error) {
+	return &nexusSource{c: c}, nil
+}
+
+func (s *nexusSource) List(ctx context.Context) ([]*pipeline.Item, error) {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	// result contains zip and project.json
+	nexusItemList, err := remote.FindAssetsFromNexus[nexus.Item](ctx, s.c.SrcUrl, s.c.Journal)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get file list")
+	}
+
+	// filter and parse composer list
+	s.repository, err = GetRepositoryFromNexusItems(ctx, settings.Src, nexusItemList)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*pipeline.Item, 0, len(s.repository.Files))
+	for _, f := range s.repository.Files {
+		items = append(items, newItem(f))
+	}
+	return items, nil
+}
+
+// Stream migrates the versions page by page while listing the source repository.
+func (s *nexusSource) Stream(ctx context.Context, fn func(item *pipeline.Item) error) error {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	err := remote.EachAssetsPageFromNexus(ctx, s.c.SrcUrl, s.c.Journal, func(page []nexus.Item) error {
+		repository, err := GetRepositoryFromNexusItems(ctx, settings.Src, page)
+		if err != nil {
+			return err
+		}
+		for _, f := range repository.Files {
+			if err = fn(newItem(f)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "failed to get file list")
+}
+
+func newItem(f *nexus.ComposerItem) *pipeline.Item {
+	return &pipeline.Item{
+		Name:       f.Name,
+		Package:    f.Name,
+		Version:    f.Version,
+		Path:       f.Name,
+		Coordinate: f.Name + ":" + f.Version,
+		Url:        f.Dist.URL,
+		Modified:   f.Time,
+		Checksum: pipeline.Checksum{
+			Sha1: f.Dist.Shasum,
+		},
+	}
+}
+
+func (s *nexusSource) Open(ctx context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	return pipeline.Download(ctx, item.Url)
+}
+
+func (s *nexusSource) Render(w io.Writer) {
+	s.repository.Render(w)
+}
+
+type sink struct {
+	username string
+	password string
+}
+
+func newSink(c *pipeline.Context) (pipeline.Sink, error) {
+	return &sink{username: c.Auth.Username, password: c.Auth.Password}, nil
+}
+
+func (s *sink) Put(ctx context.Context, item *pipeline.Item, body io.Reader) error {
+	pushUrl := getPushUrl(item.Version)
+	resp, err := httputil.DefaultClient.Put(ctx, pushUrl, "", body, s.username, s.password)
+	if err != nil {
+		return errors.Wrapf(err, "failed to push to %s", pushUrl)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	return pipeline.CheckPushResponse(resp)
+}
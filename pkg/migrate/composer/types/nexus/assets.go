@@ -4,11 +4,6 @@ import (
 	"time"
 )
 
-type GetAssetsResponse struct {
-	Items             []Item `json:"items"`
-	ContinuationToken string `json:"continuationToken"`
-}
-
 type Item struct {
 	DownloadURL string `json:"downloadUrl"`
 	Path        string `json:"path"`
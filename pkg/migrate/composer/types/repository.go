@@ -5,11 +5,6 @@ import (
 
 	"github.com/coding-wepack/carctl/pkg/migrate/composer/types/nexus"
 	"github.com/olekukonko/tablewriter"
-	"github.com/pkg/errors"
-)
-
-var (
-	ErrForEachContinue = errors.New("continue")
 )
 
 type (
@@ -24,18 +19,6 @@ type (
 	}
 )
 
-func (r *Repository) ForEach(fn func(path, version, downloadUrl string) error) error {
-	for _, f := range r.Files {
-		if err := fn(f.Name, f.Version, f.Dist.URL); err != nil {
-			if err == ErrForEachContinue {
-				continue
-			}
-			return err
-		}
-	}
-	return nil
-}
-
 func (r *Repository) AddVersionFileList(items []*nexus.ComposerItem) {
 	r.Files = append(r.Files, items...)
 }
@@ -0,0 +1,181 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/migrate/docker/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/oci"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/pkg/errors"
+)
+
+// archiveSep separates the archive and the image in the url of an item of a local source,
+// e.g. `images/app.tar#team/app:1.0`.
+const archiveSep = "#"
+
+// localSource lists the images of `docker save` tarballs and OCI image layouts in --src,
+// which is either an archive or a directory of them.
+type localSource struct {
+	c *pipeline.Context
+
+	repository *types.Repository
+}
+
+func newLocalSource(c *pipeline.Context) (pipeline.Source, error) {
+	if _, err := os.Stat(settings.Src); err != nil {
+		return nil, err
+	}
+	return &localSource{c: c}, nil
+}
+
+func (s *localSource) List(_ context.Context) ([]*pipeline.Item, error) {
+	log.Infof("Scanning image archives in [%s] ...", settings.Src)
+	archives, err := findArchives(settings.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	s.repository = &types.Repository{Path: settings.Src}
+	var images []*types.Image
+	// archivePaths are the archives of the images
+	archivePaths := make(map[*types.Image]string)
+	for _, path := range archives {
+		archive, err := oci.OpenArchive(path)
+		if err != nil {
+			if path == settings.Src {
+				return nil, err
+			}
+			log.Warn("Skip invalid image archive", logfields.String("path", path), logfields.Error(err))
+			continue
+		}
+		info, _ := os.Stat(path)
+		for _, img := range archive.Images() {
+			if len(settings.Prefix) != 0 && !strings.HasPrefix(img.String(), settings.Prefix) {
+				continue
+			}
+			image := &types.Image{
+				SrcPath:    img.String(),
+				PkgName:    strings.ReplaceAll(img.Name, "/", "_"),
+				Version:    img.Tag,
+				SrcPkgName: img.Name,
+				Modified:   info.ModTime(),
+			}
+			image.Tag = image.PkgName + ":" + image.Version
+			images = append(images, image)
+			archivePaths[image] = path
+		}
+		_ = archive.Close()
+	}
+	if err = addImages(s.repository, images, s.c.ExistsArtifacts); err != nil {
+		return nil, err
+	}
+	items := make([]*pipeline.Item, 0, len(s.repository.Images))
+	for _, image := range s.repository.Images {
+		items = append(items, &pipeline.Item{
+			Name:       image.Tag,
+			Package:    image.PkgName,
+			Version:    image.Version,
+			Path:       image.SrcPath,
+			Coordinate: coordinate(image),
+			Url:        archivePaths[image] + archiveSep + image.SrcPath,
+			Modified:   image.Modified,
+			Extra:      image,
+		})
+	}
+	log.Infof("local archive count is:%d, image count is:%d, need migrate count is:%d", len(archives), len(images), s.repository.Count)
+
+	if s.repository.CheckDuplication(s.c.Out) && !settings.Force {
+		log.Warn("Duplicate artifacts exist. Please check the artifacts")
+		return nil, nil
+	}
+	return items, nil
+}
+
+// Open is not supported, images are copied by the sink.
+func (s *localSource) Open(_ context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	return nil, errors.Errorf("unsupported to open docker image %s", item.Name)
+}
+
+func (s *localSource) Render(w io.Writer) {
+	s.repository.Render(w)
+}
+
+// findArchives returns root if it's an archive, or the archives under root.
+func findArchives(root string) ([]string, error) {
+	if oci.IsArchive(root) {
+		return []string{root}, nil
+	}
+	var archives []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !oci.IsArchive(path) {
+			if !info.IsDir() && (strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")) {
+				log.Warn("Skip compressed archive, decompress it to migrate", logfields.String("path", path))
+			}
+			return nil
+		}
+		archives = append(archives, path)
+		if info.IsDir() {
+			// the blobs of an OCI image layout are not archives
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan image archives")
+	}
+	return archives, nil
+}
+
+// archives opens the archives of the items of a local source once.
+type archives struct {
+	mu    sync.Mutex
+	opens map[string]*oci.Archive
+}
+
+// image returns the archive and the image of the url of an item.
+func (a *archives) image(itemUrl string) (*oci.Archive, oci.Reference, error) {
+	i := strings.LastIndex(itemUrl, archiveSep)
+	if i < 0 {
+		return nil, oci.Reference{}, errors.Errorf("invalid image %s of archive", itemUrl)
+	}
+	path, name := itemUrl[:i], itemUrl[i+len(archiveSep):]
+	ref, err := oci.ParseReference("local/" + name)
+	if err != nil {
+		return nil, ref, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	archive, ok := a.opens[path]
+	if !ok {
+		if archive, err = oci.OpenArchive(path); err != nil {
+			return nil, ref, err
+		}
+		if a.opens == nil {
+			a.opens = make(map[string]*oci.Archive)
+		}
+		a.opens[path] = archive
+	}
+	return archive, ref, nil
+}
+
+func (a *archives) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, archive := range a.opens {
+		_ = archive.Close()
+	}
+	a.opens = nil
+	return nil
+}
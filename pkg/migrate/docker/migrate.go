@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -14,226 +15,55 @@ import (
 	"github.com/coding-wepack/carctl/pkg/log"
 	"github.com/coding-wepack/carctl/pkg/log/logfields"
 	"github.com/coding-wepack/carctl/pkg/migrate/docker/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/docker/types/nexus"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
 	"github.com/coding-wepack/carctl/pkg/remote"
-	reportutil "github.com/coding-wepack/carctl/pkg/report"
 	"github.com/coding-wepack/carctl/pkg/settings"
-	"github.com/coding-wepack/carctl/pkg/util/cmdutil"
-	"github.com/coding-wepack/carctl/pkg/util/sliceutil"
-	"github.com/pkg/errors"
-	"github.com/vbauerster/mpb/v7"
-	"github.com/vbauerster/mpb/v7/decor"
 )
 
 var (
-	ErrFileConflict = errors.New("failed to put file: 409 conflict")
+	ErrFileConflict = pipeline.ErrFileConflict
 )
 
-func Migrate(cfg *action.Configuration, out io.Writer) error {
-	log.Info("Check authorization of the registry")
-	configFile, err := cfg.RegistryClient.ConfigFile()
-	if err != nil {
-		return errors.Wrap(err, "failed to get config file")
-	}
-
-	has, authConfig, err := configFile.GetAuthConfig(settings.Dst)
-	if err != nil {
-		return errors.Wrap(err, "failed to get registry authorization info")
-	}
-	if !has {
-		return errors.New("Unauthorized: authentication required. Maybe you haven't logged in before.")
-	}
-
-	if settings.Verbose {
-		log.Debug("Auth config", logfields.String("host", authConfig.ServerAddress),
-			logfields.String("username", authConfig.Username),
-			logfields.String("password", authConfig.Password))
-	}
-
-	// exists artifacts
-	var exists map[string]bool
-	if !settings.Force {
-		exists, err = api.FindDstExistsArtifacts(&authConfig, settings.GetDstWithoutSlash(), constants.TypeDocker)
-		if err != nil {
-			return errors.Wrap(err, "failed to find dst repo exists artifacts")
-		}
-	}
-
-	if isLocalRepository(settings.Src) {
-		// TODO local repository
-		// return MigrateFromDisk(cfg, out)
-		return errors.New("unsupported migrate local docker artifacts")
-	} else {
-		// remote repository
-		srcUrl, err := url.Parse(settings.Src)
-		if err != nil {
-			log.Warn("Invalid src url", logfields.String("src", settings.Src), logfields.Error(err))
-			return errors.Wrap(err, "invalid src url")
-		}
-		if srcUrl != nil && srcUrl.Scheme == "" {
-			srcUrl.Scheme = "http"
-		}
-		return MigrateFromUrl(&authConfig, out, srcUrl, exists)
-	}
-}
-
-func MigrateFromUrl(cfg *config.AuthConfig, out io.Writer, srcUrl *url.URL, exists map[string]bool) error {
-	// 默认为 nexus
-	if settings.SrcType == "" {
-		settings.SrcType = "nexus"
-	}
-	switch settings.SrcType {
-	case "jfrog":
-		return MigrateFromJfrog(cfg, out, srcUrl, exists)
-	default:
-		return errors.Errorf("This src-type [%s] is not supported", settings.SrcType)
+// NewMigration returns the migration of docker repositories.
+func NewMigration() *pipeline.Migration {
+	return &pipeline.Migration{
+		Type: constants.TypeDocker,
+		Sources: map[string]pipeline.SourceFactory{
+			pipeline.SrcTypeJfrog:    newJfrogSource,
+			pipeline.SrcTypeNexus:    newNexusSource,
+			pipeline.SrcTypeRegistry: newRegistrySource,
+			pipeline.SrcTypeLocal:    newLocalSource,
+		},
+		NewSink: newSink,
+		Exists:  exists,
 	}
 }
 
-func MigrateFromJfrog(cfg *config.AuthConfig, out io.Writer, jfrogUrl *url.URL, exists map[string]bool) error {
-	log.Infof("Get file list from source repository [%s] ...", settings.Src)
-	// 获取仓库名称
-	repository := strings.Trim(jfrogUrl.Path, "/")
-	filesInfo, err := remote.FindFileListFromJfrog(jfrogUrl, repository)
-	if err != nil {
-		return errors.Wrap(err, "failed to get file list")
-	}
-
-	if len(settings.Prefix) != 0 {
-		// 过滤匹配 settings.Prefix 的制品
-		totalCount := len(filesInfo.Res)
-		var matchFiles []remote.JfrogFile
-		for _, f := range filesInfo.Res {
-			if strings.HasPrefix(f.GetFilePath(), settings.Prefix) {
-				matchFiles = append(matchFiles, f)
-			}
-		}
-		filesInfo.Res = matchFiles
-		log.Infof("remote repository file count is:%d, match prefix count is:%d", totalCount, len(matchFiles))
-	}
-
-	if err = migrateJfrogRepository(cfg, out, jfrogUrl, filesInfo.Res, cfg, exists); err != nil {
-		return err
-	}
-	return nil
+func Migrate(ctx context.Context, cfg *action.Configuration, out io.Writer) error {
+	return pipeline.Migrate(ctx, cfg, out, NewMigration())
 }
 
-func migrateJfrogRepository(cfg *config.AuthConfig, w io.Writer, jfrogUrl *url.URL, jfrogFileList []remote.JfrogFile, auth *config.AuthConfig, exists map[string]bool) error {
-	log.Info("Scanning jfrog repository ...")
-
-	sliceutil.QuickSortReverse(jfrogFileList, func(f remote.JfrogFile) int64 { return f.Size })
-	repository, err := GetRepositoryFromJfrogFile(jfrogUrl, jfrogFileList, exists)
-	if err != nil {
-		return err
-	}
-	log.Info("Successfully to scan the repository", logfields.Int("images count", repository.Count))
-	if repository.Count == 0 {
-		log.Warn("no files found or files have been migrated, no need to migrate")
-		return nil
-	}
-	if settings.Verbose {
-		log.Info("Repository Info:")
-		repository.Render(w)
-	}
-	duplication := repository.CheckDuplication(w)
-	if !settings.Force && duplication {
-		log.Warn("Duplicate artifacts exist. Please check the artifacts")
-		return nil
-	}
-	if settings.DryRun {
-		return nil
-	}
-
-	// Progress Bar
-	// initialize progress container, with custom width
-	p := mpb.New(mpb.WithWidth(80))
-	const pbName = "Pushing:"
-	// adding a single bar, which will inherit container's width
-	bar := p.Add(
-		int64(repository.Count),
-		mpb.NewBarFiller(mpb.BarStyle()),
-		mpb.PrependDecorators(
-			// display our name with one space on the right
-			decor.Name(pbName, decor.WC{W: len(pbName) + 1, C: decor.DidentRight}),
-			// replace ETA decorator with "done" message, OnComplete event
-			decor.OnComplete(
-				decor.AverageETA(decor.ET_STYLE_GO, decor.WC{W: 4}), "Done!",
-			),
-		),
-		mpb.AppendDecorators(
-			// counter
-			decor.Counters(0, "%d / %d  "),
-			// percentage
-			decor.Percentage(),
-			// average
-			// mpb.AppendDecorators(decor.AverageSpeed(decor.UnitKiB, "  % .1f")),
-		),
-	)
-
-	log.Info("Begin to migrate docker artifacts ...")
-	start := time.Now()
-
-	report := reportutil.NewReport()
-	if settings.Verbose {
-		defer func() {
-			log.Info("Migrate result:")
-			report.Render(w)
-		}()
-	}
-
-	if err = repository.ParallelForEach(func(image *types.Image, path, dst string, isTlsSrc, isTlsDst bool) error {
-		defer bar.Increment()
-		srcTag := fmt.Sprintf("%s/%s", path, image.SrcPath)
-		dstTag := fmt.Sprintf("%s/%s", dst, image.Tag)
-		if err1 := doMigrateJfrogArt(srcTag, dstTag, isTlsSrc, isTlsDst, auth); err1 != nil {
-			if err1 == ErrFileConflict {
-				report.AddSkippedResult(image.SrcPath, srcTag, "409 Conflict")
-				return types.ErrForEachContinue
-			}
-
-			report.AddFailedResult(image.SrcPath, srcTag, err1.Error())
-
-			if settings.FailFast {
-				return errors.Wrapf(err1, "failed to migrate %s", srcTag)
-			}
-		} else {
-			report.AddSucceededResult(image.SrcPath, srcTag, "Succeeded")
-			addProperty(cfg, image)
-		}
-
-		return nil
-	}); err != nil {
-		return err
-	}
-
-	// wait for our bar to complete and flush
-	p.Wait()
-
-	log.Info("End to migrate.",
-		logfields.Duration("duration", time.Now().Sub(start)),
-		logfields.Int("succeededCount", len(report.SucceededResult)),
-		logfields.Int("skippedCount", len(report.SkippedResult)),
-		logfields.Int("failedCount", len(report.FailedResult)))
-
-	return nil
+// Plan writes the plan of the migration to path.
+func Plan(ctx context.Context, cfg *action.Configuration, out io.Writer, path string) error {
+	return pipeline.WritePlan(ctx, cfg, out, NewMigration(), path)
 }
 
-func doMigrateJfrogArt(srcTag, dstTag string, isTlsSrc, isTlsDst bool, auth *config.AuthConfig) error {
-	cmd := fmt.Sprintf("skopeo copy --src-creds='%s:%s' --dest-creds='%s:%s' --src-tls-verify=%t --dest-tls-verify=%t docker://%s docker://%s",
-		settings.SrcUsername, settings.SrcPassword, auth.Username, auth.Password, isTlsSrc, isTlsDst, srcTag, dstTag)
-	output, errOutput, err := cmdutil.Command(cmd)
-	if err != nil {
-		return errors.Wrapf(err, "failed to migrate image from %s to %s, result: %s, err: %s", srcTag, dstTag, output, errOutput)
-	}
-	return nil
+func exists(c *pipeline.Context, item *pipeline.Item) bool {
+	return c.ExistsArtifacts[item.Name]
 }
 
+// GetRepositoryFromJfrogFile returns the tagged images of the files of a JFrog docker repository,
+// a tag is the folder of a `manifest.json`, or a `list.manifest.json` of a multi-arch image.
 func GetRepositoryFromJfrogFile(jfrogUrl *url.URL, jfrogFileList []remote.JfrogFile, exists map[string]bool) (repository *types.Repository, err error) {
 	fileCount := 0
 	isTls := strings.EqualFold(jfrogUrl.Scheme, "https")
 	repositoryUrl := fmt.Sprintf("%s%s", jfrogUrl.Host, jfrogUrl.Path)
 	repository = &types.Repository{IsTls: isTls, Path: repositoryUrl}
+	var images []*types.Image
+	tags := make(map[string]bool)
 	for _, f := range jfrogFileList {
-		if !strings.EqualFold(f.Name, "manifest.json") {
+		if !strings.EqualFold(f.Name, "manifest.json") && !strings.EqualFold(f.Name, "list.manifest.json") {
 			continue
 		}
 		srcPath, srcName, pkg, version, err := f.GetDockerInfo()
@@ -241,28 +71,140 @@ func GetRepositoryFromJfrogFile(jfrogUrl *url.URL, jfrogFileList []remote.JfrogF
 			log.Warnf("failed to gat docker tag from file srcPath: %s", f.Path)
 			continue
 		}
+		if isDigestFolder(version) || tags[srcPath] {
+			// the platform manifests of a multi-arch image are copied with its list
+			continue
+		}
+		tags[srcPath] = true
 		imageTag := &types.Image{
 			SrcPath:    strings.Trim(srcPath, "/"),
 			PkgName:    strings.Trim(pkg, "/"),
 			Version:    strings.Trim(version, "/"),
 			SrcPkgName: strings.Trim(srcName, "/"),
+			Created:    f.Created,
+			Modified:   f.ModifiedTime(),
+			Downloaded: f.DownloadedTime(),
 		}
 		imageTag.Tag = fmt.Sprintf("%s:%s", imageTag.PkgName, imageTag.Version)
 		fileCount++
-		if settings.Force || isNeedMigrate(exists, imageTag) {
-			repository.Images = append(repository.Images, imageTag)
-			repository.Count++
-		}
+		images = append(images, imageTag)
+	}
+	if err = addImages(repository, images, exists); err != nil {
+		return nil, err
 	}
 	log.Infof("remote repository file count is:%d, need migrate count is:%d", fileCount, repository.Count)
 	return
 }
 
-func isLocalRepository(src string) bool {
-	if strings.HasPrefix(src, "http") {
-		return false
+// GetRepositoryFromNexusItems returns the tagged images of the assets of a Nexus docker repository.
+func GetRepositoryFromNexusItems(nexusUrl *url.URL, nexusItemList []nexus.Item, exists map[string]bool) (repository *types.Repository, err error) {
+	fileCount := 0
+	isTls := strings.EqualFold(nexusUrl.Scheme, "https")
+	repositoryUrl := fmt.Sprintf("%s%s", nexusUrl.Host, strings.TrimSuffix(nexusUrl.Path, "/"))
+	repository = &types.Repository{IsTls: isTls, Path: repositoryUrl}
+	var images []*types.Image
+	for _, item := range nexusItemList {
+		srcName, version, ok := nexusManifestTag(item.Path)
+		if !ok {
+			continue
+		}
+		imageTag := &types.Image{
+			SrcPath:    fmt.Sprintf("%s:%s", srcName, version),
+			PkgName:    strings.ReplaceAll(srcName, "/", "_"),
+			Version:    version,
+			SrcPkgName: srcName,
+			Created:    item.BlobCreated,
+			Modified:   item.LastModified,
+			Downloaded: pipeline.NexusDownloaded(item.LastDownloaded),
+		}
+		imageTag.Tag = fmt.Sprintf("%s:%s", imageTag.PkgName, imageTag.Version)
+		fileCount++
+		images = append(images, imageTag)
+	}
+	if err = addImages(repository, images, exists); err != nil {
+		return nil, err
+	}
+	log.Infof("remote repository image count is:%d, need migrate count is:%d", fileCount, repository.Count)
+	return
+}
+
+// GetRepositoryFromRegistryTags returns the tagged images of a registry, names are the image names
+// relative to the path of registryUrl, which is the namespace of the images.
+func GetRepositoryFromRegistryTags(registryUrl *url.URL, names []string, tags map[string][]string, exists map[string]bool) (*types.Repository, error) {
+	imageCount := 0
+	isTls := strings.EqualFold(registryUrl.Scheme, "https")
+	repositoryUrl := fmt.Sprintf("%s%s", registryUrl.Host, strings.TrimSuffix(registryUrl.Path, "/"))
+	repository := &types.Repository{IsTls: isTls, Path: repositoryUrl}
+	var images []*types.Image
+	for _, name := range names {
+		for _, tag := range tags[name] {
+			srcPath := fmt.Sprintf("%s:%s", name, tag)
+			if len(settings.Prefix) != 0 && !strings.HasPrefix(srcPath, settings.Prefix) {
+				continue
+			}
+			imageTag := &types.Image{
+				SrcPath:    srcPath,
+				PkgName:    strings.ReplaceAll(name, "/", "_"),
+				Version:    tag,
+				SrcPkgName: name,
+			}
+			imageTag.Tag = fmt.Sprintf("%s:%s", imageTag.PkgName, imageTag.Version)
+			imageCount++
+			images = append(images, imageTag)
+		}
 	}
-	return true
+	if err := addImages(repository, images, exists); err != nil {
+		return nil, err
+	}
+	log.Infof("remote registry image count is:%d, need migrate count is:%d", imageCount, repository.Count)
+	return repository, nil
+}
+
+// isDigestFolder reports whether the folder of a manifest in JFrog is named by its digest
+// instead of a tag, e.g. `sha256:{hex}` or `sha256__{hex}`.
+func isDigestFolder(version string) bool {
+	return strings.HasPrefix(version, "sha256:") || strings.HasPrefix(version, "sha256__")
+}
+
+// nexusManifestTag returns the image name and the tag of the path `v2/{name}/manifests/{tag}`
+// of a Nexus asset, ok is false for other assets, e.g. blobs and manifests pulled by digest.
+func nexusManifestTag(path string) (name, tag string, ok bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "/"), "v2/")
+	i := strings.LastIndex(path, "/manifests/")
+	if i <= 0 {
+		return "", "", false
+	}
+	name, tag = path[:i], path[i+len("/manifests/"):]
+	if tag == "" || strings.ContainsAny(tag, ":/") {
+		return "", "", false
+	}
+	return name, tag, true
+}
+
+// addImages names the images by the name map, and adds the ones selected by the tag policy which
+// need to be migrated to the repository.
+func addImages(repository *types.Repository, images []*types.Image, exists map[string]bool) error {
+	names, err := loadNameMap()
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if image.PkgName, err = names.Map(image.SrcPkgName); err != nil {
+			return err
+		}
+		image.Tag = fmt.Sprintf("%s:%s", image.PkgName, image.Version)
+	}
+	policy, err := newTagPolicy()
+	if err != nil {
+		return err
+	}
+	for _, image := range policy.apply(images) {
+		if settings.Force || isNeedMigrate(exists, image) {
+			repository.Images = append(repository.Images, image)
+			repository.Count++
+		}
+	}
+	return nil
 }
 
 func isNeedMigrate(exists map[string]bool, imageTag *types.Image) bool {
@@ -272,9 +214,9 @@ func isNeedMigrate(exists map[string]bool, imageTag *types.Image) bool {
 	return !exists[imageTag.Tag]
 }
 
-func addProperty(cfg *config.AuthConfig, image *types.Image) {
+func addProperty(ctx context.Context, cfg *config.AuthConfig, image *types.Image) {
 	time.Sleep(3 * time.Second)
-	err := api.AddProperties(cfg, settings.GetDstWithoutSlash(), constants.TypeDocker, image.PkgName, image.Version,
+	err := api.AddProperties(ctx, cfg, settings.GetDstWithoutSlash(), constants.TypeDocker, image.PkgName, image.Version,
 		"srcName", image.SrcPkgName)
 	info := fmt.Sprintf("add property srcName=%s to %s:%s ", image.SrcPkgName, image.PkgName, image.Version)
 	if err != nil {
@@ -0,0 +1,96 @@
+package docker
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/migrate/docker/types/nexus"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRepositoryFromNexusItems(t *testing.T) {
+	nexusUrl, _ := url.Parse("https://nexus.example.com/repository/docker-hosted/")
+	items := []nexus.Item{
+		{Path: "v2/team/app/manifests/1.0"},
+		{Path: "/v2/team/app/manifests/1.1"},
+		{Path: "v2/team/app/manifests/sha256:abc"},
+		{Path: "v2/-/blobs/sha256:abc"},
+		{Path: "v2/nginx/manifests/latest"},
+	}
+	repository, err := GetRepositoryFromNexusItems(nexusUrl, items, map[string]bool{"team_app:1.1": true})
+	require.NoError(t, err)
+	assert.Equal(t, "nexus.example.com/repository/docker-hosted", repository.Path)
+	assert.True(t, repository.IsTls)
+	require.Len(t, repository.Images, 2)
+	assert.Equal(t, "team/app:1.0", repository.Images[0].SrcPath)
+	assert.Equal(t, "team_app:1.0", repository.Images[0].Tag)
+	assert.Equal(t, "team/app", repository.Images[0].SrcPkgName)
+	assert.Equal(t, "nginx:latest", repository.Images[1].Tag)
+
+	itemUrls := []string{}
+	for _, item := range imageItems(repository) {
+		itemUrls = append(itemUrls, item.Url)
+	}
+	assert.Equal(t, []string{
+		"nexus.example.com/repository/docker-hosted/team/app:1.0",
+		"nexus.example.com/repository/docker-hosted/nginx:latest",
+	}, itemUrls)
+}
+
+func TestGetRepositoryFromRegistryTags(t *testing.T) {
+	registryUrl, _ := url.Parse("http://harbor.example.com/project/")
+	names := []string{"app", "team/tool"}
+	tags := map[string][]string{"app": {"1.0", "1.1"}, "team/tool": {"latest"}}
+	repository, err := GetRepositoryFromRegistryTags(registryUrl, names, tags, map[string]bool{"app:1.1": true})
+	require.NoError(t, err)
+	assert.False(t, repository.IsTls)
+	require.Len(t, repository.Images, 2)
+	assert.Equal(t, "app:1.0", repository.Images[0].Tag)
+	assert.Equal(t, "team_tool:latest", repository.Images[1].Tag)
+	assert.Equal(t, "team/tool", repository.Images[1].SrcPkgName)
+
+	items := imageItems(repository)
+	assert.Equal(t, "harbor.example.com/project/team/tool:latest", items[1].Url)
+}
+
+func TestGetRepositoryFromJfrogFile(t *testing.T) {
+	jfrogUrl, _ := url.Parse("https://jfrog.example.com/docker-local")
+	files := []remote.JfrogFile{
+		{Path: "team/app/1.0", Name: "manifest.json"},
+		{Path: "team/app/1.0", Name: "sha256__abc"},
+		{Path: "team/app/multi", Name: "list.manifest.json"},
+		{Path: "team/app/sha256:def", Name: "manifest.json"},
+		{Path: "team/app/sha256__def", Name: "manifest.json"},
+	}
+	repository, err := GetRepositoryFromJfrogFile(jfrogUrl, files, map[string]bool{})
+	require.NoError(t, err)
+	require.Len(t, repository.Images, 2)
+	assert.Equal(t, "team/app:1.0", repository.Images[0].SrcPath)
+	assert.Equal(t, "team_app:multi", repository.Images[1].Tag)
+}
+
+func TestImageItemsFilter(t *testing.T) {
+	registryUrl, _ := url.Parse("https://harbor.example.com/")
+	names := []string{"team/service/api", "team/service/web", "team/tool"}
+	tags := map[string][]string{"team/service/api": {"1.0"}, "team/service/web": {"2.0"}, "team/tool": {"1.0"}}
+	repository, err := GetRepositoryFromRegistryTags(registryUrl, names, tags, map[string]bool{})
+	require.NoError(t, err)
+	items := imageItems(repository)
+	require.Len(t, items, 3)
+	assert.Equal(t, "team/service/api:1.0", items[0].Coordinate)
+	assert.Equal(t, "team_service_api:1.0", items[0].Name)
+
+	// the filters match the source path of a nested image, not the destination name
+	f, err := pipeline.NewFilter([]string{"team/service/*"}, []string{"*:2.0"})
+	require.NoError(t, err)
+	var selected []string
+	for _, item := range items {
+		if ok, _ := f.Match(item); ok {
+			selected = append(selected, item.Coordinate)
+		}
+	}
+	assert.Equal(t, []string{"team/service/api:1.0"}, selected)
+}
@@ -0,0 +1,132 @@
+package docker
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// imageNameRegex is a valid image name in a CODING docker repository, which has no `/`.
+var imageNameRegex = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+
+// nameMap maps the source image names to the destination image names by the rules of the
+// --name-map file, e.g.
+//
+//	overrides:
+//	  team/service/api: service-api
+//	rules:
+//	  - match: '^team/([^/]+)/([^/]+)$'
+//	    replace: '$1-$2'
+//	  - stripPrefix: library/
+//	  - match: '^ext/'
+//	    replace: ''
+//	    addPrefix: mirror-
+//
+// An override maps a name as is. Otherwise the first rule which applies to the name rewrites it:
+// either the regex match is replaced with capture groups, or the prefix is stripped, then the
+// prefix is added. The `/` left in a mapped name are replaced with `_`, the same as the names
+// without a rule.
+type nameMap struct {
+	Overrides map[string]string `yaml:"overrides"`
+	Rules     []*nameRule       `yaml:"rules"`
+}
+
+// nameRule applies to the names which match Match, or start with StripPrefix.
+type nameRule struct {
+	Match string `yaml:"match"`
+	// Replace is the replacement of the match, the match is kept if it's nil
+	Replace     *string `yaml:"replace"`
+	StripPrefix string  `yaml:"stripPrefix"`
+	AddPrefix   string  `yaml:"addPrefix"`
+
+	re *regexp.Regexp
+}
+
+// loadNameMap reads the --name-map file, nil if it's not set.
+func loadNameMap() (*nameMap, error) {
+	if settings.NameMap == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(settings.NameMap)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read name map")
+	}
+	return parseNameMap(data)
+}
+
+func parseNameMap(data []byte) (*nameMap, error) {
+	m := &nameMap{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, errors.Wrap(err, "invalid name map")
+	}
+	for src, dst := range m.Overrides {
+		if !imageNameRegex.MatchString(dst) {
+			return nil, errors.Errorf("invalid name map, %s of override %s is not a valid image name", dst, src)
+		}
+	}
+	for i, rule := range m.Rules {
+		if rule.Match == "" && rule.StripPrefix == "" && rule.AddPrefix == "" {
+			return nil, errors.Errorf("invalid name map, rule %d is empty", i+1)
+		}
+		if rule.Match == "" && rule.Replace != nil {
+			return nil, errors.Errorf("invalid name map, replace of rule %d needs a match", i+1)
+		}
+		if rule.Match != "" && rule.StripPrefix != "" {
+			return nil, errors.Errorf("invalid name map, rule %d has both match and stripPrefix", i+1)
+		}
+		if rule.Match != "" {
+			re, err := regexp.Compile(rule.Match)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid name map, match of rule %d", i+1)
+			}
+			rule.re = re
+		}
+	}
+	return m, nil
+}
+
+// Map returns the destination image name of the source image name.
+func (m *nameMap) Map(srcName string) (string, error) {
+	if m == nil {
+		return defaultImageName(srcName), nil
+	}
+	if dst, ok := m.Overrides[srcName]; ok {
+		return dst, nil
+	}
+	for _, rule := range m.Rules {
+		if name, ok := rule.apply(srcName); ok {
+			name = defaultImageName(name)
+			if !imageNameRegex.MatchString(name) {
+				return "", errors.Errorf("image %s is mapped to an invalid name %q", srcName, name)
+			}
+			return name, nil
+		}
+	}
+	return defaultImageName(srcName), nil
+}
+
+func (r *nameRule) apply(name string) (string, bool) {
+	switch {
+	case r.re != nil:
+		if !r.re.MatchString(name) {
+			return "", false
+		}
+		if r.Replace != nil {
+			name = r.re.ReplaceAllString(name, *r.Replace)
+		}
+	case !strings.HasPrefix(name, r.StripPrefix):
+		return "", false
+	default:
+		name = strings.TrimPrefix(name, r.StripPrefix)
+	}
+	return r.AddPrefix + name, true
+}
+
+// defaultImageName flattens a nested image name, e.g. `team/app` is `team_app`.
+func defaultImageName(name string) string {
+	return strings.ReplaceAll(strings.Trim(name, "/"), "/", "_")
+}
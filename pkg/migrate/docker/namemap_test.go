@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNameMap = `
+overrides:
+  team/service/api: service-api
+rules:
+  - match: '^team/([^/]+)/([^/]+)$'
+    replace: '$1-$2'
+  - stripPrefix: library/
+  - match: '^ext/'
+    replace: ''
+    addPrefix: mirror-
+`
+
+func TestNameMap_Map(t *testing.T) {
+	m, err := parseNameMap([]byte(testNameMap))
+	require.NoError(t, err)
+	for src, dst := range map[string]string{
+		"team/service/api": "service-api",
+		"team/service/web": "service-web",
+		"library/nginx":    "nginx",
+		"ext/redis":        "mirror-redis",
+		"team/app":         "team_app",
+		"a/b/c/d":          "a_b_c_d",
+	} {
+		name, err := m.Map(src)
+		require.NoError(t, err, src)
+		assert.Equal(t, dst, name, src)
+	}
+
+	m, err = parseNameMap([]byte(`rules: [{match: '^(.*)$', replace: 'UPPER-$1'}]`))
+	require.NoError(t, err)
+	_, err = m.Map("app")
+	assert.Error(t, err, "mapped to an invalid name")
+
+	name, err := (*nameMap)(nil).Map("team/app")
+	require.NoError(t, err)
+	assert.Equal(t, "team_app", name)
+
+	for _, invalid := range []string{
+		`rules: [{}]`,
+		`rules: [{replace: x}]`,
+		`rules: [{match: '(', replace: x}]`,
+		`rules: [{match: '^a', stripPrefix: a}]`,
+		`overrides: {app: 'a/b'}`,
+		`rules: x`,
+	} {
+		_, err = parseNameMap([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestNameMap_Conflicts(t *testing.T) {
+	defer func() { settings.NameMap = "" }()
+	settings.NameMap = filepath.Join(t.TempDir(), "names.yaml")
+	require.NoError(t, os.WriteFile(settings.NameMap, []byte(`rules: [{match: '^(team|group)/', replace: ''}]`), 0644))
+
+	registryUrl, _ := url.Parse("https://registry.example.com")
+	names := []string{"team/app", "group/app", "team/tool"}
+	tags := map[string][]string{"team/app": {"1.0"}, "group/app": {"2.0"}, "team/tool": {"1.0"}}
+	repository, err := GetRepositoryFromRegistryTags(registryUrl, names, tags, map[string]bool{})
+	require.NoError(t, err)
+	assert.Equal(t, "app:1.0", repository.Images[0].Tag)
+	assert.Equal(t, "tool:1.0", repository.Images[2].Tag)
+
+	// the different images named app are conflicts, although the tags are different
+	out := &bytes.Buffer{}
+	assert.True(t, repository.CheckDuplication(out))
+	assert.Contains(t, out.String(), "team/app:1.0")
+	assert.Contains(t, out.String(), "group/app:2.0")
+	assert.NotContains(t, out.String(), "team/tool")
+}
@@ -0,0 +1,78 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/migrate/docker/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/oci"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/pkg/errors"
+)
+
+// registrySource lists the images of a registry by the `_catalog` and `tags/list` of the
+// Registry v2 api, e.g. Harbor, GitLab Container Registry and docker/distribution.
+// The path of --src is the namespace of the migrated repositories, e.g. a Harbor project.
+type registrySource struct {
+	c *pipeline.Context
+
+	registry   *oci.Registry
+	repository *types.Repository
+}
+
+func newRegistrySource(c *pipeline.Context) (pipeline.Source, error) {
+	registry := oci.NewRegistry(c.SrcUrl.Host, !strings.EqualFold(c.SrcUrl.Scheme, "https"),
+		settings.SrcUsername, settings.SrcPassword)
+	return &registrySource{c: c, registry: registry}, nil
+}
+
+func (s *registrySource) List(ctx context.Context) ([]*pipeline.Item, error) {
+	log.Infof("Get image list from source registry [%s] ...", settings.Src)
+	namespace := strings.Trim(s.c.SrcUrl.Path, "/")
+	repositories, err := s.registry.Catalog(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get image list")
+	}
+
+	tags := make(map[string][]string)
+	var names []string
+	for _, repo := range repositories {
+		if namespace != "" && !strings.HasPrefix(repo, namespace+"/") {
+			continue
+		}
+		repoTags, err := s.registry.Tags(ctx, repo)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Warn("Skip the repository whose tags are not listed", logfields.String("repository", repo), logfields.Error(err))
+			continue
+		}
+		name := strings.TrimPrefix(repo, namespace+"/")
+		names = append(names, name)
+		tags[name] = repoTags
+	}
+
+	s.repository, err = GetRepositoryFromRegistryTags(s.c.SrcUrl, names, tags, s.c.ExistsArtifacts)
+	if err != nil {
+		return nil, err
+	}
+	if s.repository.CheckDuplication(s.c.Out) && !settings.Force {
+		log.Warn("Duplicate artifacts exist. Please check the artifacts")
+		return nil, nil
+	}
+	return imageItems(s.repository), nil
+}
+
+// Open is not supported, images are copied by the sink.
+func (s *registrySource) Open(_ context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	return nil, errors.Errorf("unsupported to open docker image %s", item.Name)
+}
+
+func (s *registrySource) Render(w io.Writer) {
+	s.repository.Render(w)
+}
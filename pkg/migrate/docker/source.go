@@ -0,0 +1,244 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/migrate/docker/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/docker/types/nexus"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/oci"
+	"github.com/coding-wepack/carctl/pkg/remote"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/sliceutil"
+	"github.com/pkg/errors"
+)
+
+type jfrogSource struct {
+	c *pipeline.Context
+
+	repository *types.Repository
+}
+
+func newJfrogSource(c *pipeline.Context) (pipeline.Source, error) {
+	return &jfrogSource{c: c}, nil
+}
+
+func (s *jfrogSource) List(ctx context.Context) ([]*pipeline.Item, error) {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	// 获取仓库名称
+	repository := strings.Trim(s.c.SrcUrl.Path, "/")
+	filesInfo, err := remote.FindFileListFromJfrog(ctx, s.c.SrcUrl, repository, s.c.Journal)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get file list")
+	}
+
+	if len(settings.Prefix) != 0 {
+		// 过滤匹配 settings.Prefix 的制品
+		totalCount := len(filesInfo.Res)
+		var matchFiles []remote.JfrogFile
+		for _, f := range filesInfo.Res {
+			if strings.HasPrefix(f.GetFilePath(), settings.Prefix) {
+				matchFiles = append(matchFiles, f)
+			}
+		}
+		filesInfo.Res = matchFiles
+		log.Infof("remote repository file count is:%d, match prefix count is:%d", totalCount, len(matchFiles))
+	}
+
+	sliceutil.QuickSortReverse(filesInfo.Res, func(f remote.JfrogFile) int64 { return f.Size })
+	s.repository, err = GetRepositoryFromJfrogFile(s.c.SrcUrl, filesInfo.Res, s.c.ExistsArtifacts)
+	if err != nil {
+		return nil, err
+	}
+	if s.repository.CheckDuplication(s.c.Out) && !settings.Force {
+		log.Warn("Duplicate artifacts exist. Please check the artifacts")
+		return nil, nil
+	}
+
+	return imageItems(s.repository), nil
+}
+
+// Open is not supported, images are copied by the sink.
+func (s *jfrogSource) Open(_ context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	return nil, errors.Errorf("unsupported to open docker image %s", item.Name)
+}
+
+func (s *jfrogSource) Render(w io.Writer) {
+	s.repository.Render(w)
+}
+
+type nexusSource struct {
+	c *pipeline.Context
+
+	repository *types.Repository
+}
+
+func newNexusSource(c *pipeline.Context) (pipeline.Source, error) {
+	if _, err := remote.GetNexusRepositoryName(c.SrcUrl); err != nil {
+		return nil, err
+	}
+	return &nexusSource{c: c}, nil
+}
+
+func (s *nexusSource) List(ctx context.Context) ([]*pipeline.Item, error) {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	nexusItemList, err := remote.FindAssetsFromNexus[nexus.Item](ctx, s.c.SrcUrl, s.c.Journal)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get file list")
+	}
+
+	if len(settings.Prefix) != 0 {
+		// 过滤匹配 settings.Prefix 的制品
+		totalCount := len(nexusItemList)
+		var matchItems []nexus.Item
+		for _, item := range nexusItemList {
+			if name, tag, ok := nexusManifestTag(item.Path); ok && strings.HasPrefix(name+":"+tag, settings.Prefix) {
+				matchItems = append(matchItems, item)
+			}
+		}
+		nexusItemList = matchItems
+		log.Infof("remote repository file count is:%d, match prefix count is:%d", totalCount, len(matchItems))
+	}
+
+	s.repository, err = GetRepositoryFromNexusItems(s.c.SrcUrl, nexusItemList, s.c.ExistsArtifacts)
+	if err != nil {
+		return nil, err
+	}
+	if s.repository.CheckDuplication(s.c.Out) && !settings.Force {
+		log.Warn("Duplicate artifacts exist. Please check the artifacts")
+		return nil, nil
+	}
+
+	return imageItems(s.repository), nil
+}
+
+// Open is not supported, images are copied by the sink.
+func (s *nexusSource) Open(_ context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	return nil, errors.Errorf("unsupported to open docker image %s", item.Name)
+}
+
+func (s *nexusSource) Render(w io.Writer) {
+	s.repository.Render(w)
+}
+
+// coordinate returns the coordinate of the image matched by the filters, which is the source
+// `repo/path:tag` like the other types, not the mapped destination name.
+func coordinate(image *types.Image) string {
+	return image.SrcPkgName + ":" + image.Version
+}
+
+func imageItems(repository *types.Repository) []*pipeline.Item {
+	srcRepo := strings.Trim(repository.Path, "/")
+	items := make([]*pipeline.Item, 0, len(repository.Images))
+	for _, image := range repository.Images {
+		items = append(items, &pipeline.Item{
+			Name:       image.Tag,
+			Package:    image.PkgName,
+			Version:    image.Version,
+			Path:       image.SrcPath,
+			Coordinate: coordinate(image),
+			Url:        srcRepo + "/" + image.SrcPath,
+			Modified:   image.Modified,
+			Downloaded: image.Downloaded,
+			Extra:      image,
+		})
+	}
+	return items
+}
+
+// itemImage returns the image of an item created by imageItems.
+func itemImage(item *pipeline.Item) *types.Image {
+	srcPkgName := item.Path
+	if i := strings.LastIndex(srcPkgName, ":"); i >= 0 {
+		srcPkgName = srcPkgName[:i]
+	}
+	return &types.Image{
+		SrcPath:    item.Path,
+		PkgName:    item.Package,
+		Version:    item.Version,
+		Tag:        item.Name,
+		SrcPkgName: srcPkgName,
+	}
+}
+
+// sink copies images to the destination registry.
+type sink struct {
+	auth *config.AuthConfig
+
+	// src is the source registry, archives are the source of a local migration
+	src *oci.Registry
+	// srcRepoPrefix is the prefix of the repositories of the item urls which is the
+	// base path of src, e.g. `repository/docker-hosted/` of a Nexus repository
+	srcRepoPrefix string
+	archives      *archives
+	copier        *oci.Copier
+	dstRepo       string
+}
+
+func newSink(c *pipeline.Context) (pipeline.Sink, error) {
+	isTlsDst, dstRepo := types.ParseDstUrl(settings.GetDstWithoutSlash())
+	dstHost := strings.SplitN(dstRepo, "/", 2)[0]
+	dst := oci.NewRegistry(dstHost, !isTlsDst, c.Auth.Username, c.Auth.Password)
+	s := &sink{
+		auth:    c.Auth,
+		copier:  oci.NewCopier(dst, c.Bandwidth),
+		dstRepo: dstRepo,
+	}
+	if c.SrcUrl == nil {
+		s.archives = &archives{}
+	} else {
+		s.src = oci.NewRegistry(c.SrcUrl.Host, !strings.EqualFold(c.SrcUrl.Scheme, "https"), settings.SrcUsername, settings.SrcPassword)
+		if settings.SrcType == "" || settings.SrcType == pipeline.SrcTypeNexus {
+			// the registry api of a Nexus repository is served under the repository url
+			s.src.BasePath = "/" + strings.Trim(c.SrcUrl.Path, "/")
+			s.srcRepoPrefix = strings.Trim(c.SrcUrl.Path, "/") + "/"
+		}
+	}
+	return s, nil
+}
+
+func (s *sink) Put(_ context.Context, item *pipeline.Item, _ io.Reader) error {
+	return errors.Errorf("unsupported to put docker image %s", item.Name)
+}
+
+func (s *sink) Copy(ctx context.Context, item *pipeline.Item) error {
+	image, ok := item.Extra.(*types.Image)
+	if !ok {
+		// the item is read from a plan
+		image = itemImage(item)
+	}
+	var (
+		source oci.Source = s.src
+		srcRef oci.Reference
+		err    error
+	)
+	if s.archives != nil {
+		source, srcRef, err = s.archives.image(item.Url)
+	} else {
+		srcRef, err = oci.ParseReference(item.Url)
+		srcRef.Repository = strings.TrimPrefix(srcRef.Repository, s.srcRepoPrefix)
+	}
+	if err != nil {
+		return err
+	}
+	dstRef, err := oci.ParseReference(s.dstRepo + "/" + image.Tag)
+	if err != nil {
+		return err
+	}
+	if err = s.copier.Copy(ctx, source, srcRef, dstRef); err != nil {
+		return errors.Wrapf(err, "failed to migrate image from %s to %s", item.Url, dstRef)
+	}
+	addProperty(ctx, s.auth, image)
+	return nil
+}
+
+func (s *sink) Close() error {
+	if s.archives != nil {
+		return s.archives.Close()
+	}
+	return nil
+}
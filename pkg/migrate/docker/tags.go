@@ -0,0 +1,164 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/migrate/docker/types"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/semver"
+	"github.com/pkg/errors"
+)
+
+const (
+	policySemver   = "semver"
+	policyKeepLast = "keep-last"
+)
+
+// tagPolicy selects the tags of every image to migrate. The tags are filtered by the include
+// and exclude regexes first, then the ones in the semver range and the newest keepLast ones
+// of the rest are selected. Without a semver range or keepLast, all the filtered tags are selected.
+type tagPolicy struct {
+	includes []*regexp.Regexp
+	excludes []*regexp.Regexp
+	semver   *semver.Constraint
+	keepLast int
+}
+
+// newTagPolicy returns the tag policy of the settings, nil if there is none.
+func newTagPolicy() (*tagPolicy, error) {
+	if len(settings.TagInclude) == 0 && len(settings.TagExclude) == 0 && settings.TagSemver == "" && settings.KeepLast == 0 {
+		return nil, nil
+	}
+	if settings.KeepLast < 0 {
+		return nil, errors.New("--keep-last must not be negative")
+	}
+	p := &tagPolicy{keepLast: settings.KeepLast}
+	var err error
+	if p.includes, err = compileRegexps("--tag-include", settings.TagInclude); err != nil {
+		return nil, err
+	}
+	if p.excludes, err = compileRegexps("--tag-exclude", settings.TagExclude); err != nil {
+		return nil, err
+	}
+	if settings.TagSemver != "" {
+		if p.semver, err = semver.ParseConstraint(settings.TagSemver); err != nil {
+			return nil, errors.Wrap(err, "invalid --tag-semver")
+		}
+	}
+	return p, nil
+}
+
+func compileRegexps(flag string, raws []string) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0, len(raws))
+	for _, raw := range raws {
+		re, err := regexp.Compile(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s %q", flag, raw)
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// apply returns the selected images in order, whose Policy is set by the rule which selects it.
+func (p *tagPolicy) apply(images []*types.Image) []*types.Image {
+	if p == nil {
+		return images
+	}
+	groups := make(map[string][]*types.Image)
+	for _, image := range images {
+		if p.match(image.Version) {
+			groups[image.SrcPkgName] = append(groups[image.SrcPkgName], image)
+		}
+	}
+
+	selected := make(map[*types.Image]bool)
+	for _, group := range groups {
+		var rest []*types.Image
+		for _, image := range group {
+			if p.semver != nil && p.matchSemver(image.Version) {
+				image.Policy = policySemver
+				selected[image] = true
+			} else {
+				rest = append(rest, image)
+			}
+		}
+		switch {
+		case p.keepLast > 0:
+			sort.SliceStable(rest, func(i, j int) bool {
+				ti, tj := createdTime(rest[i]), createdTime(rest[j])
+				if !ti.Equal(tj) {
+					return ti.After(tj)
+				}
+				return compareTags(rest[i].Version, rest[j].Version) > 0
+			})
+			for i := 0; i < len(rest) && i < p.keepLast; i++ {
+				rest[i].Policy = fmt.Sprintf("%s %d/%d", policyKeepLast, i+1, p.keepLast)
+				selected[rest[i]] = true
+			}
+		case p.semver == nil:
+			for _, image := range rest {
+				selected[image] = true
+			}
+		}
+	}
+
+	result := make([]*types.Image, 0, len(selected))
+	for _, image := range images {
+		if selected[image] {
+			result = append(result, image)
+		}
+	}
+	log.Infof("tag policy selects %d of %d tags", len(result), len(images))
+	return result
+}
+
+// match reports whether the tag matches the include and exclude regexes.
+func (p *tagPolicy) match(tag string) bool {
+	for _, re := range p.excludes {
+		if re.MatchString(tag) {
+			return false
+		}
+	}
+	if len(p.includes) == 0 {
+		return true
+	}
+	for _, re := range p.includes {
+		if re.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *tagPolicy) matchSemver(tag string) bool {
+	v, err := semver.Parse(tag)
+	return err == nil && p.semver.Check(v)
+}
+
+// compareTags compares the tags of the images of the same time, e.g. the ones of a registry
+// without the created time. The tags are compared as semantic versions if both are, so that
+// v1.10 is newer than v1.9, otherwise lexicographically.
+func compareTags(a, b string) int {
+	va, errA := semver.Parse(a)
+	vb, errB := semver.Parse(b)
+	if errA == nil && errB == nil {
+		if c := va.Compare(vb); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// createdTime returns the created time of the image, or the modified time if it's unknown.
+func createdTime(image *types.Image) time.Time {
+	if image.Created.IsZero() {
+		return image.Modified
+	}
+	return image.Created
+}
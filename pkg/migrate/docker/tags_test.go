@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/migrate/docker/types"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagPolicy(t *testing.T) {
+	defer func() {
+		settings.TagInclude, settings.TagExclude, settings.TagSemver, settings.KeepLast = nil, nil, "", 0
+	}()
+	now := time.Now()
+	newImages := func() []*types.Image {
+		var images []*types.Image
+		for i, tag := range []string{"1.0.0", "v1.1.0", "2.0.0-rc.1", "build-1", "build-2", "build-3", "main"} {
+			images = append(images, &types.Image{SrcPkgName: "team/app", Version: tag, Created: now.Add(time.Duration(i) * time.Minute)})
+		}
+		images = append(images, &types.Image{SrcPkgName: "other", Version: "build-9", Created: now})
+		return images
+	}
+	tags := func(images []*types.Image) (result []string) {
+		for _, image := range images {
+			result = append(result, image.SrcPkgName+":"+image.Version+" "+image.Policy)
+		}
+		return
+	}
+
+	p, err := newTagPolicy()
+	require.NoError(t, err)
+	assert.Nil(t, p)
+	assert.Len(t, p.apply(newImages()), 8)
+
+	settings.TagSemver, settings.KeepLast = ">=1.0.0", 2
+	settings.TagExclude = []string{"^main$"}
+	p, err = newTagPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"team/app:1.0.0 semver",
+		"team/app:v1.1.0 semver",
+		"team/app:build-2 keep-last 2/2",
+		"team/app:build-3 keep-last 1/2",
+		"other:build-9 keep-last 1/2",
+	}, tags(p.apply(newImages())))
+
+	settings.TagSemver, settings.KeepLast = "", 0
+	settings.TagInclude, settings.TagExclude = []string{"^build-"}, []string{"-2$"}
+	p, err = newTagPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team/app:build-1 ", "team/app:build-3 ", "other:build-9 "}, tags(p.apply(newImages())))
+
+	// the tags without the created time are ordered by semver
+	settings.TagInclude, settings.TagExclude, settings.KeepLast = nil, nil, 2
+	var images []*types.Image
+	for _, tag := range []string{"v1.9", "v1.10", "v1.2", "latest"} {
+		images = append(images, &types.Image{SrcPkgName: "app", Version: tag})
+	}
+	p, err = newTagPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app:v1.9 keep-last 2/2", "app:v1.10 keep-last 1/2"}, tags(p.apply(images)))
+	assert.Equal(t, 1, compareTags("v1.10", "v1.9"))
+	assert.Equal(t, -1, compareTags("build-10", "build-9"))
+
+	settings.TagInclude = []string{"("}
+	_, err = newTagPolicy()
+	assert.Error(t, err)
+	settings.TagInclude, settings.TagSemver = nil, ">=x"
+	_, err = newTagPolicy()
+	assert.Error(t, err)
+}
@@ -0,0 +1,17 @@
+package nexus
+
+import "time"
+
+// Item is an asset of a Nexus docker repository, the path of a tagged manifest is
+// `v2/{name}/manifests/{tag}`.
+type Item struct {
+	DownloadURL    string     `json:"downloadUrl"`
+	Path           string     `json:"path"`
+	ID             string     `json:"id"`
+	Repository     string     `json:"repository"`
+	Format         string     `json:"format"`
+	ContentType    string     `json:"contentType"`
+	LastModified   time.Time  `json:"lastModified"`
+	BlobCreated    time.Time  `json:"blobCreated"`
+	LastDownloaded *time.Time `json:"lastDownloaded"`
+}
@@ -4,20 +4,10 @@ import (
 	"fmt"
 	"io"
 	"strings"
-	"sync"
-	"sync/atomic"
+	"time"
 
 	"github.com/coding-wepack/carctl/pkg/log"
-	"github.com/coding-wepack/carctl/pkg/log/logfields"
-	"github.com/coding-wepack/carctl/pkg/settings"
-	"github.com/coding-wepack/carctl/pkg/util/logutil"
-	"github.com/coding-wepack/carctl/pkg/util/queueutil"
 	"github.com/olekukonko/tablewriter"
-	"github.com/pkg/errors"
-)
-
-var (
-	ErrForEachContinue = errors.New("continue")
 )
 
 type (
@@ -41,28 +31,57 @@ type (
 		Version    string `json:"version,omitempty"`
 		Tag        string `json:"tag,omitempty"`
 		SrcPkgName string `json:"SrcPkgName,omitempty"`
+
+		// Created, Modified and Downloaded are the times of the manifest in the source
+		Created    time.Time  `json:"created,omitempty"`
+		Modified   time.Time  `json:"modified,omitempty"`
+		Downloaded *time.Time `json:"downloaded,omitempty"`
+
+		// Policy is the tag policy which selects the image, e.g. semver or keep-last
+		Policy string `json:"policy,omitempty"`
 	}
 )
 
 func (r *Repository) Render(w io.Writer) {
+	withPolicy := false
+	for _, v := range r.Images {
+		withPolicy = withPolicy || v.Policy != ""
+	}
+	header := []string{"Artifact", "Version", "SrcPath"}
+	footer := []string{"", "Total Images", fmt.Sprintf("%d", r.Count)}
+	if withPolicy {
+		header = append(header, "Policy")
+		footer = append(footer, "")
+	}
 	data := make([][]string, len(r.Images))
 	for i, v := range r.Images {
 		data[i] = []string{v.PkgName, v.Version, v.SrcPath}
+		if withPolicy {
+			data[i] = append(data[i], v.Policy)
+		}
 	}
 
 	table := tablewriter.NewWriter(w)
-	table.SetHeader([]string{"Artifact", "Version", "SrcPath"})
-	table.SetFooter([]string{"", "Total Images", fmt.Sprintf("%d", r.Count)})
+	table.SetHeader(header)
+	table.SetFooter(footer)
 	table.SetAutoMergeCells(true)
 	table.SetRowLine(true)
 	table.AppendBulk(data)
 	table.Render()
 }
 
+// CheckDuplication reports whether some images are named the same in the destination, either the
+// tags of them are the same, or they are different images in the source, and renders them.
 func (r *Repository) CheckDuplication(w io.Writer) bool {
 	var duplication []*Image
 	imageMap := make(map[string]*Image, len(r.Images))
+	nameMap := make(map[string]*Image, len(r.Images))
 	for _, v := range r.Images {
+		if image, ok := nameMap[v.PkgName]; !ok {
+			nameMap[v.PkgName] = v
+		} else if image.SrcPkgName != v.SrcPkgName {
+			duplication = append(duplication, image, v)
+		}
 		image, ok := imageMap[v.Tag]
 		if !ok {
 			imageMap[v.Tag] = v
@@ -92,76 +111,14 @@ func (r *Repository) CheckDuplication(w io.Writer) bool {
 	return true
 }
 
-func (r *Repository) ForEach(fn func(image *Image, srcRepo, dstRepo string, isTlsSrc, isTlsDst bool) error) error {
-	isTlsDst, dstRepo := parseDstUrl(settings.GetDstWithoutSlash())
-	srcRepo := strings.Trim(r.Path, "/")
-	for _, image := range r.Images {
-		if err := fn(image, srcRepo, dstRepo, r.IsTls, isTlsDst); err != nil {
-			if err == ErrForEachContinue {
-				continue
-			}
-			return err
-		}
-	}
-	return nil
-}
-
-func (r *Repository) ParallelForEach(fn func(image *Image, srcRepo, dstRepo string, isTlsSrc, isTlsDst bool) error) error {
-	if settings.Concurrency <= 1 {
-		return r.ForEach(fn)
-	}
-	isTlsDst, dstRepo := parseDstUrl(settings.GetDstWithoutSlash())
-	srcRepo := strings.Trim(r.Path, "/")
-
-	dataChan := make(chan *Image)
-	go queueutil.Producer(r.Images, dataChan)
-
-	if settings.Verbose {
-		log.Debug("parallel foreach do migrate docker artifacts",
-			logfields.Int("file size", r.Count),
-			logfields.Int("concurrency", settings.Concurrency))
-	}
-	var wg sync.WaitGroup
-	var goroutineCount int32 = 0
-	errChan := make(chan error)
-	execJobNum := make([]int32, settings.Concurrency)
-	for i := 0; i < settings.Concurrency; i++ {
-		wg.Add(1)
-		execJobNum[i] = 0
-		go queueutil.Consumer(dataChan, errChan, &wg, &execJobNum[i], func(image *Image) error {
-			atomic.AddInt32(&goroutineCount, 1)
-			err := fn(image, srcRepo, dstRepo, r.IsTls, isTlsDst)
-			atomic.AddInt32(&goroutineCount, -1)
-			if err != nil && err == ErrForEachContinue {
-				return nil
-			}
-			return err
-		})
-	}
-
-	go logutil.WriteGoroutineFile(&goroutineCount, execJobNum)
-
-	go func() {
-		wg.Wait()
-		// 关闭通道，表示所有的 goroutine 已经执行完毕
-		close(errChan)
-	}()
-
-	for err := range errChan {
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func (r *Repository) Tag(fn func(image *Image)) {
 	for _, image := range r.Images {
 		fn(image)
 	}
 }
 
-func parseDstUrl(dstUrl string) (isTls bool, registryUrl string) {
+// ParseDstUrl returns whether the registry is tls and the url without scheme.
+func ParseDstUrl(dstUrl string) (isTls bool, registryUrl string) {
 	isTls = strings.HasPrefix(dstUrl, "https://")
 	if isTls {
 		registryUrl = strings.TrimPrefix(dstUrl, "https://")
@@ -0,0 +1,314 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/coding-wepack/carctl/pkg/util/ratelimit"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultChunkThreshold is the size above which files are uploaded in chunks
+	DefaultChunkThreshold = "100MiB"
+	// DefaultChunkSize is the size of a chunk
+	DefaultChunkSize = "8MiB"
+	// DefaultChunkConcurrency is the number of chunks of a file uploaded in parallel
+	DefaultChunkConcurrency = 4
+
+	chunksDirName = "chunks"
+)
+
+// chunkUploader uploads a file to the `chunks/` endpoint of a CODING generic repository:
+// `part-init` starts an upload, every chunk is uploaded by `part-upload`, and `part-complete`
+// merges them into the file. The chunks are read from the source stream one by one and uploaded
+// in parallel, the uploaded ones are recorded in a state file so that an interrupted upload is resumed.
+type chunkUploader struct {
+	username string
+	password string
+
+	// threshold is the size above which files are uploaded in chunks, 0 means never
+	threshold   int64
+	chunkSize   int64
+	concurrency int
+
+	// stateDir is the dir of the state files of the uploads
+	stateDir string
+}
+
+// uploadState is an upload in progress.
+type uploadState struct {
+	UploadId  string `json:"uploadId"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunkSize"`
+	// Parts are the numbers of the uploaded chunks, starting from 1
+	Parts []int `json:"parts"`
+}
+
+func newChunkUploader(username, password string) (*chunkUploader, error) {
+	u := &chunkUploader{
+		username:    username,
+		password:    password,
+		concurrency: settings.ChunkConcurrency,
+		stateDir:    filepath.Join(config.Dir(), chunksDirName),
+	}
+	var err error
+	if u.threshold, err = parseSize(settings.ChunkThreshold, DefaultChunkThreshold); err != nil {
+		return nil, errors.Wrap(err, "invalid --chunk-threshold")
+	}
+	if u.chunkSize, err = parseSize(settings.ChunkSize, DefaultChunkSize); err != nil {
+		return nil, errors.Wrap(err, "invalid --chunk-size")
+	}
+	if u.chunkSize <= 0 {
+		return nil, errors.New("--chunk-size must be positive")
+	}
+	if u.concurrency < 1 {
+		u.concurrency = DefaultChunkConcurrency
+	}
+	return u, nil
+}
+
+func parseSize(s, defaultSize string) (int64, error) {
+	if s == "" {
+		s = defaultSize
+	}
+	return ratelimit.ParseSize(s)
+}
+
+// accept reports whether the file of size is uploaded in chunks.
+func (u *chunkUploader) accept(size int64) bool {
+	return settings.LargeFileMode || (u.threshold > 0 && size > u.threshold)
+}
+
+// upload uploads the content of size to pushUrl, which is the url of the file under `chunks/`.
+func (u *chunkUploader) upload(ctx context.Context, pushUrl string, size int64, body io.Reader) (err error) {
+	statePath := filepath.Join(u.stateDir, stateName(pushUrl))
+	state := u.loadState(statePath, size)
+	if state == nil {
+		uploadId, err := u.initUpload(ctx, pushUrl, size)
+		if err != nil {
+			return err
+		}
+		state = &uploadState{UploadId: uploadId, Size: size, ChunkSize: u.chunkSize}
+		if err = saveState(statePath, state); err != nil {
+			return err
+		}
+	} else {
+		log.Info("Resume the upload of chunks", logfields.String("url", pushUrl), logfields.Int("uploadedChunks", len(state.Parts)))
+	}
+	defer func() {
+		var statusErr *httputil.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode >= http.StatusBadRequest && statusErr.StatusCode < http.StatusInternalServerError {
+			// the upload is rejected, e.g. expired, the next attempt starts over
+			_ = os.Remove(statePath)
+			if statusErr.StatusCode == http.StatusConflict {
+				err = ErrFileConflict
+			}
+		}
+	}()
+
+	uploaded := make(map[int]bool, len(state.Parts))
+	for _, part := range state.Parts {
+		uploaded[part] = true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, u.concurrency)
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	hash := md5.New()
+	r := io.TeeReader(body, hash)
+	var total int64
+	for part := 1; ctx.Err() == nil; part++ {
+		buf := make([]byte, u.chunkSize)
+		n, rErr := io.ReadFull(r, buf)
+		if rErr != nil && rErr != io.ErrUnexpectedEOF && rErr != io.EOF {
+			fail(errors.Wrap(rErr, "failed to read the source"))
+			break
+		}
+		total += int64(n)
+		if n == 0 {
+			break
+		}
+		if !uploaded[part] {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Add(1)
+			go func(part int, chunk []byte) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if err := u.uploadChunk(ctx, pushUrl, state.UploadId, part, chunk); err != nil {
+					fail(err)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				state.Parts = append(state.Parts, part)
+				if err := saveState(statePath, state); err != nil {
+					log.Warn("failed to save the state of chunks", logfields.Error(err))
+				}
+			}(part, buf[:n])
+		}
+		if rErr != nil {
+			break
+		}
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	if err = u.completeUpload(ctx, pushUrl, state.UploadId, total, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return err
+	}
+	_ = os.Remove(statePath)
+	return nil
+}
+
+func (u *chunkUploader) initUpload(ctx context.Context, pushUrl string, size int64) (string, error) {
+	query := url.Values{"version": {"latest"}, "action": {"part-init"}, "fileSize": {strconv.FormatInt(size, 10)}}
+	resp, err := u.post(ctx, pushUrl, query, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to init the upload of chunks to %s", pushUrl)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+
+	var result struct {
+		Data struct {
+			UploadId string `json:"uploadId"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil || result.Data.UploadId == "" {
+		return "", errors.Errorf("failed to init the upload of chunks to %s, invalid response", pushUrl)
+	}
+	return result.Data.UploadId, nil
+}
+
+// uploadChunk uploads a chunk, which is retried on transient failures unless ctx is a
+// httputil.WithoutRetry one, e.g. a migration retries the item, which resumes the uploaded chunks.
+func (u *chunkUploader) uploadChunk(ctx context.Context, pushUrl, uploadId string, part int, chunk []byte) error {
+	query := url.Values{
+		"version":    {"latest"},
+		"action":     {"part-upload"},
+		"uploadId":   {uploadId},
+		"partNumber": {strconv.Itoa(part)},
+		"size":       {strconv.Itoa(len(chunk))},
+	}
+	err := httputil.DefaultRetryPolicy.Do(ctx, func() error {
+		resp, err := u.post(ctx, pushUrl, query, chunk)
+		if err != nil {
+			return err
+		}
+		ioutils.QuiteClose(resp.Body)
+		return nil
+	}, func(err error, delay time.Duration) {
+		log.Debug("retry the chunk", logfields.Int("part", part), logfields.Duration("delay", delay), logfields.Error(err))
+	})
+	return errors.Wrapf(err, "failed to upload chunk %d to %s", part, pushUrl)
+}
+
+func (u *chunkUploader) completeUpload(ctx context.Context, pushUrl, uploadId string, size int64, md5sum string) error {
+	query := url.Values{
+		"version":  {"latest"},
+		"action":   {"part-complete"},
+		"uploadId": {uploadId},
+		"fileSize": {strconv.FormatInt(size, 10)},
+		"fileTag":  {md5sum},
+	}
+	resp, err := u.post(ctx, pushUrl, query, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to complete the upload of chunks to %s", pushUrl)
+	}
+	ioutils.QuiteClose(resp.Body)
+	return nil
+}
+
+// post sends a request of the chunks api, an unexpected response status is returned as a StatusError.
+func (u *chunkUploader) post(ctx context.Context, pushUrl string, query url.Values, body []byte) (*http.Response, error) {
+	resp, err := httputil.DefaultClient.Post(ctx, pushUrl+"?"+query.Encode(), "application/octet-stream",
+		bytes.NewReader(body), u.username, u.password)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer ioutils.QuiteClose(resp.Body)
+		return nil, httputil.NewStatusError(resp)
+	}
+	return resp, nil
+}
+
+// loadState returns the state of an upload in progress of the file of size, nil if there is none.
+func (u *chunkUploader) loadState(path string, size int64) *uploadState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	state := &uploadState{}
+	if err = json.Unmarshal(data, state); err != nil || state.UploadId == "" ||
+		state.Size != size || state.ChunkSize != u.chunkSize {
+		return nil
+	}
+	sort.Ints(state.Parts)
+	return state
+}
+
+func saveState(path string, state *uploadState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the state dir of chunks")
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err = os.WriteFile(temp, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to save the state of chunks")
+	}
+	return os.Rename(temp, path)
+}
+
+// stateName returns the name of the state file of the upload to pushUrl.
+func stateName(pushUrl string) string {
+	sum := sha1.Sum([]byte(pushUrl))
+	return hex.EncodeToString(sum[:]) + ".json"
+}
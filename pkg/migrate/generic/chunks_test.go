@@ -0,0 +1,104 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChunks is a chunks endpoint which fails the chunk of failPart.
+type fakeChunks struct {
+	mu       sync.Mutex
+	parts    map[int][]byte
+	uploads  map[int]int
+	failPart int
+	file     []byte
+	fileTag  string
+}
+
+func (f *fakeChunks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	query := r.URL.Query()
+	switch query.Get("action") {
+	case "part-init":
+		f.parts = map[int][]byte{}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"uploadId":"u1"}}`))
+	case "part-upload":
+		part, _ := strconv.Atoi(query.Get("partNumber"))
+		if query.Get("uploadId") != "u1" || part == f.failPart {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.parts[part] = data
+		f.uploads[part]++
+	case "part-complete":
+		parts := make([]int, 0, len(f.parts))
+		for part := range f.parts {
+			parts = append(parts, part)
+		}
+		sort.Ints(parts)
+		f.file = nil
+		for _, part := range parts {
+			f.file = append(f.file, f.parts[part]...)
+		}
+		f.fileTag = query.Get("fileTag")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestChunkUploader_Upload(t *testing.T) {
+	defer func(retries int) { httputil.DefaultRetryPolicy.Retries = retries }(httputil.DefaultRetryPolicy.Retries)
+	httputil.DefaultRetryPolicy.Retries = 0
+
+	fake := &fakeChunks{uploads: map[int]int{}, failPart: 3}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	content := make([]byte, 10*1024+100)
+	rand.New(rand.NewSource(1)).Read(content)
+	u := &chunkUploader{chunkSize: 1024, concurrency: 1, stateDir: t.TempDir()}
+	pushUrl := server.URL + "/chunks/dir/large.bin"
+
+	// the upload fails on the third chunk, and resumes from it
+	err := u.upload(context.Background(), pushUrl, int64(len(content)), bytes.NewReader(content))
+	require.Error(t, err)
+	assert.Equal(t, map[int]int{1: 1, 2: 1}, fake.uploads)
+	assert.NotNil(t, u.loadState(u.stateDir+"/"+stateName(pushUrl), int64(len(content))))
+
+	fake.failPart = 0
+	u.concurrency = 4
+	require.NoError(t, u.upload(context.Background(), pushUrl, int64(len(content)), bytes.NewReader(content)))
+	assert.Equal(t, content, fake.file)
+	sum := md5.Sum(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), fake.fileTag)
+	assert.Len(t, fake.uploads, 11)
+	for part, n := range fake.uploads {
+		assert.Equal(t, 1, n, "chunk %d is uploaded once", part)
+	}
+	assert.Nil(t, u.loadState(u.stateDir+"/"+stateName(pushUrl), int64(len(content))), "the state is removed")
+}
+
+func TestChunkUploader_Accept(t *testing.T) {
+	u := &chunkUploader{threshold: 100}
+	assert.False(t, u.accept(100))
+	assert.True(t, u.accept(101))
+
+	u.threshold = 0
+	assert.False(t, u.accept(1<<40))
+}
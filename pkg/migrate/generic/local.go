@@ -0,0 +1,117 @@
+package generic
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/migrate/generic/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/sliceutil"
+	"github.com/pkg/errors"
+)
+
+// diskSource walks a local directory, the path of a file relative to the directory is the
+// path of it in the generic repository.
+type diskSource struct {
+	c *pipeline.Context
+
+	// root is the local directory of --src
+	root       string
+	repository *types.Repository
+}
+
+type localFile struct {
+	path     string
+	modified time.Time
+}
+
+func newDiskSource(c *pipeline.Context) (pipeline.Source, error) {
+	root := localPath(settings.Src)
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New("source repository is not a directory")
+	}
+	return &diskSource{c: c, root: root}, nil
+}
+
+// localPath returns the path of a local --src, which may be a `file://` url or start with `~/`.
+func localPath(src string) string {
+	src = strings.TrimPrefix(src, "file://")
+	if src == "~" || strings.HasPrefix(src, "~/") {
+		src = filepath.Join(config.GetHomeDir(), src[1:])
+	}
+	return filepath.Clean(src)
+}
+
+func (s *diskSource) List(_ context.Context) ([]*pipeline.Item, error) {
+	log.Infof("Scan files of source directory [%s] ...", s.root)
+	var files []*types.File
+	// locals are the local files of the files
+	locals := make(map[*types.File]localFile)
+	err := filepath.Walk(s.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		file := &types.File{FileName: info.Name(), FilePath: filepath.ToSlash(rel), Size: info.Size()}
+		if len(settings.Prefix) != 0 && !strings.HasPrefix(file.FilePath, settings.Prefix) {
+			return nil
+		}
+		files = append(files, file)
+		locals[file] = localFile{path: path, modified: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan source directory")
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("generic directory: %s file not found, please check your directory or command", s.root)
+	}
+
+	sliceutil.QuickSortReverse(files, func(f *types.File) int64 { return f.Size })
+	s.repository = &types.Repository{Path: s.root}
+	for _, f := range files {
+		if settings.Force || isNeedMigrate(f, s.c.ExistsArtifacts) {
+			s.repository.Files = append(s.repository.Files, f)
+			s.repository.Count++
+		}
+	}
+	log.Infof("local directory file count:%d, need migrate count:%d", len(files), s.repository.Count)
+
+	items := make([]*pipeline.Item, 0, len(s.repository.Files))
+	for _, f := range s.repository.Files {
+		items = append(items, &pipeline.Item{
+			Name:     f.FileName,
+			Path:     f.FilePath,
+			DstPath:  f.FilePath,
+			Url:      locals[f].path,
+			Size:     f.Size,
+			Modified: locals[f].modified,
+		})
+	}
+	return items, nil
+}
+
+func (s *diskSource) Open(_ context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	return pipeline.OpenFile(item.Url)
+}
+
+func (s *diskSource) Render(w io.Writer) {
+	s.repository.Render(w)
+}
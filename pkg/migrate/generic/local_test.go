@@ -0,0 +1,64 @@
+package generic
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskSource_List(t *testing.T) {
+	defer func(src, dst, prefix string) { settings.Src, settings.Dst, settings.Prefix = src, dst, prefix }(settings.Src, settings.Dst, settings.Prefix)
+
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"release/app-1.0.tar.gz": "app 1.0",
+		"release/app-1.1.tar.gz": "app 1.1 is larger",
+		"release/docs/readme.md": "docs",
+		"nightly/app.tar.gz":     "nightly",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, path), []byte(content), 0644))
+	}
+	settings.Src = "file://" + root + "/"
+	settings.Dst = "https://team-generic.pkg.coding.net/project/generic-repo/"
+	settings.Prefix = "release/"
+
+	c := &pipeline.Context{ExistsArtifacts: map[string]bool{"release/app-1.0.tar.gz:latest": true}}
+	src, err := newDiskSource(c)
+	require.NoError(t, err)
+	items, err := src.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "release/app-1.1.tar.gz", items[0].Path, "the larger file is the first")
+	assert.Equal(t, "app-1.1.tar.gz", items[0].Name)
+	assert.Equal(t, "release/docs/readme.md", items[1].Path)
+	assert.Equal(t, "https://team-generic.pkg.coding.net/project/generic-repo/release/docs/readme.md", getPushUrl(items[1].Path, false))
+
+	rc, err := src.Open(context.Background(), items[0])
+	require.NoError(t, err)
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	assert.Equal(t, "app 1.1 is larger", string(data))
+
+	settings.Prefix = "missing/"
+	_, err = src.List(context.Background())
+	assert.Error(t, err)
+
+	settings.Src = filepath.Join(root, "release/app-1.0.tar.gz")
+	_, err = newDiskSource(c)
+	assert.Error(t, err, "not a directory")
+}
+
+func TestLocalPath(t *testing.T) {
+	assert.Equal(t, "/data/artifacts", localPath("file:///data/artifacts/"))
+	assert.Equal(t, "artifacts", localPath("./artifacts"))
+	assert.Equal(t, filepath.Join(config.GetHomeDir(), "generic"), localPath("file://~/generic/"))
+}
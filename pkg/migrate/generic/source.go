@@ -0,0 +1,150 @@
+package generic
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/migrate/generic/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/remote"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/coding-wepack/carctl/pkg/util/sliceutil"
+	"github.com/pkg/errors"
+)
+
+type jfrogSource struct {
+	c *pipeline.Context
+
+	repository *types.Repository
+}
+
+func newJfrogSource(c *pipeline.Context) (pipeline.Source, error) {
+	return &jfrogSource{c: c}, nil
+}
+
+func (s *jfrogSource) List(ctx context.Context) ([]*pipeline.Item, error) {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	repository := s.repositoryName()
+	filesInfo, err := remote.FindFileListFromJfrog(ctx, s.c.SrcUrl, repository, s.c.Journal)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get file list")
+	}
+
+	if len(settings.Prefix) != 0 {
+		totalCount := len(filesInfo.Res)
+		// 过滤匹配 settings.Prefix 的制品
+		var matchFiles []remote.JfrogFile
+		for _, f := range filesInfo.Res {
+			if strings.HasPrefix(f.GetFilePath(), settings.Prefix) {
+				matchFiles = append(matchFiles, f)
+			}
+		}
+		filesInfo.Res = matchFiles
+		log.Infof("remote repository file count: %d, match prefix count: %d", totalCount, len(matchFiles))
+	}
+
+	if len(filesInfo.Res) == 0 {
+		return nil, errors.Errorf("generic repository: %s file not found, please check your repository or command", repository)
+	}
+
+	sliceutil.QuickSortReverse(filesInfo.Res, func(f remote.JfrogFile) int64 { return f.Size })
+	s.repository, err = GetRepositoryFromJfrogFile(s.c.SrcUrl, filesInfo.Res, s.c.ExistsArtifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	jfrogFiles := make(map[string]*remote.JfrogFile, len(filesInfo.Res))
+	for i := range filesInfo.Res {
+		jfrogFiles[filesInfo.Res[i].GetFilePath()] = &filesInfo.Res[i]
+	}
+
+	items := make([]*pipeline.Item, 0, len(s.repository.Files))
+	for _, f := range s.repository.Files {
+		items = append(items, newItem(f, jfrogFiles[f.FilePath]))
+	}
+	return items, nil
+}
+
+// Stream migrates the files page by page while listing the source repository,
+// the files are not sorted by size in this mode.
+func (s *jfrogSource) Stream(ctx context.Context, fn func(item *pipeline.Item) error) error {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	err := remote.EachFileListPageFromJfrog(ctx, s.c.SrcUrl, s.repositoryName(), s.c.Journal, func(page []remote.JfrogFile) error {
+		for i := range page {
+			f := &page[i]
+			file := &types.File{FileName: f.Name, FilePath: f.GetFilePath(), Size: f.Size}
+			if len(settings.Prefix) != 0 && !strings.HasPrefix(file.FilePath, settings.Prefix) {
+				continue
+			}
+			if !settings.Force && !isNeedMigrate(file, s.c.ExistsArtifacts) {
+				continue
+			}
+			if err := fn(newItem(file, f)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "failed to get file list")
+}
+
+// repositoryName returns the name of the source repository, which is the second segment of the url path.
+func (s *jfrogSource) repositoryName() string {
+	// 获取仓库名称
+	urlPathStrs := strings.Split(strings.Trim(s.c.SrcUrl.Path, "/"), "/")
+	return urlPathStrs[1]
+}
+
+func newItem(f *types.File, jf *remote.JfrogFile) *pipeline.Item {
+	return &pipeline.Item{
+		Name:       f.FileName,
+		Path:       f.FilePath,
+		DstPath:    f.FilePath,
+		Url:        getDownloadUrl(f.FilePath),
+		Size:       f.Size,
+		Checksum:   pipeline.JfrogChecksum(jf),
+		Modified:   jf.ModifiedTime(),
+		Downloaded: jf.DownloadedTime(),
+	}
+}
+
+func (s *jfrogSource) Open(ctx context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	return pipeline.Download(ctx, item.Url)
+}
+
+func (s *jfrogSource) Render(w io.Writer) {
+	s.repository.Render(w)
+}
+
+type sink struct {
+	username string
+	password string
+
+	chunks *chunkUploader
+}
+
+func newSink(c *pipeline.Context) (pipeline.Sink, error) {
+	chunks, err := newChunkUploader(c.Auth.Username, c.Auth.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &sink{username: c.Auth.Username, password: c.Auth.Password, chunks: chunks}, nil
+}
+
+func (s *sink) Put(ctx context.Context, item *pipeline.Item, body io.Reader) error {
+	if s.chunks.accept(item.Size) {
+		return s.chunks.upload(ctx, getPushUrl(item.Path, true), item.Size, body)
+	}
+
+	pushUrl := getPushUrl(item.Path, false)
+	resp, err := httputil.DefaultClient.Put(ctx, pushUrl, "", body, s.username, s.password)
+	if err != nil {
+		return errors.Wrapf(err, "failed to push to %s", pushUrl)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	return pipeline.CheckPushResponse(resp)
+}
@@ -0,0 +1,357 @@
+// Package journal records the progress of a migration on disk, so that an
+// interrupted migration can be resumed with `--resume <journal>`.
+//
+// A journal is a JSON lines file. The first line is the header of the
+// migration, followed by the exists artifacts of the destination, the pages of
+// the source listing with the cursor of the next page (a nexus continuationToken
+// or a jfrog offset), and the result of every migrated item.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/util/diskset"
+	"github.com/pkg/errors"
+)
+
+const (
+	kindHeader = "header"
+	kindExists = "exists"
+	kindPage   = "page"
+	kindItem   = "item"
+
+	dirName = "journals"
+)
+
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusSkipped   Status = "skipped"
+	StatusFailed    Status = "failed"
+)
+
+type record struct {
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+
+	// header
+	Type string `json:"type,omitempty"`
+	Src  string `json:"src,omitempty"`
+	Dst  string `json:"dst,omitempty"`
+
+	// exists
+	ExistsArtifacts []string `json:"existsArtifacts,omitempty"`
+	ExistsFiles     []string `json:"existsFiles,omitempty"`
+	// ExistsIndex is the path of the diskset index of the exists files if they are too many
+	ExistsIndex string `json:"existsIndex,omitempty"`
+
+	// page
+	Page   json.RawMessage `json:"page,omitempty"`
+	Cursor string          `json:"cursor,omitempty"`
+	Last   bool            `json:"last,omitempty"`
+
+	// item
+	Key     string `json:"key,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Status  Status `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Journal is safe for concurrent use. All methods of a nil *Journal are no-ops,
+// so callers don't have to check whether journaling is enabled.
+type Journal struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	enc  *json.Encoder
+
+	header *record
+	exists *record
+	cursor string
+	listed bool
+
+	results map[string]Status
+}
+
+// DefaultPath returns a new journal path in the carctl config dir for the artifact type.
+func DefaultPath(artifactType string) string {
+	name := fmt.Sprintf("%s-%s.journal", artifactType, time.Now().Format("20060102150405"))
+	return filepath.Join(config.Dir(), dirName, name)
+}
+
+// Create creates a new journal at path for a migration from src to dst.
+func Create(path, artifactType, src, dst string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create journal dir of %s", path)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create journal")
+	}
+
+	j := &Journal{path: path, f: f, enc: json.NewEncoder(f), results: make(map[string]Status)}
+	j.header = &record{Kind: kindHeader, Type: artifactType, Src: src, Dst: dst}
+	if err = j.write(j.header); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// Open loads the journal at path and appends to it. A truncated last record is cut off,
+// so that the records appended later start at a new line.
+func Open(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open journal")
+	}
+
+	j := &Journal{path: path, f: f, enc: json.NewEncoder(f), results: make(map[string]Status)}
+	end, terminated, err := j.load(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(err, "failed to load journal %s", path)
+	}
+	if j.header == nil {
+		_ = f.Close()
+		return nil, errors.Errorf("invalid journal %s: header not found", path)
+	}
+	if err = f.Truncate(end); err == nil && !terminated {
+		_, err = f.WriteString("\n")
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(err, "failed to repair journal %s", path)
+	}
+	return j, nil
+}
+
+// load applies the records of r, and returns the end offset of the last complete record,
+// and whether it is terminated by a newline.
+func (j *Journal) load(r io.Reader) (end int64, terminated bool, err error) {
+	br := bufio.NewReader(r)
+	terminated = true
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, false, err
+		}
+		eof := err == io.EOF
+		offset += int64(len(data))
+		if len(strings.TrimSpace(string(data))) != 0 {
+			rec := new(record)
+			if err = json.Unmarshal(data, rec); err != nil {
+				if eof {
+					// the last record may be truncated if carctl was killed while writing it
+					log.Warnf("ignore truncated record at line %d of journal %s", line, j.path)
+					return end, terminated, nil
+				}
+				return 0, false, errors.Wrapf(err, "invalid record at line %d", line)
+			}
+			j.apply(rec)
+			end, terminated = offset, !eof
+		} else if !eof {
+			end, terminated = offset, true
+		}
+		if eof {
+			return end, terminated, nil
+		}
+	}
+}
+
+func (j *Journal) apply(rec *record) {
+	switch rec.Kind {
+	case kindHeader:
+		j.header = rec
+	case kindExists:
+		j.exists = rec
+	case kindPage:
+		// the pages are read from the file by EachPage, so that they are not kept in memory
+		j.cursor = rec.Cursor
+		j.listed = rec.Last
+	case kindItem:
+		j.results[rec.Key] = rec.Status
+	}
+}
+
+func (j *Journal) write(rec *record) error {
+	rec.Time = time.Now()
+	if err := j.enc.Encode(rec); err != nil {
+		return errors.Wrap(err, "failed to write journal")
+	}
+	return nil
+}
+
+// Path returns the file path of the journal.
+func (j *Journal) Path() string {
+	if j == nil {
+		return ""
+	}
+	return j.path
+}
+
+// Check returns an error if the journal is not of a migration from src to dst.
+func (j *Journal) Check(artifactType, src, dst string) error {
+	if j == nil {
+		return nil
+	}
+	h := j.header
+	if h.Type != artifactType || strings.Trim(h.Src, "/") != strings.Trim(src, "/") || strings.Trim(h.Dst, "/") != strings.Trim(dst, "/") {
+		return errors.Errorf("journal %s is of migrating %s artifacts from %s to %s", j.path, h.Type, h.Src, h.Dst)
+	}
+	return nil
+}
+
+// IndexPath returns the path for the diskset index of the exists files of the destination.
+func (j *Journal) IndexPath() string {
+	if j == nil {
+		return ""
+	}
+	return j.path + ".exists"
+}
+
+// Exists returns the exists artifacts and files of the destination saved by SaveExists.
+// ok is false if they were not saved, or the index of the files is lost.
+func (j *Journal) Exists() (artifacts map[string]bool, files diskset.Set, ok bool) {
+	if j == nil || j.exists == nil {
+		return nil, nil, false
+	}
+	if j.exists.ExistsIndex == "" {
+		return toSet(j.exists.ExistsArtifacts), diskset.Map(toSet(j.exists.ExistsFiles)), true
+	}
+	files, err := diskset.Open(j.exists.ExistsIndex)
+	if err != nil {
+		log.Warnf("failed to open exists index of journal %s: %s", j.path, err)
+		return nil, nil, false
+	}
+	return toSet(j.exists.ExistsArtifacts), files, true
+}
+
+// SaveExists saves the exists artifacts and files of the destination,
+// only the path of files is saved if it is a diskset.DiskSet.
+func (j *Journal) SaveExists(artifacts map[string]bool, files diskset.Set) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.exists = &record{Kind: kindExists, ExistsArtifacts: toSlice(artifacts)}
+	if ds, ok := files.(*diskset.DiskSet); ok {
+		j.exists.ExistsIndex = ds.Path()
+	} else if files != nil {
+		j.exists.ExistsFiles = diskset.Keys(files)
+	}
+	return j.write(j.exists)
+}
+
+// EachPage calls fn with every saved page of the source listing in order, and returns
+// the cursor of the next page, and whether the listing was completed.
+// The pages are read from the journal file rather than kept in memory.
+func (j *Journal) EachPage(fn func(page json.RawMessage) error) (cursor string, listed bool, err error) {
+	if j == nil {
+		return "", false, nil
+	}
+	j.mu.Lock()
+	cursor, listed = j.cursor, j.listed
+	j.mu.Unlock()
+	if cursor == "" && !listed {
+		return "", false, nil
+	}
+
+	f, err := os.Open(j.path)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to open journal")
+	}
+	defer func() { _ = f.Close() }()
+	br := bufio.NewReader(f)
+	for {
+		data, rErr := br.ReadBytes('\n')
+		if rErr != nil && rErr != io.EOF {
+			return "", false, errors.Wrap(rErr, "failed to read journal")
+		}
+		var rec record
+		// the last line may be a record which is being written
+		if json.Unmarshal(data, &rec) == nil && rec.Kind == kindPage && len(rec.Page) != 0 {
+			if err = fn(rec.Page); err != nil {
+				return "", false, err
+			}
+		}
+		if rErr == io.EOF {
+			return cursor, listed, nil
+		}
+	}
+}
+
+// SavePage saves a page of the source listing and the cursor of the next page.
+// last is true if there is no next page.
+func (j *Journal) SavePage(page json.RawMessage, cursor string, last bool) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cursor = cursor
+	j.listed = last
+	return j.write(&record{Kind: kindPage, Page: page, Cursor: cursor, Last: last})
+}
+
+// Done reports whether the item has been migrated or skipped by a previous run.
+func (j *Journal) Done(key string) bool {
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := j.results[key]
+	return status == StatusSucceeded || status == StatusSkipped
+}
+
+// Record saves the result of an item.
+func (j *Journal) Record(key, name string, status Status, message string) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.results[key] = status
+	return j.write(&record{Kind: kindItem, Key: key, Name: name, Status: status, Message: message})
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.f.Close()
+}
+
+func toSet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return set
+}
+
+func toSlice(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k, v := range set {
+		if v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/util/diskset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maven.journal")
+	j, err := Create(path, "maven", "http://127.0.0.1:8081/repository/maven-releases/", "https://demo-maven.pkg.coding.net/repository/p/r")
+	require.NoError(t, err)
+
+	require.NoError(t, j.SaveExists(map[string]bool{"g:a:1.0": true}, nil))
+	require.NoError(t, j.SavePage(json.RawMessage(`[1,2]`), "token-1", false))
+	require.NoError(t, j.Record("a.jar", "g:a:1.1", StatusSucceeded, ""))
+	require.NoError(t, j.Record("b.jar", "g:b:1.1", StatusFailed, "500"))
+	require.NoError(t, j.Close())
+
+	// simulate a record truncated by a crash
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"kind":"item","key":"c.j`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	j, err = Open(path)
+	require.NoError(t, err)
+	defer func() { _ = j.Close() }()
+
+	assert.NoError(t, j.Check("maven", "http://127.0.0.1:8081/repository/maven-releases", "https://demo-maven.pkg.coding.net/repository/p/r/"))
+	assert.Error(t, j.Check("npm", "http://127.0.0.1:8081/repository/maven-releases", "https://demo-maven.pkg.coding.net/repository/p/r"))
+
+	artifacts, _, ok := j.Exists()
+	assert.True(t, ok)
+	assert.True(t, artifacts["g:a:1.0"])
+
+	var pages []string
+	cursor, listed, err := j.EachPage(func(page json.RawMessage) error {
+		pages = append(pages, string(page))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[1,2]"}, pages)
+	assert.Equal(t, "token-1", cursor)
+	assert.False(t, listed)
+
+	assert.True(t, j.Done("a.jar"))
+	assert.False(t, j.Done("b.jar"))
+	assert.False(t, j.Done("c.jar"))
+}
+
+func TestResumeTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maven.journal")
+	j, err := Create(path, "maven", "http://127.0.0.1:8081/repository/maven-releases/", "https://demo-maven.pkg.coding.net/repository/p/r")
+	require.NoError(t, err)
+	require.NoError(t, j.Record("a.jar", "g:a:1.0", StatusSucceeded, ""))
+	require.NoError(t, j.Close())
+
+	// killed while writing a record, then resumed and killed again
+	kill := func(partial string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		require.NoError(t, err)
+		_, err = f.WriteString(partial)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+	kill(`{"kind":"item","key":"b.j`)
+	j, err = Open(path)
+	require.NoError(t, err)
+	require.NoError(t, j.Record("b.jar", "g:b:1.0", StatusSucceeded, ""))
+	require.NoError(t, j.Close())
+
+	// a complete record without its newline is kept
+	kill(`{"kind":"item","key":"c.jar","status":"succeeded"}`)
+	j, err = Open(path)
+	require.NoError(t, err, "the first resume leaves a valid journal")
+	assert.True(t, j.Done("a.jar"))
+	assert.True(t, j.Done("b.jar"))
+	assert.True(t, j.Done("c.jar"))
+	require.NoError(t, j.Record("d.jar", "g:d:1.0", StatusSucceeded, ""))
+	require.NoError(t, j.Close())
+
+	j, err = Open(path)
+	require.NoError(t, err, "the second resume leaves a valid journal")
+	defer func() { _ = j.Close() }()
+	for _, key := range []string{"a.jar", "b.jar", "c.jar", "d.jar"} {
+		assert.True(t, j.Done(key), key)
+	}
+}
+
+func TestExistsIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maven.journal")
+	j, err := Create(path, "maven", "src", "dst")
+	require.NoError(t, err)
+
+	b := diskset.NewBuilder(j.IndexPath(), 1)
+	require.NoError(t, b.Add("g/a/1.0/a-1.0.jar"))
+	require.NoError(t, b.Add("g/a/1.0/a-1.0.pom"))
+	files, err := b.Build()
+	require.NoError(t, err)
+	require.IsType(t, &diskset.DiskSet{}, files)
+	require.NoError(t, j.SaveExists(nil, files))
+	require.NoError(t, j.Close())
+
+	j, err = Open(path)
+	require.NoError(t, err)
+	defer func() { _ = j.Close() }()
+	_, restored, ok := j.Exists()
+	require.True(t, ok)
+	assert.Equal(t, 2, restored.Len())
+	assert.True(t, restored.Has("g/a/1.0/a-1.0.pom"))
+}
+
+func TestNilJournal(t *testing.T) {
+	var j *Journal
+	assert.NoError(t, j.Record("a.jar", "g:a:1.0", StatusSucceeded, ""))
+	assert.False(t, j.Done("a.jar"))
+	assert.NoError(t, j.Close())
+}
@@ -1,609 +1,76 @@
 package maven
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
-	"net/http"
-	"net/url"
-	"os"
 	"path"
 	"path/filepath"
 	"strings"
-	"sync/atomic"
-	"time"
 
 	"github.com/coding-wepack/carctl/pkg/action"
-	"github.com/coding-wepack/carctl/pkg/api"
 	"github.com/coding-wepack/carctl/pkg/config"
 	"github.com/coding-wepack/carctl/pkg/constants"
 	"github.com/coding-wepack/carctl/pkg/log"
-	"github.com/coding-wepack/carctl/pkg/log/logfields"
 	"github.com/coding-wepack/carctl/pkg/migrate/maven/types"
 	"github.com/coding-wepack/carctl/pkg/migrate/maven/types/nexus"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
 	"github.com/coding-wepack/carctl/pkg/remote"
-	reportutil "github.com/coding-wepack/carctl/pkg/report"
 	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/diskset"
 	"github.com/coding-wepack/carctl/pkg/util/fileutil"
-	"github.com/coding-wepack/carctl/pkg/util/httputil"
-	"github.com/coding-wepack/carctl/pkg/util/ioutils"
 	"github.com/pkg/errors"
-	"github.com/vbauerster/mpb/v7"
-	"github.com/vbauerster/mpb/v7/decor"
 )
 
 var (
-	ErrFileConflict = errors.New("failed to put file: 409 conflict")
+	ErrFileConflict = pipeline.ErrFileConflict
 	MetadataXml     = "maven-metadata.xml"
 	Snapshot        = "SNAPSHOT"
 	Metadata        = "Metadata"
 )
 
-func Migrate(cfg *action.Configuration, out io.Writer) error {
-	if settings.Src == "" {
-		settings.Src = defaultMavenRepositoryPath()
-	}
-
-	log.Info("Check authorization of the registry")
-	configFile, err := cfg.RegistryClient.ConfigFile()
-	if err != nil {
-		return errors.Wrap(err, "failed to get config file")
-	}
-
-	has, authConfig, err := configFile.GetAuthConfig(settings.Dst)
-	if err != nil {
-		return errors.Wrap(err, "failed to get registry authorization info")
-	}
-	if !has {
-		return errors.New("Unauthorized: authentication required. Maybe you haven't logged in before.")
-	}
-
-	if settings.Verbose {
-		log.Debug("Auth config", logfields.String("host", authConfig.ServerAddress),
-			logfields.String("username", authConfig.Username),
-			logfields.String("password", authConfig.Password))
-	}
-
-	// exists artifacts
-	var existsVersions map[string]bool
-	var existsFiles map[string]bool
-	if !settings.Force {
-		existsFiles, err = api.FindDstExistsFiles(&authConfig, settings.GetDstWithoutSlash(), constants.TypeMaven)
-		if err != nil {
-			return errors.Wrap(err, "failed to find dst repo exists files")
-		}
-		existsVersions, err = api.FindDstExistsArtifacts(&authConfig, settings.GetDstWithoutSlash(), constants.TypeMaven)
-		if err != nil {
-			return errors.Wrap(err, "failed to find dst repo exists artifacts")
-		}
-	}
-
-	isLocalPath := isLocalRepository(settings.Src)
-	if isLocalPath {
-		// local repository
-		return MigrateFromDisk(&authConfig, out, existsVersions, existsFiles)
-	} else {
-		// remote repository
-		srcUrl, err := url.Parse(settings.Src)
-		if err != nil {
-			log.Warn("Invalid src url", logfields.String("src", settings.Src), logfields.Error(err))
-			return errors.Wrap(err, "invalid src url")
-		}
-		if srcUrl != nil && srcUrl.Scheme == "" {
-			srcUrl.Scheme = "http"
-		}
-		return MigrateFromUrl(&authConfig, out, srcUrl, existsVersions, existsFiles)
-	}
-}
-
-func MigrateFromDisk(cfg *config.AuthConfig, out io.Writer, existsVersions, existsFiles map[string]bool) error {
-	log.Info("Stat source repository ...")
-
-	repositoryFileInfo, err := os.Stat(settings.Src)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			log.Warn("source repository not found", logfields.String("path", settings.Src))
-			return nil
-		}
-		return err
-	}
-	if !repositoryFileInfo.IsDir() {
-		return errors.New("source repository is not a directory")
-	}
-
-	if err = migrateRepository(out, cfg.Username, cfg.Password, existsVersions, existsFiles); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func MigrateFromUrl(cfg *config.AuthConfig, out io.Writer, srcUrl *url.URL, existsVersions, existsFiles map[string]bool) error {
-	// 默认为 nexus
-	if settings.SrcType == "" {
-		settings.SrcType = "nexus"
-	}
-	switch settings.SrcType {
-	case "nexus":
-		return MigrateFromNexus(cfg, out, srcUrl, existsVersions, existsFiles)
-	case "jfrog":
-		return MigrateFromJfrog(cfg, out, srcUrl, existsVersions, existsFiles)
-	default:
-		return errors.Errorf("This src-type [%s] is not supported", settings.SrcType)
-	}
-}
-
-func MigrateFromNexus(cfg *config.AuthConfig, out io.Writer, nexusUrl *url.URL, existsVersions, existsFiles map[string]bool) error {
-	log.Infof("Get file list from source repository [%s] ...", settings.Src)
-
-	nexusScheme := nexusUrl.Scheme
-	nexusHost := nexusUrl.Host
-	urlPath := nexusUrl.Path
-	urlPathStrs := strings.Split(strings.Trim(urlPath, "/"), "/")
-	repoName := urlPathStrs[1]
-	continuationToken := ""
-
-	var nexusItemList []nexus.Item
-	for true {
-		resp, err := getFileListFromNexus(nexusScheme, nexusHost, repoName, continuationToken)
-		if err != nil {
-			log.Errorf("failed to get file list, err: %s, continuationToken: %s", err, continuationToken)
-			break
-		}
-		nexusItemList = append(nexusItemList, resp.Items...)
-		if strings.TrimSpace(resp.ContinuationToken) == "" {
-			break
-		}
-		continuationToken = resp.ContinuationToken
-		// TODO: 是否要做个最大限制跳出循环，并输出 continuationToken 让用户手动执行下一次同步
-	}
-
-	if err := migrateNexusRepository(out, nexusItemList, cfg.Username, cfg.Password, existsVersions, existsFiles); err != nil {
-		return err
+// NewMigration returns the migration of maven repositories.
+func NewMigration() *pipeline.Migration {
+	return &pipeline.Migration{
+		Type: constants.TypeMaven,
+		Sources: map[string]pipeline.SourceFactory{
+			pipeline.SrcTypeLocal: newDiskSource,
+			pipeline.SrcTypeNexus: newNexusSource,
+			pipeline.SrcTypeJfrog: newJfrogSource,
+		},
+		NewSink:      newSink,
+		ExistsFiles:  true,
+		CompareFiles: true,
+		Exists:       exists,
 	}
-
-	return nil
 }
 
-func MigrateFromJfrog(cfg *config.AuthConfig, out io.Writer, jfrogUrl *url.URL, existsVersions, existsFiles map[string]bool) error {
-	log.Infof("Get file list from source repository [%s] ...", settings.Src)
-	// 获取仓库名称
-	urlPathStrs := strings.Split(strings.Trim(jfrogUrl.Path, "/"), "/")
-	repository := urlPathStrs[1]
-
-	filesInfo, err := remote.FindFileListFromJfrog(jfrogUrl, repository)
-	if err != nil {
-		return errors.Wrap(err, "failed to get file list")
-	}
-
-	if err = migrateJfrogRepository(out, filesInfo.Res, cfg.Username, cfg.Password, existsVersions, existsFiles); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// getFileListFromNexus 使用 nexus3 API 来获取文件列表
-func getFileListFromNexus(scheme, nexusHost, repository, continuationToken string) (*nexus.GetAssetsResponse, error) {
-	apiUrl := fmt.Sprintf("%s://%s/service/rest/v1/assets?repository=%s", scheme, nexusHost, repository)
-	if continuationToken != "" {
-		apiUrl = fmt.Sprintf("%s&continuationToken=%s", apiUrl, continuationToken)
-	}
-
-	resp, err := httputil.DefaultClient.GetWithAuth(apiUrl, settings.SrcUsername, settings.SrcPassword)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get components: %s", apiUrl)
-	}
-	defer ioutils.QuiteClose(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		// TODO: 优化的优雅一点，临时修复比较粗糙
-		// 如果状态码为 404，则尝试兼容老版本的 nexus3.x，API 是带有 /nexus 前缀的
-		if resp.StatusCode == http.StatusNotFound {
-			apiUrl = fmt.Sprintf("%s://%s/nexus/service/rest/v1/assets?repository=%s", scheme, nexusHost, repository)
-			if continuationToken != "" {
-				apiUrl = fmt.Sprintf("%s&continuationToken=%s", apiUrl, continuationToken)
-			}
-			resp, err = httputil.DefaultClient.GetWithAuth(apiUrl, settings.SrcUsername, settings.SrcPassword)
-			if err != nil {
-				return nil, errors.Wrapf(err, "failed to get components: %s", apiUrl)
-			}
-			defer ioutils.QuiteClose(resp.Body)
-
-			if resp.StatusCode != http.StatusOK {
-				return nil, errors.Errorf("failed to get components: %s, status: %s", apiUrl, resp.Status)
-			}
-		} else {
-			return nil, errors.Errorf("failed to get components: %s, status: %s", apiUrl, resp.Status)
-		}
-	}
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to read resp: %s", apiUrl)
-	}
-
-	var getComponentsResp *nexus.GetAssetsResponse
-	err = json.Unmarshal(bodyBytes, &getComponentsResp)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to unmarshal resp: %s", string(bodyBytes))
-	}
-	return getComponentsResp, nil
-}
-
-func migrateRepository(w io.Writer, username, password string, existsVersions, existsFiles map[string]bool) error {
-	log.Info("Scanning repository ...")
-
-	repository, err := GetRepository(settings.Src, settings.MaxFiles, existsVersions, existsFiles)
-	if err != nil {
-		return err
-	}
-	flattenRepository := repository.Flatten()
-	log.Info("Successfully to scan the repository",
-		logfields.Int("groups", flattenRepository.GetGroupCount()),
-		logfields.Int("artifacts", flattenRepository.GetArtifactCount()),
-		logfields.Int("versions", flattenRepository.GetVersionCount()),
-		logfields.Int("files", flattenRepository.GetFileCount()))
-	if flattenRepository.GetFileCount() == 0 {
-		log.Warn("no files found or files have been migrated, no need to migrate")
-		return nil
-	}
-	if settings.Verbose {
-		log.Info("Repository Info:")
-		repository.Render(w)
-	}
-
-	// Progress Bar
-	// initialize progress container, with custom width
-	p := mpb.New(mpb.WithWidth(80))
-	total := flattenRepository.GetFileCount()
-	const pbName = "Pushing:"
-	// adding a single bar, which will inherit container's width
-	bar := p.Add(
-		int64(total),
-		mpb.NewBarFiller(mpb.BarStyle()),
-		mpb.PrependDecorators(
-			// display our name with one space on the right
-			decor.Name(pbName, decor.WC{W: len(pbName) + 1, C: decor.DidentRight}),
-			// replace ETA decorator with "done" message, OnComplete event
-			decor.OnComplete(
-				decor.AverageETA(decor.ET_STYLE_GO, decor.WC{W: 4}), "Done!",
-			),
-		),
-		mpb.AppendDecorators(
-			// counter
-			decor.Counters(0, "%d / %d  "),
-			// percentage
-			decor.Percentage(),
-			// average
-			// mpb.AppendDecorators(decor.AverageSpeed(decor.UnitKiB, "  % .1f")),
-		),
-	)
-
-	log.Info("Begin to migrate ...")
-	start := time.Now()
-
-	report := reportutil.NewReport()
-	if settings.Verbose {
-		defer func() {
-			log.Info("Migrate result:")
-			report.Render(w)
-		}()
-	}
-
-	var count int32
-	if err := repository.ParallelForEach(func(group, artifact, version, path, downloadUrl string, size int64) error {
-		defer bar.Increment()
-		atomic.AddInt32(&count, 1)
-		if err1 := doLocalMigrate(path, username, password); err1 != nil {
-			if err1 == ErrFileConflict {
-				report.AddSkippedResult(strings.Join([]string{group, artifact, version}, ":"), path, "409 Conflict")
-				return types.ErrForEachContinue
-			}
-
-			report.AddFailedResult(strings.Join([]string{group, artifact, version}, ":"), path, err1.Error())
-
-			if settings.FailFast {
-				return errors.Wrapf(err1, "failed to migrate %s", path)
-			}
-		} else {
-			report.AddSucceededResult(strings.Join([]string{group, artifact, version}, ":"), path, "Succeeded")
-		}
-		atomic.AddInt32(&count, -1)
-
-		return nil
-	}); err != nil {
-		return err
-	}
-
-	go func() {
-		file, err := os.Create("goroutine.txt")
-		if err != nil {
-			log.Error("", logfields.Error(err))
-			return
-		}
-		defer file.Close()
-		write := bufio.NewWriter(file)
-
-		for {
-			_, err = write.WriteString(fmt.Sprintf("%s goroutine number : %d\n", time.Now().Format("15:04:05.000"), count))
-			write.Flush()
-			if err != nil {
-				log.Error("", logfields.Error(err))
-				return
-			}
-			time.Sleep(time.Millisecond * 100)
-		}
-	}()
-
-	// wait for our bar to complete and flush
-	p.Wait()
-
-	log.Info("End to migrate.",
-		logfields.Duration("duration", time.Now().Sub(start)),
-		logfields.Int("succeededCount", len(report.SucceededResult)),
-		logfields.Int("skippedCount", len(report.SkippedResult)),
-		logfields.Int("failedCount", len(report.FailedResult)))
-
-	return nil
-}
-
-func migrateJfrogRepository(w io.Writer, jfrogFiles []remote.JfrogFile, username, password string, existsVersions, existsFiles map[string]bool) error {
-	log.Info("Scanning jfrog repository ...")
-
-	repository, err := GetRepositoryFromJfrogFile(settings.Src, jfrogFiles, existsVersions, existsFiles)
-	if err != nil {
-		return err
-	}
-	flattenRepository := repository.Flatten()
-	log.Info("Successfully to scan the repository",
-		logfields.Int("groups", flattenRepository.GetGroupCount()),
-		logfields.Int("artifacts", flattenRepository.GetArtifactCount()),
-		logfields.Int("versions", flattenRepository.GetVersionCount()),
-		logfields.Int("files", flattenRepository.GetFileCount()))
-	if flattenRepository.GetFileCount() == 0 {
-		log.Warn("no files found or files have been migrated, no need to migrate")
-		return nil
-	}
-	if settings.Verbose {
-		log.Info("Repository Info:")
-		repository.Render(w)
-	}
-	if settings.DryRun {
-		return nil
-	}
-
-	// Progress Bar
-	// initialize progress container, with custom width
-	p := mpb.New(mpb.WithWidth(80))
-	total := flattenRepository.GetFileCount()
-	const pbName = "Pushing:"
-	// adding a single bar, which will inherit container's width
-	bar := p.Add(
-		int64(total),
-		mpb.NewBarFiller(mpb.BarStyle()),
-		mpb.PrependDecorators(
-			// display our name with one space on the right
-			decor.Name(pbName, decor.WC{W: len(pbName) + 1, C: decor.DidentRight}),
-			// replace ETA decorator with "done" message, OnComplete event
-			decor.OnComplete(
-				decor.AverageETA(decor.ET_STYLE_GO, decor.WC{W: 4}), "Done!",
-			),
-		),
-		mpb.AppendDecorators(
-			// counter
-			decor.Counters(0, "%d / %d  "),
-			// percentage
-			decor.Percentage(),
-			// average
-			// mpb.AppendDecorators(decor.AverageSpeed(decor.UnitKiB, "  % .1f")),
-		),
-	)
-
-	log.Info("Begin to migrate maven artifacts ...")
-	start := time.Now()
-
-	report := reportutil.NewReport()
-	if settings.Verbose {
-		defer func() {
-			log.Info("Migrate result:")
-			report.RenderV2(w)
-		}()
-	}
-
-	if err = repository.ParallelForEach(func(group, artifact, version, path, downloadUrl string, size int64) error {
-		defer bar.Increment()
-		if useTime, err1 := doRemoteMigrate(path, downloadUrl, username, password); err1 != nil {
-			if err1 == ErrFileConflict {
-				report.AddSkippedResultV2(strings.Join([]string{group, artifact, version}, ":"), downloadUrl, "409 Conflict", size, useTime)
-				return types.ErrForEachContinue
-			}
-
-			report.AddFailedResultV2(strings.Join([]string{group, artifact, version}, ":"), downloadUrl, err1.Error(), size, useTime)
-
-			if settings.FailFast {
-				return errors.Wrapf(err1, "failed to migrate %s", path)
-			}
-		} else {
-			report.AddSucceededResultV2(strings.Join([]string{group, artifact, version}, ":"), downloadUrl, "Succeeded", size, useTime)
-		}
-
-		return nil
-	}); err != nil {
-		return err
-	}
-
-	// wait for our bar to complete and flush
-	p.Wait()
-
-	log.Info("End to migrate.",
-		logfields.Duration("duration", time.Now().Sub(start)),
-		logfields.Int("succeededCount", len(report.SucceededResult)),
-		logfields.Int("skippedCount", len(report.SkippedResult)),
-		logfields.Int("failedCount", len(report.FailedResult)))
-
-	return nil
-}
-
-func migrateNexusRepository(w io.Writer, nexusItemList []nexus.Item, username, password string, existsVersions, existsFiles map[string]bool) error {
-	log.Info("Scanning nexus repository ...")
-
-	repository, err := GetRepositoryFromNexusItems(settings.Src, nexusItemList, existsVersions, existsFiles)
-	if err != nil {
-		return err
-	}
-	flattenRepository := repository.Flatten()
-	log.Info("Successfully to scan the repository",
-		logfields.Int("groups", flattenRepository.GetGroupCount()),
-		logfields.Int("artifacts", flattenRepository.GetArtifactCount()),
-		logfields.Int("versions", flattenRepository.GetVersionCount()),
-		logfields.Int("files", flattenRepository.GetFileCount()))
-	if flattenRepository.GetFileCount() == 0 {
-		log.Warn("no files found or files have been migrated, no need to migrate")
-		return nil
-	}
-	if settings.Verbose {
-		log.Info("Repository Info:")
-		repository.Render(w)
-	}
-
-	// Progress Bar
-	// initialize progress container, with custom width
-	p := mpb.New(mpb.WithWidth(80))
-	total := flattenRepository.GetFileCount()
-	const pbName = "Pushing:"
-	// adding a single bar, which will inherit container's width
-	bar := p.Add(
-		int64(total),
-		mpb.NewBarFiller(mpb.BarStyle()),
-		mpb.PrependDecorators(
-			// display our name with one space on the right
-			decor.Name(pbName, decor.WC{W: len(pbName) + 1, C: decor.DidentRight}),
-			// replace ETA decorator with "done" message, OnComplete event
-			decor.OnComplete(
-				decor.AverageETA(decor.ET_STYLE_GO, decor.WC{W: 4}), "Done!",
-			),
-		),
-		mpb.AppendDecorators(
-			// counter
-			decor.Counters(0, "%d / %d  "),
-			// percentage
-			decor.Percentage(),
-			// average
-			// mpb.AppendDecorators(decor.AverageSpeed(decor.UnitKiB, "  % .1f")),
-		),
-	)
-
-	log.Info("Begin to migrate ...")
-	start := time.Now()
-
-	report := reportutil.NewReport()
-	if settings.Verbose {
-		defer func() {
-			log.Info("Migrate result:")
-			report.Render(w)
-		}()
-	}
-
-	if err := repository.ParallelForEach(func(group, artifact, version, path, downloadUrl string, size int64) error {
-		defer bar.Increment()
-		if _, err1 := doRemoteMigrate(path, downloadUrl, username, password); err1 != nil {
-			if err1 == ErrFileConflict {
-				report.AddSkippedResult(strings.Join([]string{group, artifact, version}, ":"), downloadUrl, "409 Conflict")
-				return types.ErrForEachContinue
-			}
-
-			report.AddFailedResult(strings.Join([]string{group, artifact, version}, ":"), downloadUrl, err1.Error())
-
-			if settings.FailFast {
-				return errors.Wrapf(err1, "failed to migrate %s", path)
-			}
-		} else {
-			report.AddSucceededResult(strings.Join([]string{group, artifact, version}, ":"), downloadUrl, "Succeeded")
-		}
-
-		return nil
-	}); err != nil {
-		return err
-	}
-
-	// wait for our bar to complete and flush
-	p.Wait()
-
-	log.Info("End to migrate.",
-		logfields.Duration("duration", time.Now().Sub(start)),
-		logfields.Int("succeededCount", len(report.SucceededResult)),
-		logfields.Int("skippedCount", len(report.SkippedResult)),
-		logfields.Int("failedCount", len(report.FailedResult)))
-
-	return nil
-}
-
-func doLocalMigrate(file, username, password string) error {
-	u := getPushUrl(file)
-	// log.Info("Put file:", logfields.String("file", file), logfields.String("url", u))
-	resp, err := httputil.DefaultClient.PutFile(u, file, username, password)
-	if err != nil {
-		return err
-	}
-	defer ioutils.QuiteClose(resp.Body)
-	if resp.StatusCode >= http.StatusBadRequest {
-		if resp.StatusCode == http.StatusConflict {
-			// log.Warn("409 Conflict: file has been pushed, and the strategy of destination is not overridable, so just skip it",
-			// 	logfields.String("file", file))
-			return ErrFileConflict
-		}
-		return errors.Errorf("got an unexpected response status: %s", resp.Status)
+func Migrate(ctx context.Context, cfg *action.Configuration, out io.Writer) error {
+	if settings.Src == "" {
+		settings.Src = defaultMavenRepositoryPath()
 	}
-
-	return nil
+	return pipeline.Migrate(ctx, cfg, out, NewMigration())
 }
 
-func doRemoteMigrate(path, downloadUrl, username, password string) (useTime int64, err error) {
-	start := time.Now()
-	defer func() { useTime = time.Since(start).Milliseconds() }()
-	var resp *http.Response
-	for i := 0; i < 3; i++ {
-		resp, err = downloadAndUpload(path, downloadUrl, username, password)
-		if err == nil {
-			break
-		}
-		log.Warn("migrate maven artifacts failed, retry in 1 second...")
-		time.Sleep(time.Second)
-	}
-	if resp.StatusCode >= http.StatusBadRequest {
-		if resp.StatusCode == http.StatusConflict {
-			// log.Warn("409 Conflict: file has been pushed, and the strategy of destination is not overridable, so just skip it",
-			// 	logfields.String("file", file))
-			return useTime, ErrFileConflict
-		}
-		return useTime, errors.Errorf("got an push unexpected response status: %s", resp.Status)
+// Plan writes the plan of the migration to path.
+func Plan(ctx context.Context, cfg *action.Configuration, out io.Writer, path string) error {
+	if settings.Src == "" {
+		settings.Src = defaultMavenRepositoryPath()
 	}
-
-	return useTime, nil
+	return pipeline.WritePlan(ctx, cfg, out, NewMigration(), path)
 }
 
-func downloadAndUpload(path, downloadUrl, username, password string) (resp *http.Response, err error) {
-	// download
-	getResp, err := httputil.DefaultClient.GetWithAuth(downloadUrl, settings.SrcUsername, settings.SrcPassword)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to download from %s", downloadUrl)
-	}
-	defer ioutils.QuiteClose(getResp.Body)
-
-	// push
-	pushUrl := getPushUrl(path)
-	resp, err = httputil.DefaultClient.Put(pushUrl, "", getResp.Body, username, password)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to push to %s", pushUrl)
+// exists reports whether the file of item exists in the destination, the name of item is group:artifact:version.
+func exists(c *pipeline.Context, item *pipeline.Item) bool {
+	gav := strings.Split(item.Name, ":")
+	if len(gav) != 3 {
+		return false
 	}
-	defer ioutils.QuiteClose(resp.Body)
-	return
+	return !isNeedMigrate(c.ExistsArtifacts, c.ExistsFiles, gav[0], gav[1], gav[2], path.Base(item.Path))
 }
 
-func GetRepository(repositoryPath string, maxFiles int, existsVersions, existsFiles map[string]bool) (repository *types.Repository, err error) {
+func GetRepository(repositoryPath string, maxFiles int, existsVersions map[string]bool, existsFiles diskset.Set) (repository *types.Repository, err error) {
 	var fileCount int
 	var needMigrateFileCount int
 	repository = &types.Repository{Path: repositoryPath}
@@ -650,9 +117,18 @@ func GetRepository(repositoryPath string, maxFiles int, existsVersions, existsFi
 	return
 }
 
-func GetRepositoryFromJfrogFile(repositoryUrl string, jfrogFiles []remote.JfrogFile, existsVersions, existsFiles map[string]bool) (repository *types.Repository, err error) {
-	var fileCount int
-	var needMigrateFileCount int
+func GetRepositoryFromJfrogFile(repositoryUrl string, jfrogFiles []remote.JfrogFile, existsVersions map[string]bool, existsFiles diskset.Set) (repository *types.Repository, err error) {
+	repository, fileCount, needMigrateFileCount := repositoryFromJfrogFiles(repositoryUrl, jfrogFiles, existsVersions, existsFiles)
+
+	// 上面逻辑处理文件的时候，包含 Metadata 文件，因不属于制品版本级别，所以无法通过已存在制品版本来过滤，此处单独过滤
+	needMigrateFileCount = repository.CleanInvalidMetadata(needMigrateFileCount)
+
+	log.Infof("remote repository file count is:%d, need migrate count is:%d", fileCount, needMigrateFileCount)
+	return
+}
+
+// repositoryFromJfrogFiles 返回 jfrogFiles 中需要迁移的文件，不过滤仅有 Metadata 的制品
+func repositoryFromJfrogFiles(repositoryUrl string, jfrogFiles []remote.JfrogFile, existsVersions map[string]bool, existsFiles diskset.Set) (repository *types.Repository, fileCount, needMigrateFileCount int) {
 	repository = &types.Repository{Path: repositoryUrl}
 	for _, file := range jfrogFiles {
 		subPath := fmt.Sprintf("%s/%s", file.Path, file.Name)
@@ -668,6 +144,11 @@ func GetRepositoryFromJfrogFile(repositoryUrl string, jfrogFiles []remote.JfrogF
 			needMigrateFileCount++
 		}
 	}
+	return
+}
+
+func GetRepositoryFromNexusItems(repositoryUrl string, nexusItemList []nexus.Item, existsVersions map[string]bool, existsFiles diskset.Set) (repository *types.Repository, err error) {
+	repository, fileCount, needMigrateFileCount, err := repositoryFromNexusItems(repositoryUrl, nexusItemList, existsVersions, existsFiles)
 
 	// 上面逻辑处理文件的时候，包含 Metadata 文件，因不属于制品版本级别，所以无法通过已存在制品版本来过滤，此处单独过滤
 	needMigrateFileCount = repository.CleanInvalidMetadata(needMigrateFileCount)
@@ -676,9 +157,8 @@ func GetRepositoryFromJfrogFile(repositoryUrl string, jfrogFiles []remote.JfrogF
 	return
 }
 
-func GetRepositoryFromNexusItems(repositoryUrl string, nexusItemList []nexus.Item, existsVersions, existsFiles map[string]bool) (repository *types.Repository, err error) {
-	var fileCount int
-	var needMigrateFileCount int
+// repositoryFromNexusItems 返回 nexusItemList 中需要迁移的文件，不过滤仅有 Metadata 的制品
+func repositoryFromNexusItems(repositoryUrl string, nexusItemList []nexus.Item, existsVersions map[string]bool, existsFiles diskset.Set) (repository *types.Repository, fileCount, needMigrateFileCount int, err error) {
 	repository = &types.Repository{Path: repositoryUrl}
 	for _, item := range nexusItemList {
 		var groupName, artifact, version, filename string
@@ -697,10 +177,6 @@ func GetRepositoryFromNexusItems(repositoryUrl string, nexusItemList []nexus.Ite
 			needMigrateFileCount++
 		}
 	}
-	// 上面逻辑处理文件的时候，包含 Metadata 文件，因不属于制品版本级别，所以无法通过已存在制品版本来过滤，此处单独过滤
-	needMigrateFileCount = repository.CleanInvalidMetadata(needMigrateFileCount)
-
-	log.Infof("remote repository file count is:%d, need migrate count is:%d", fileCount, needMigrateFileCount)
 	return
 }
 
@@ -758,13 +234,10 @@ func defaultMavenRepositoryPath() string {
 }
 
 func isLocalRepository(src string) bool {
-	if strings.HasPrefix(src, "http") {
-		return false
-	}
-	return true
+	return pipeline.IsLocalRepository(src)
 }
 
-func isNeedMigrate(existsVersions, existsFiles map[string]bool, groupName, artifact, version, filename string) bool {
+func isNeedMigrate(existsVersions map[string]bool, existsFiles diskset.Set, groupName, artifact, version, filename string) bool {
 	if settings.Force {
 		return true
 	}
@@ -784,7 +257,7 @@ func isNeedMigrate(existsVersions, existsFiles map[string]bool, groupName, artif
 	} else {
 		fileName = join("/", groupName, artifact, version, filename)
 	}
-	return !existsFiles[fileName]
+	return !existsFiles.Has(fileName)
 }
 
 func join(sep string, elems ...string) string {
@@ -1,13 +1,18 @@
 package maven
 
 import (
+	"context"
 	"os"
 	"testing"
 
 	"github.com/coding-wepack/carctl/pkg/action"
 	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/migrate/maven/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
 	"github.com/coding-wepack/carctl/pkg/registry"
+	"github.com/coding-wepack/carctl/pkg/remote"
 	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/diskset"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,7 +29,7 @@ func TestMigrate(t *testing.T) {
 
 	cfg := &action.Configuration{RegistryClient: regCli}
 
-	err = Migrate(cfg, os.Stdout)
+	err = Migrate(context.Background(), cfg, os.Stdout)
 	assert.NoError(t, err)
 }
 
@@ -55,7 +60,7 @@ func TestMigrateFromNexus(t *testing.T) {
 
 	cfg := &action.Configuration{RegistryClient: regCli}
 
-	err = Migrate(cfg, os.Stdout)
+	err = Migrate(context.Background(), cfg, os.Stdout)
 	assert.NoError(t, err)
 }
 
@@ -112,3 +117,47 @@ func TestGetArtInfoFromSubPath(t *testing.T) {
 		assert.Equal(t, "maven-metadata.xml.md5", filename)
 	}
 }
+
+func TestScanRemotePages(t *testing.T) {
+	settings.Src = "http://jfrog.example.com/artifactory/maven-local"
+	file := func(path, name string) remote.JfrogFile {
+		return remote.JfrogFile{Path: path, Name: name, Type: "file"}
+	}
+	// sorted by path like the AQL, the metadata and the versions of an artifact are in different pages
+	pages := [][]remote.JfrogFile{
+		{
+			file("com/example/app", "maven-metadata.xml"),
+			file("com/example/app", "maven-metadata.xml.sha1"),
+			file("com/example/lib/1.0", "lib-1.0.jar"),
+			file("com/example/orphan", "maven-metadata.xml"),
+		},
+		{
+			file("com/example/app/1.0", "app-1.0.jar"),
+			file("com/example/lib", "maven-metadata.xml"),
+		},
+	}
+
+	var paths []string
+	err := scanRemotePages(func(emit func(p remotePage) error) error {
+		for _, page := range pages {
+			repository, fileCount, needMigrateFileCount := repositoryFromJfrogFiles(settings.Src, page, nil, diskset.Map{})
+			if err := emit(remotePage{repository, jfrogFiles(page), fileCount, needMigrateFileCount}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(repository *types.Repository, files map[string]remoteFile) error {
+		return forEachItem(repository, files, func(item *pipeline.Item) error {
+			paths = append(paths, item.Path)
+			return nil
+		})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"com/example/lib/1.0/lib-1.0.jar",
+		"com/example/app/1.0/app-1.0.jar",
+		"com/example/app/maven-metadata.xml",
+		"com/example/app/maven-metadata.xml.sha1",
+		"com/example/lib/maven-metadata.xml",
+	}, paths)
+}
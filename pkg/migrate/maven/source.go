@@ -0,0 +1,319 @@
+package maven
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/migrate/maven/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/maven/types/nexus"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/remote"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/pkg/errors"
+)
+
+// source scans a maven repository into a types.Repository and flattens it into items.
+type source struct {
+	scan func(ctx context.Context) (*types.Repository, error)
+	// scanPages calls fn with the repository and the remote files of every page of the remote file list,
+	// it is nil for a local repository
+	scanPages func(ctx context.Context, fn func(repository *types.Repository, files map[string]remoteFile) error) error
+
+	repository *types.Repository
+	// remote files by download url
+	files map[string]remoteFile
+}
+
+func newDiskSource(c *pipeline.Context) (pipeline.Source, error) {
+	log.Info("Stat source repository ...")
+	repositoryFileInfo, err := os.Stat(settings.Src)
+	if err != nil {
+		return nil, err
+	}
+	if !repositoryFileInfo.IsDir() {
+		return nil, errors.New("source repository is not a directory")
+	}
+
+	return &source{scan: func(ctx context.Context) (*types.Repository, error) {
+		return GetRepository(settings.Src, settings.MaxFiles, c.ExistsArtifacts, c.ExistsFiles)
+	}}, nil
+}
+
+func newNexusSource(c *pipeline.Context) (pipeline.Source, error) {
+	s := &source{}
+	s.scan = func(ctx context.Context) (*types.Repository, error) {
+		log.Infof("Get file list from source repository [%s] ...", settings.Src)
+		nexusItemList, err := remote.FindAssetsFromNexus[nexus.Item](ctx, c.SrcUrl, c.Journal)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get file list")
+		}
+		s.files = nexusFiles(nexusItemList)
+		return GetRepositoryFromNexusItems(settings.Src, nexusItemList, c.ExistsArtifacts, c.ExistsFiles)
+	}
+	s.scanPages = func(ctx context.Context, fn func(repository *types.Repository, files map[string]remoteFile) error) error {
+		return scanRemotePages(func(emit func(p remotePage) error) error {
+			return remote.EachAssetsPageFromNexus(ctx, c.SrcUrl, c.Journal, func(page []nexus.Item) error {
+				repository, fileCount, needMigrateFileCount, err := repositoryFromNexusItems(settings.Src, page, c.ExistsArtifacts, c.ExistsFiles)
+				if err != nil {
+					return err
+				}
+				return emit(remotePage{repository, nexusFiles(page), fileCount, needMigrateFileCount})
+			})
+		}, fn)
+	}
+	return s, nil
+}
+
+func newJfrogSource(c *pipeline.Context) (pipeline.Source, error) {
+	s := &source{}
+	s.scan = func(ctx context.Context) (*types.Repository, error) {
+		log.Infof("Get file list from source repository [%s] ...", settings.Src)
+		filesInfo, err := remote.FindFileListFromJfrog(ctx, c.SrcUrl, jfrogRepositoryName(c), c.Journal)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get file list")
+		}
+		s.files = jfrogFiles(filesInfo.Res)
+		return GetRepositoryFromJfrogFile(settings.Src, filesInfo.Res, c.ExistsArtifacts, c.ExistsFiles)
+	}
+	s.scanPages = func(ctx context.Context, fn func(repository *types.Repository, files map[string]remoteFile) error) error {
+		return scanRemotePages(func(emit func(p remotePage) error) error {
+			return remote.EachFileListPageFromJfrog(ctx, c.SrcUrl, jfrogRepositoryName(c), c.Journal, func(page []remote.JfrogFile) error {
+				repository, fileCount, needMigrateFileCount := repositoryFromJfrogFiles(settings.Src, page, c.ExistsArtifacts, c.ExistsFiles)
+				return emit(remotePage{repository, jfrogFiles(page), fileCount, needMigrateFileCount})
+			})
+		}, fn)
+	}
+	return s, nil
+}
+
+// remotePage is a page of the remote file list, whose metadata-only artifacts are not cleaned.
+type remotePage struct {
+	repository           *types.Repository
+	files                map[string]remoteFile
+	fileCount            int
+	needMigrateFileCount int
+}
+
+// scanRemotePages calls fn with the repository and the remote files of every page listed by each.
+// The maven-metadata.xml of an artifact and its versions may be listed in different pages,
+// so a metadata-only artifact is held back until a page shows a version of it, and dropped if the listing ends without one.
+func scanRemotePages(each func(emit func(p remotePage) error) error, fn func(repository *types.Repository, files map[string]remoteFile) error) error {
+	var fileCount, needMigrateFileCount int
+	// artifacts which have versions to migrate, by group:artifact
+	versioned := make(map[string]bool)
+	// metadata-only artifacts held back, by group:artifact
+	held := make(map[string]*remotePage)
+
+	err := each(func(p remotePage) error {
+		fileCount += p.fileCount
+		needMigrateFileCount += p.needMigrateFileCount
+		var groups []*types.Group
+		for _, g := range p.repository.Groups {
+			var artifacts []*types.Artifact
+			for _, a := range g.Artifacts {
+				key := g.Name + ":" + a.Name
+				if !isMetadataOnly(a) {
+					versioned[key] = true
+					if h, ok := held[key]; ok {
+						// the versions are listed, emit the metadata held back with them
+						delete(held, key)
+						_ = h.repository.ForEach(func(_, _, version, path, downloadUrl string, size int64) error {
+							p.repository.AddVersionFileBase(g.Name, a.Name, version, filepath.Base(path), path, downloadUrl, size)
+							p.files[downloadUrl] = h.files[downloadUrl]
+							return nil
+						})
+					}
+				} else if !versioned[key] {
+					h, ok := held[key]
+					if !ok {
+						h = &remotePage{repository: &types.Repository{Path: p.repository.Path}, files: make(map[string]remoteFile)}
+						held[key] = h
+					}
+					for _, v := range a.Versions {
+						for _, f := range v.Files {
+							h.repository.AddVersionFileBase(g.Name, a.Name, v.Name, f.Name, f.Path, f.DownloadUrl, f.Size)
+							h.files[f.DownloadUrl] = p.files[f.DownloadUrl]
+						}
+					}
+					continue
+				}
+				artifacts = append(artifacts, a)
+			}
+			if g.Artifacts = artifacts; len(artifacts) > 0 {
+				groups = append(groups, g)
+			}
+		}
+		p.repository.Groups = groups
+		return fn(p.repository, p.files)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get file list")
+	}
+
+	for _, h := range held {
+		needMigrateFileCount -= h.repository.GetFileCount()
+	}
+	log.Infof("remote repository file count is:%d, need migrate count is:%d", fileCount, needMigrateFileCount)
+	return nil
+}
+
+// isMetadataOnly returns whether the artifact has only the maven-metadata.xml files and no versions.
+func isMetadataOnly(a *types.Artifact) bool {
+	return len(a.Versions) == 1 && strings.EqualFold(a.Versions[0].Name, Metadata)
+}
+
+// jfrogRepositoryName returns the name of the source repository, which is the second segment of the url path.
+func jfrogRepositoryName(c *pipeline.Context) string {
+	// 获取仓库名称
+	urlPathStrs := strings.Split(strings.Trim(c.SrcUrl.Path, "/"), "/")
+	return urlPathStrs[1]
+}
+
+// remoteFile is the information of a file in a remote repository, which is not kept in types.Repository.
+type remoteFile struct {
+	checksum   pipeline.Checksum
+	modified   time.Time
+	downloaded *time.Time
+}
+
+func nexusFiles(nexusItemList []nexus.Item) map[string]remoteFile {
+	files := make(map[string]remoteFile, len(nexusItemList))
+	for _, item := range nexusItemList {
+		files[item.DownloadUrl] = remoteFile{
+			checksum: pipeline.Checksum{
+				Md5:    item.Checksum.Md5,
+				Sha1:   item.Checksum.Sha1,
+				Sha256: item.Checksum.Sha256,
+				Sha512: item.Checksum.Sha512,
+			},
+			modified:   item.LastModified,
+			downloaded: pipeline.NexusDownloaded(item.LastDownloaded),
+		}
+	}
+	return files
+}
+
+func jfrogFiles(jfrogFileList []remote.JfrogFile) map[string]remoteFile {
+	files := make(map[string]remoteFile, len(jfrogFileList))
+	for i := range jfrogFileList {
+		f := &jfrogFileList[i]
+		downloadUrl := fmt.Sprintf("%s/%s/%s", settings.GetSrcWithoutSlash(), f.Path, f.Name)
+		files[downloadUrl] = remoteFile{
+			checksum:   pipeline.JfrogChecksum(f),
+			modified:   f.ModifiedTime(),
+			downloaded: f.DownloadedTime(),
+		}
+	}
+	return files
+}
+
+func (s *source) List(ctx context.Context) ([]*pipeline.Item, error) {
+	repository, err := s.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.repository = repository
+
+	flattenRepository := repository.Flatten()
+	log.Info("Successfully to scan the maven repository",
+		logfields.Int("groups", flattenRepository.GetGroupCount()),
+		logfields.Int("artifacts", flattenRepository.GetArtifactCount()),
+		logfields.Int("versions", flattenRepository.GetVersionCount()),
+		logfields.Int("files", flattenRepository.GetFileCount()))
+
+	var items []*pipeline.Item
+	_ = forEachItem(repository, s.files, func(item *pipeline.Item) error {
+		items = append(items, item)
+		return nil
+	})
+	return items, nil
+}
+
+// Stream migrates the files page by page while listing a remote repository,
+// a local repository is scanned at once.
+func (s *source) Stream(ctx context.Context, fn func(item *pipeline.Item) error) error {
+	if s.scanPages == nil {
+		items, err := s.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err = fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	return s.scanPages(ctx, func(repository *types.Repository, files map[string]remoteFile) error {
+		return forEachItem(repository, files, fn)
+	})
+}
+
+// forEachItem calls fn with the items of the files of repository.
+func forEachItem(repository *types.Repository, files map[string]remoteFile, fn func(item *pipeline.Item) error) error {
+	return repository.ForEach(func(group, artifact, version, path, downloadUrl string, size int64) error {
+		item := &pipeline.Item{
+			Name:    strings.Join([]string{group, artifact, version}, ":"),
+			Package: strings.Join([]string{group, artifact}, ":"),
+			Version: version,
+			Path:    strings.Trim(filepath.ToSlash(strings.TrimPrefix(path, settings.Src)), "/"),
+			Url:     downloadUrl,
+			Size:    size,
+		}
+		if f, ok := files[downloadUrl]; ok {
+			item.Checksum = f.checksum
+			item.Modified = f.modified
+			item.Downloaded = f.downloaded
+		}
+		item.DstPath = item.Path
+		item.Coordinate = item.Name
+		if item.Url == "" {
+			// local repository
+			item.Url = path
+			if info, err := os.Stat(path); err == nil {
+				item.Modified = info.ModTime()
+			}
+		}
+		return fn(item)
+	})
+}
+
+func (s *source) Open(ctx context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	if isLocalRepository(item.Url) {
+		return pipeline.OpenFile(item.Url)
+	}
+	return pipeline.Download(ctx, item.Url)
+}
+
+func (s *source) Render(w io.Writer) {
+	s.repository.Render(w)
+}
+
+type sink struct {
+	username string
+	password string
+}
+
+func newSink(c *pipeline.Context) (pipeline.Sink, error) {
+	return &sink{username: c.Auth.Username, password: c.Auth.Password}, nil
+}
+
+func (s *sink) Put(ctx context.Context, item *pipeline.Item, body io.Reader) error {
+	pushUrl := getPushUrl(item.Path)
+	resp, err := httputil.DefaultClient.Put(ctx, pushUrl, "", body, s.username, s.password)
+	if err != nil {
+		return errors.Wrapf(err, "failed to push to %s", pushUrl)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	return pipeline.CheckPushResponse(resp)
+}
@@ -5,11 +5,6 @@ import (
 	"time"
 )
 
-type GetAssetsResponse struct {
-	Items             []Item `json:"items"`
-	ContinuationToken string `json:"continuationToken"`
-}
-
 type Item struct {
 	DownloadUrl string `json:"downloadUrl"`
 	Path        string `json:"path"`
@@ -6,14 +6,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
-	"sync"
-	"sync/atomic"
-
-	"github.com/coding-wepack/carctl/pkg/log"
-	"github.com/coding-wepack/carctl/pkg/log/logfields"
-	"github.com/coding-wepack/carctl/pkg/settings"
-	"github.com/coding-wepack/carctl/pkg/util/logutil"
-	"github.com/coding-wepack/carctl/pkg/util/queueutil"
+
 	"github.com/pkg/errors"
 
 	"github.com/olekukonko/tablewriter"
@@ -72,15 +65,6 @@ type (
 
 		Size int64 `json:"size,omitempty"`
 	}
-
-	Maven struct {
-		group       string
-		artifact    string
-		version     string
-		path        string
-		downloadUrl string
-		size        int64
-	}
 )
 
 type (
@@ -173,69 +157,6 @@ func (r *Repository) ForEach(fn func(group, artifact, version, path, downloadUrl
 	return nil
 }
 
-func (r *Repository) ParallelForEach(fn func(group, artifact, version, path, downloadUrl string, size int64) error) error {
-	if settings.Concurrency <= 1 {
-		return r.ForEach(fn)
-	}
-	mavens := make([]*Maven, 0)
-	for _, g := range r.Groups {
-		for _, a := range g.Artifacts {
-			for _, v := range a.Versions {
-				for _, f := range v.Files {
-					mavens = append(mavens, &Maven{
-						group:       g.Name,
-						artifact:    a.Name,
-						version:     v.Name,
-						path:        f.Path,
-						downloadUrl: f.DownloadUrl,
-						size:        f.Size,
-					})
-				}
-			}
-		}
-	}
-
-	dataChan := make(chan *Maven)
-	go queueutil.Producer(mavens, dataChan)
-
-	if settings.Verbose {
-		log.Debug("parallel foreach do migrate maven artifacts",
-			logfields.Int("file size", len(mavens)),
-			logfields.Int("concurrency", settings.Concurrency))
-	}
-	var wg sync.WaitGroup
-	var goroutineCount int32 = 0
-	errChan := make(chan error)
-	execJobNum := make([]int32, settings.Concurrency)
-	for i := 0; i < settings.Concurrency; i++ {
-		wg.Add(1)
-		execJobNum[i] = 0
-		go queueutil.Consumer(dataChan, errChan, &wg, &execJobNum[i], func(item *Maven) error {
-			atomic.AddInt32(&goroutineCount, 1)
-			err := fn(item.group, item.artifact, item.version, item.path, item.downloadUrl, item.size)
-			atomic.AddInt32(&goroutineCount, -1)
-			if err != nil || err == ErrForEachContinue {
-				return nil
-			}
-			return err
-		})
-	}
-	go logutil.WriteGoroutineFile(&goroutineCount, execJobNum)
-
-	go func() {
-		wg.Wait()
-		// 关闭通道，表示所有的 goroutine 已经执行完毕
-		close(errChan)
-	}()
-
-	for err := range errChan {
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func (r *Repository) AddVersionFile(groupName, artifactName, versionName, filename, filePath string) {
 	r.AddVersionFileBase(groupName, artifactName, versionName, filename, filePath, "", 0)
 }
@@ -0,0 +1,209 @@
+package npm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/coding-wepack/carctl/pkg/util/semver"
+	"github.com/pkg/errors"
+)
+
+// defaultDistTag is the dist-tag of the published versions, as `npm publish` does by default.
+const defaultDistTag = "latest"
+
+// publishDoc is the document PUT to `<registry>/<name>` to publish a version, the same as the npm cli.
+type publishDoc struct {
+	ID          string                            `json:"_id"`
+	Name        string                            `json:"name"`
+	Description string                            `json:"description,omitempty"`
+	DistTags    map[string]string                 `json:"dist-tags"`
+	Versions    map[string]map[string]interface{} `json:"versions"`
+	Readme      string                            `json:"readme,omitempty"`
+	Access      *string                           `json:"access"`
+	Attachments map[string]*attachment            `json:"_attachments"`
+}
+
+type attachment struct {
+	ContentType string `json:"content_type"`
+	Data        string `json:"data"`
+	Length      int    `json:"length"`
+}
+
+// publishTarball publishes the tarball to the destination registry, the tarball is uploaded as it is.
+// The latest dist-tag of the destination is kept if it's a higher version than the tarball.
+func publishTarball(ctx context.Context, auth *config.AuthConfig, body io.Reader) error {
+	tarball, err := io.ReadAll(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read tarball")
+	}
+	registry := settings.GetDstHasSubSlash()
+	doc, err := newPublishDoc(registry, tarball)
+	if err != nil {
+		return err
+	}
+	version := doc.DistTags[defaultDistTag]
+	latest, err := getDistTag(ctx, auth, registry, doc.Name, defaultDistTag)
+	if err != nil {
+		return err
+	}
+	if !isNewerLatest(version, latest) {
+		doc.DistTags[defaultDistTag] = latest
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httputil.DefaultClient.PutJson(ctx, registry+escapeName(doc.Name), bytes.NewReader(data), auth.Username, auth.Password)
+	if err != nil {
+		return errors.Wrapf(err, "failed to publish %s@%s", doc.Name, version)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	return pipeline.CheckPushResponse(resp)
+}
+
+// getDistTag returns the version of the dist-tag of the package in the registry, empty if
+// the package or the tag doesn't exist.
+func getDistTag(ctx context.Context, auth *config.AuthConfig, registry, name, tag string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, registry+escapeName(name), nil)
+	if err != nil {
+		return "", err
+	}
+	// the abbreviated packument has the dist-tags without the metadata of every version
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+	resp, err := httputil.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get dist-tags of %s", name)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Wrapf(httputil.NewStatusError(resp), "failed to get dist-tags of %s", name)
+	}
+
+	var packument struct {
+		DistTags map[string]string `json:"dist-tags"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&packument); err != nil {
+		return "", errors.Wrapf(err, "invalid packument of %s", name)
+	}
+	return packument.DistTags[tag], nil
+}
+
+// isNewerLatest reports whether version should be the latest instead of the current latest,
+// the same as `npm publish` if the current one is not a valid version. A pre-release never
+// replaces a release.
+func isNewerLatest(version, latest string) bool {
+	if latest == "" {
+		return true
+	}
+	v, err := semver.Parse(version)
+	if err != nil {
+		return false
+	}
+	l, err := semver.Parse(latest)
+	if err != nil {
+		return true
+	}
+	if len(v.Pre) != 0 && len(l.Pre) == 0 {
+		return false
+	}
+	return v.Compare(l) > 0
+}
+
+// newPublishDoc returns the publish document of the tarball from its package.json,
+// the keys of settings.DropInvalidKey are dropped from the version.
+func newPublishDoc(registry string, tarball []byte) (*publishDoc, error) {
+	manifest, err := readPackageJson(tarball)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := manifest["name"].(string)
+	version, _ := manifest["version"].(string)
+	if name == "" || version == "" {
+		return nil, errors.New("invalid package.json, the name or version is missing")
+	}
+	for _, key := range settings.DropInvalidKey {
+		delete(manifest, key)
+	}
+
+	sha1Sum := sha1.Sum(tarball)
+	sha512Sum := sha512.Sum512(tarball)
+	unscoped := name[strings.LastIndex(name, "/")+1:]
+	manifest["_id"] = name + "@" + version
+	manifest["dist"] = map[string]interface{}{
+		"shasum":    hex.EncodeToString(sha1Sum[:]),
+		"integrity": "sha512-" + base64.StdEncoding.EncodeToString(sha512Sum[:]),
+		"tarball":   registry + name + "/-/" + unscoped + "-" + version + ".tgz",
+	}
+
+	doc := &publishDoc{
+		ID:       name,
+		Name:     name,
+		DistTags: map[string]string{defaultDistTag: version},
+		Versions: map[string]map[string]interface{}{version: manifest},
+		Attachments: map[string]*attachment{
+			name + "-" + version + ".tgz": {
+				ContentType: "application/octet-stream",
+				Data:        base64.StdEncoding.EncodeToString(tarball),
+				Length:      len(tarball),
+			},
+		},
+	}
+	doc.Description, _ = manifest["description"].(string)
+	doc.Readme, _ = manifest["readme"].(string)
+	return doc, nil
+}
+
+// readPackageJson returns the package.json in the root dir of the tarball, which is `package/` usually.
+func readPackageJson(tarball []byte) (map[string]interface{}, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid tarball")
+	}
+	defer ioutils.QuiteClose(gz)
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("package.json is not found in the tarball")
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid tarball")
+		}
+		name := strings.TrimPrefix(path.Clean(header.Name), "/")
+		if !header.FileInfo().Mode().IsRegular() || path.Base(name) != "package.json" || strings.Count(name, "/") != 1 {
+			continue
+		}
+		manifest := make(map[string]interface{})
+		if err = json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return nil, errors.Wrap(err, "invalid package.json")
+		}
+		return manifest, nil
+	}
+}
+
+// escapeName escapes the `/` of a scoped package name, e.g. `@scope%2fname`.
+func escapeName(name string) string {
+	return strings.Replace(name, "/", "%2f", 1)
+}
@@ -0,0 +1,151 @@
+package npm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestNewPublishDoc(t *testing.T) {
+	defer func(keys []string) { settings.DropInvalidKey = keys }(settings.DropInvalidKey)
+	settings.DropInvalidKey = []string{"private"}
+
+	tarball := newTarball(t, map[string]string{
+		"package/lib/package.json": `{"name":"nested"}`,
+		"package/package.json":     `{"name":"@scope/app","version":"1.2.3-beta.1","description":"demo","private":true,"dependencies":{"lodash":"^4.17.21"}}`,
+	})
+	doc, err := newPublishDoc("https://demo-npm.pkg.coding.net/project/npm/", tarball)
+	require.NoError(t, err)
+
+	assert.Equal(t, "@scope/app", doc.ID)
+	assert.Equal(t, "demo", doc.Description)
+	assert.Equal(t, map[string]string{"latest": "1.2.3-beta.1"}, doc.DistTags)
+	version := doc.Versions["1.2.3-beta.1"]
+	require.NotNil(t, version)
+	assert.Equal(t, "@scope/app@1.2.3-beta.1", version["_id"])
+	assert.NotContains(t, version, "private")
+	assert.Equal(t, map[string]interface{}{"lodash": "^4.17.21"}, version["dependencies"])
+	dist := version["dist"].(map[string]interface{})
+	assert.Equal(t, "https://demo-npm.pkg.coding.net/project/npm/@scope/app/-/app-1.2.3-beta.1.tgz", dist["tarball"])
+	assert.Regexp(t, "^[0-9a-f]{40}$", dist["shasum"])
+	assert.Regexp(t, "^sha512-", dist["integrity"])
+
+	a := doc.Attachments["@scope/app-1.2.3-beta.1.tgz"]
+	require.NotNil(t, a)
+	data, err := base64.StdEncoding.DecodeString(a.Data)
+	require.NoError(t, err)
+	assert.Equal(t, tarball, data, "the tarball is uploaded as it is")
+	assert.Equal(t, len(tarball), a.Length)
+
+	_, err = newPublishDoc("", newTarball(t, map[string]string{"package/index.js": ""}))
+	assert.Error(t, err)
+}
+
+func TestPublishTarball(t *testing.T) {
+	defer func(dst string) { settings.Dst = dst }(settings.Dst)
+
+	var published publishDoc
+	status := http.StatusCreated
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/project/npm/@scope%2fapp", r.URL.RawPath)
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(t, http.MethodPut, r.Method)
+		user, password, _ := r.BasicAuth()
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "pass'word", password)
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &published))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	settings.Dst = server.URL + "/project/npm"
+
+	tarball := newTarball(t, map[string]string{"package/package.json": `{"name":"@scope/app","version":"1.0.0"}`})
+	auth := &config.AuthConfig{Username: "user", Password: "pass'word"}
+	require.NoError(t, publishTarball(context.Background(), auth, bytes.NewReader(tarball)))
+	assert.Equal(t, "@scope/app", published.Name)
+	assert.Contains(t, published.Attachments, "@scope/app-1.0.0.tgz")
+
+	status = http.StatusConflict
+	err := publishTarball(context.Background(), auth, bytes.NewReader(tarball))
+	assert.ErrorIs(t, err, pipeline.ErrFileConflict)
+}
+
+func TestPublishTarball_KeepLatest(t *testing.T) {
+	defer func(dst string) { settings.Dst = dst }(settings.Dst)
+
+	// the registry merges the dist-tags of the published documents
+	var distTags map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			if distTags == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "app", "dist-tags": distTags})
+			return
+		}
+		var doc publishDoc
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&doc))
+		if distTags == nil {
+			distTags = make(map[string]string)
+		}
+		for tag, version := range doc.DistTags {
+			distTags[tag] = version
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	settings.Dst = server.URL + "/project/npm"
+
+	auth := &config.AuthConfig{}
+	for _, version := range []string{"2.0.0", "1.0.0", "2.1.0-rc.1", "1.9.9"} {
+		tarball := newTarball(t, map[string]string{"package/package.json": `{"name":"app","version":"` + version + `"}`})
+		require.NoError(t, publishTarball(context.Background(), auth, bytes.NewReader(tarball)))
+	}
+	assert.Equal(t, "2.0.0", distTags["latest"])
+
+	tarball := newTarball(t, map[string]string{"package/package.json": `{"name":"app","version":"2.0.1"}`})
+	require.NoError(t, publishTarball(context.Background(), auth, bytes.NewReader(tarball)))
+	assert.Equal(t, "2.0.1", distTags["latest"])
+}
+
+func TestIsNewerLatest(t *testing.T) {
+	assert.True(t, isNewerLatest("1.0.0", ""))
+	assert.True(t, isNewerLatest("1.10.0", "1.9.0"))
+	assert.True(t, isNewerLatest("1.0.0", "beta"))
+	assert.True(t, isNewerLatest("2.0.0-rc.2", "2.0.0-rc.1"))
+	assert.False(t, isNewerLatest("1.0.0", "2.0.0"))
+	assert.False(t, isNewerLatest("1.0.0", "1.0.0"))
+	assert.False(t, isNewerLatest("3.0.0-rc.1", "2.0.0"))
+}
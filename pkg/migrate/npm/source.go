@@ -0,0 +1,159 @@
+package npm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/migrate/npm/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/remote"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/sliceutil"
+	"github.com/pkg/errors"
+)
+
+type jfrogSource struct {
+	c *pipeline.Context
+
+	repository *types.Repository
+}
+
+func newJfrogSource(c *pipeline.Context) (pipeline.Source, error) {
+	return &jfrogSource{c: c}, nil
+}
+
+func (s *jfrogSource) List(ctx context.Context) ([]*pipeline.Item, error) {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	repository := s.repositoryName()
+	filesInfo, err := remote.FindFileListFromJfrog(ctx, s.c.SrcUrl, repository, s.c.Journal)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get file list")
+	}
+	if len(filesInfo.Res) == 0 {
+		return nil, errors.Errorf("npm repository: %s file not found, please check your repository or command", repository)
+	}
+
+	files := make([]remote.JfrogFile, 0)
+	for _, f := range filesInfo.Res {
+		if strings.HasSuffix(f.Name, ".tgz") {
+			files = append(files, f)
+		}
+	}
+	log.Infof("remote repository file count: %d", len(files))
+
+	sliceutil.QuickSortReverse(files, func(f remote.JfrogFile) int64 { return f.Size })
+	s.repository, err = GetRepositoryFromJfrogFile(s.c.SrcUrl, files, s.c.ExistsArtifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	jfrogFiles := make(map[string]*remote.JfrogFile, len(filesInfo.Res))
+	for i := range filesInfo.Res {
+		jfrogFiles[filesInfo.Res[i].GetFilePath()] = &filesInfo.Res[i]
+	}
+
+	items := make([]*pipeline.Item, 0, len(s.repository.Files))
+	for _, f := range s.repository.Files {
+		items = append(items, newItem(f, jfrogFiles[f.FilePath]))
+	}
+	return items, nil
+}
+
+// Stream migrates the tarballs page by page while listing the source repository,
+// the tarballs are not sorted by size in this mode.
+func (s *jfrogSource) Stream(ctx context.Context, fn func(item *pipeline.Item) error) error {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	err := remote.EachFileListPageFromJfrog(ctx, s.c.SrcUrl, s.repositoryName(), s.c.Journal, func(page []remote.JfrogFile) error {
+		for i := range page {
+			f := &page[i]
+			if !strings.HasSuffix(f.Name, ".tgz") {
+				continue
+			}
+			file := &types.File{
+				FileName:    f.Name,
+				FilePath:    f.GetFilePath(),
+				DownloadUrl: fmt.Sprintf("%s/%s/%s", settings.GetSrcWithoutSlash(), f.Path, f.Name),
+				Size:        f.Size,
+			}
+			if !settings.Force && !isNeedMigrate(s.c.ExistsArtifacts, file.FilePath) {
+				continue
+			}
+			if err := fn(newItem(file, f)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "failed to get file list")
+}
+
+// repositoryName returns the name of the source repository, which is the second segment of the url path.
+func (s *jfrogSource) repositoryName() string {
+	// 获取仓库名称
+	urlPathStrs := strings.Split(strings.Trim(s.c.SrcUrl.Path, "/"), "/")
+	return urlPathStrs[1]
+}
+
+func newItem(f *types.File, jf *remote.JfrogFile) *pipeline.Item {
+	item := &pipeline.Item{
+		Name:       f.FileName,
+		Path:       f.FilePath,
+		Url:        f.DownloadUrl,
+		Size:       f.Size,
+		Checksum:   pipeline.JfrogChecksum(jf),
+		Modified:   jf.ModifiedTime(),
+		Downloaded: jf.DownloadedTime(),
+	}
+	if pkg, version, ok := parseTarballPath(f.FilePath); ok {
+		item.Package = pkg
+		item.Version = version
+		item.Coordinate = pkg + "@" + version
+	}
+	return item
+}
+
+func (s *jfrogSource) Open(ctx context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	return pipeline.Download(ctx, item.Url)
+}
+
+func (s *jfrogSource) Render(w io.Writer) {
+	s.repository.Render(w)
+}
+
+// sink publishes tarballs to the destination registry.
+type sink struct {
+	auth *config.AuthConfig
+
+	mu sync.Mutex
+	// packages are the locks of the packages, the versions of a package are published one by one,
+	// so that the latest dist-tag is not moved by the concurrent publishes
+	packages map[string]*sync.Mutex
+}
+
+func newSink(c *pipeline.Context) (pipeline.Sink, error) {
+	return &sink{auth: c.Auth, packages: make(map[string]*sync.Mutex)}, nil
+}
+
+func (s *sink) Put(ctx context.Context, item *pipeline.Item, body io.Reader) error {
+	lock := s.lock(item.Package)
+	defer lock.Unlock()
+	return publishTarball(ctx, s.auth, body)
+}
+
+// lock locks the package and returns its lock.
+func (s *sink) lock(pkg string) *sync.Mutex {
+	s.mu.Lock()
+	lock, ok := s.packages[pkg]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.packages[pkg] = lock
+	}
+	s.mu.Unlock()
+	lock.Lock()
+	return lock
+}
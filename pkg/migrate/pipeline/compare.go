@@ -0,0 +1,226 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/api"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	reportutil "github.com/coding-wepack/carctl/pkg/report"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrDrift = errors.New("the destination differs from the source")
+)
+
+// Kinds of the differences between the source and the destination.
+const (
+	DiffMissing  = "missing"
+	DiffExtra    = "extra"
+	DiffMismatch = "checksum-mismatch"
+)
+
+// Difference is a file or an artifact which differs between the source and the destination.
+type Difference struct {
+	Kind    string
+	Name    string
+	Path    string
+	Message string
+}
+
+// comparison is the result of comparing the items of the source with the destination.
+type comparison struct {
+	matched   []*Item
+	unchecked []*Item
+	diffs     []*Difference
+}
+
+// compare lists the source and the destination, and reports the differences.
+func compare(ctx context.Context, c *Context, m *Migration, src Source) (err error) {
+	report := reportutil.NewReport()
+	report.Metadata = &reportutil.Metadata{
+		Type:      c.Type,
+		Src:       settings.Src,
+		SrcType:   settings.SrcType,
+		Dst:       settings.Dst,
+		StartTime: time.Now(),
+		Version:   settings.Version,
+	}
+
+	log.Info("Scanning repository ...")
+	items, err := listAll(ctx, c, m, src)
+	if err != nil {
+		return err
+	}
+	items, filtered := filterItems(c.Filter, items)
+	log.Info("Successfully to scan the repository", logfields.Int("files", len(items)), logfields.Int("filtered", len(filtered)))
+
+	log.Info("Scanning destination repository ...")
+	dst := settings.GetDstWithoutSlash()
+	var result *comparison
+	if m.CompareFiles {
+		files := make(map[string]string)
+		err = api.EachDstFile(ctx, c.Auth, dst, c.Type, func(f *api.RepoFile) error {
+			files[f.Path] = f.Hash
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to find dst repo files")
+		}
+		result = compareFiles(items, files, c.Filter == nil)
+	} else {
+		artifacts, err := api.FindDstExistsArtifacts(ctx, c.Auth, dst, c.Type)
+		if err != nil {
+			return errors.Wrap(err, "failed to find dst repo artifacts")
+		}
+		result = compareArtifacts(items, artifacts, c.Filter == nil)
+	}
+	if c.Filter != nil {
+		log.Info("Extra items of the destination are not reported with filters")
+	}
+
+	for _, item := range result.matched {
+		report.AddSucceededResultV2(item.Name, item.Path, "Verified", item.Size, 0)
+	}
+	for _, item := range result.unchecked {
+		report.AddSkippedResultV2(item.Name, item.Path, "Unable to compare", item.Size, 0)
+	}
+	for _, d := range result.diffs {
+		report.AddFailedResultV2(d.Name, d.Path, d.Message, 0, 0)
+	}
+	report.Metadata.EndTime = time.Now()
+	if settings.ReportFile != "" {
+		if err = report.WriteFile(settings.ReportFile, settings.ReportFormat); err != nil {
+			return err
+		}
+	}
+
+	if len(result.diffs) != 0 {
+		log.Info("Differences:")
+		renderDiffs(c.Out, result.diffs)
+	}
+	counts := make(map[string]int)
+	for _, d := range result.diffs {
+		counts[d.Kind]++
+	}
+	log.Info("End to verify.",
+		logfields.Int("matchedCount", len(result.matched)),
+		logfields.Int("uncheckedCount", len(result.unchecked)),
+		logfields.Int("missingCount", counts[DiffMissing]),
+		logfields.Int("extraCount", counts[DiffExtra]),
+		logfields.Int("mismatchedCount", counts[DiffMismatch]))
+	if len(result.diffs) != 0 {
+		return errors.Wrapf(ErrDrift, "%d differences", len(result.diffs))
+	}
+	return nil
+}
+
+// compareFiles compares the items with the files of the destination keyed by path, whose values are hashes.
+// The files which are not in the source are extra if withExtra.
+func compareFiles(items []*Item, files map[string]string, withExtra bool) *comparison {
+	result := &comparison{}
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.DstPath == "" {
+			result.unchecked = append(result.unchecked, item)
+			continue
+		}
+		seen[item.DstPath] = true
+		digest, ok := files[item.DstPath]
+		switch {
+		case !ok:
+			result.diffs = append(result.diffs, &Difference{
+				Kind: DiffMissing, Name: item.Name, Path: item.DstPath, Message: "not found in the destination",
+			})
+		case digest != "" && item.Checksum.Of(digest) != "" && !item.Checksum.Match(digest):
+			result.diffs = append(result.diffs, &Difference{
+				Kind: DiffMismatch, Name: item.Name, Path: item.DstPath,
+				Message: errors.Wrapf(ErrChecksumMismatch, "source %s, destination %s", item.Checksum.Of(digest), digest).Error(),
+			})
+		default:
+			result.matched = append(result.matched, item)
+		}
+	}
+	if withExtra {
+		for path := range files {
+			if !seen[path] {
+				result.diffs = append(result.diffs, &Difference{
+					Kind: DiffExtra, Name: path, Path: path, Message: "not found in the source",
+				})
+			}
+		}
+	}
+	sortDiffs(result.diffs)
+	return result
+}
+
+// compareArtifacts compares the items with the `package:version` artifacts of the destination.
+// The artifacts which are not in the source are extra if withExtra.
+func compareArtifacts(items []*Item, artifacts map[string]bool, withExtra bool) *comparison {
+	result := &comparison{}
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.Package == "" || item.Version == "" {
+			result.unchecked = append(result.unchecked, item)
+			continue
+		}
+		key := item.Package + ":" + item.Version
+		if seen[key] {
+			// the other files of a version are compared as the artifact
+			continue
+		}
+		seen[key] = true
+		if artifacts[key] {
+			result.matched = append(result.matched, item)
+		} else {
+			result.diffs = append(result.diffs, &Difference{
+				Kind: DiffMissing, Name: key, Path: item.Path, Message: "not found in the destination",
+			})
+		}
+	}
+	if withExtra {
+		for key := range artifacts {
+			if !seen[key] {
+				result.diffs = append(result.diffs, &Difference{
+					Kind: DiffExtra, Name: key, Message: "not found in the source",
+				})
+			}
+		}
+	}
+	sortDiffs(result.diffs)
+	return result
+}
+
+func sortDiffs(diffs []*Difference) {
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Kind != diffs[j].Kind {
+			return diffs[i].Kind < diffs[j].Kind
+		}
+		if diffs[i].Name != diffs[j].Name {
+			return diffs[i].Name < diffs[j].Name
+		}
+		return diffs[i].Path < diffs[j].Path
+	})
+}
+
+func renderDiffs(w io.Writer, diffs []*Difference) {
+	data := make([][]string, len(diffs))
+	for i, d := range diffs {
+		data[i] = []string{d.Kind, d.Name, d.Path, d.Message}
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Difference", "Artifact", "Path", "Message"})
+	table.SetFooter([]string{"Total", strconv.Itoa(len(diffs)), "", ""})
+	table.SetAutoMergeCellsByColumnIndex([]int{0})
+	table.SetRowLine(true)
+	table.AppendBulk(data)
+	table.Render()
+}
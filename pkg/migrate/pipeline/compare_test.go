@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/remote"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareFiles(t *testing.T) {
+	items := []*Item{
+		{Name: "a", DstPath: "a.txt", Checksum: Checksum{Sha1: "da39a3ee5e6b4b0d3255bfef95601890afd80709"}},
+		{Name: "b", DstPath: "b.txt", Checksum: Checksum{Sha1: "da39a3ee5e6b4b0d3255bfef95601890afd80709"}},
+		{Name: "c", DstPath: "c.txt"},
+		{Name: "d", DstPath: "d.txt", Checksum: Checksum{Md5: "d41d8cd98f00b204e9800998ecf8427e"}},
+		{Name: "e"},
+	}
+	files := map[string]string{
+		"a.txt": "DA39A3EE5E6B4B0D3255BFEF95601890AFD80709",
+		"b.txt": "0000000000000000000000000000000000000000",
+		// the sha1 of d is unknown, so it is not compared
+		"d.txt": "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+		"x.txt": "",
+	}
+
+	result := compareFiles(items, files, true)
+	require.Len(t, result.diffs, 3)
+	assert.Equal(t, DiffMismatch, result.diffs[0].Kind)
+	assert.Equal(t, "b.txt", result.diffs[0].Path)
+	assert.Equal(t, &Difference{Kind: DiffExtra, Name: "x.txt", Path: "x.txt", Message: "not found in the source"}, result.diffs[1])
+	assert.Equal(t, DiffMissing, result.diffs[2].Kind)
+	assert.Equal(t, "c.txt", result.diffs[2].Path)
+	assert.Equal(t, []*Item{items[0], items[3]}, result.matched)
+	assert.Equal(t, []*Item{items[4]}, result.unchecked)
+
+	assert.Len(t, compareFiles(items, files, false).diffs, 2)
+}
+
+func TestCompareArtifacts(t *testing.T) {
+	items := []*Item{
+		{Name: "lodash-4.17.21.tgz", Package: "lodash", Version: "4.17.21"},
+		{Name: "requests-2.31.0.tar.gz", Package: "requests", Version: "2.31.0"},
+		{Name: "requests-2.31.0-py3-none-any.whl", Package: "requests", Version: "2.31.0"},
+		{Name: "invalid.tgz"},
+	}
+	artifacts := map[string]bool{"requests:2.31.0": true, "left-pad:1.3.0": true}
+
+	result := compareArtifacts(items, artifacts, true)
+	require.Len(t, result.diffs, 2)
+	assert.Equal(t, &Difference{Kind: DiffExtra, Name: "left-pad:1.3.0", Message: "not found in the source"}, result.diffs[0])
+	assert.Equal(t, DiffMissing, result.diffs[1].Kind)
+	assert.Equal(t, "lodash:4.17.21", result.diffs[1].Name)
+	assert.Equal(t, []*Item{items[1]}, result.matched)
+	assert.Equal(t, []*Item{items[3]}, result.unchecked)
+}
+
+// nexusSource lists the paths of the assets of a Nexus repository.
+type nexusSource struct {
+	srcUrl *url.URL
+}
+
+func (s *nexusSource) List(ctx context.Context) ([]*Item, error) {
+	assets, err := remote.FindAssetsFromNexus[struct{ Path string }](ctx, s.srcUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*Item, 0, len(assets))
+	for _, a := range assets {
+		items = append(items, &Item{Name: a.Path, Path: a.Path, DstPath: a.Path})
+	}
+	return items, nil
+}
+
+func (s *nexusSource) Open(context.Context, *Item) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(nil)), nil
+}
+
+func TestCompareFailedListing(t *testing.T) {
+	defer func(retries int, dst string) {
+		httputil.DefaultRetryPolicy.Retries, settings.Dst = retries, dst
+	}(httputil.DefaultRetryPolicy.Retries, settings.Dst)
+	httputil.DefaultRetryPolicy.Retries = 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/open-api":
+			// the destination has the files of the first page only
+			_, _ = w.Write([]byte(`{"Response":{"Data":{"InstanceSet":[{"Path":"a.txt"}]}}}`))
+		case r.URL.Query().Get("continuationToken") == "":
+			_, _ = w.Write([]byte(`{"items":[{"path":"a.txt"}],"continuationToken":"page2"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	srcUrl, _ := url.Parse(server.URL + "/repository/raw/")
+	settings.Dst = server.URL + "/project/raw/"
+
+	c := &Context{Type: "generic", Out: io.Discard, Auth: &config.AuthConfig{}, SrcUrl: srcUrl}
+	err := compare(context.Background(), c, &Migration{CompareFiles: true}, &nexusSource{srcUrl: srcUrl})
+	require.Error(t, err, "a partial listing must not be reported as no drift")
+	assert.False(t, errors.Is(err, ErrDrift))
+}
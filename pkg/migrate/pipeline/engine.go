@@ -0,0 +1,473 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/migrate/journal"
+	reportutil "github.com/coding-wepack/carctl/pkg/report"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/coding-wepack/carctl/pkg/util/logutil"
+	"github.com/coding-wepack/carctl/pkg/util/queueutil"
+	"github.com/coding-wepack/carctl/pkg/util/ratelimit"
+	"github.com/coding-wepack/carctl/pkg/util/signalutil"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/vbauerster/mpb/v7"
+	"github.com/vbauerster/mpb/v7/decor"
+)
+
+// Run scans the source and pushes every item to the sink.
+// The sink may be nil in dry-run mode.
+func Run(ctx context.Context, c *Context, src Source, sink Sink) (err error) {
+	w := c.Out
+	report := reportutil.NewReport()
+	report.Metadata = &reportutil.Metadata{
+		Type:      c.Type,
+		Src:       settings.Src,
+		SrcType:   settings.SrcType,
+		Dst:       settings.Dst,
+		StartTime: time.Now(),
+		Version:   settings.Version,
+	}
+	if settings.ReportFile != "" && !settings.DryRun {
+		defer func() {
+			report.Metadata.EndTime = time.Now()
+			if wErr := report.WriteFile(settings.ReportFile, settings.ReportFormat); wErr != nil {
+				log.Error("failed to write report", logfields.String("file", settings.ReportFile), logfields.Error(wErr))
+				if err == nil {
+					err = wErr
+				}
+			}
+		}()
+	}
+
+	streamer, stream := src.(Streamer)
+	stream = stream && !settings.DryRun
+
+	var produce producer
+	var total int
+	var listed int64
+	if stream {
+		// the items are migrated while listing, the total is updated by the producer
+		log.Info("Scanning and migrating repository ...")
+	} else {
+		log.Info("Scanning repository ...")
+		items, err := src.List(ctx)
+		if err != nil {
+			return err
+		}
+		log.Info("Successfully to scan the repository", logfields.Int("files", len(items)))
+		items, filtered := filterItems(c.Filter, items)
+		if c.Filter != nil {
+			log.Info("Filter the repository", logfields.Int("selected", len(items)), logfields.Int("filtered", len(filtered)))
+		}
+		if len(filtered) != 0 && (settings.Verbose || settings.DryRun) {
+			log.Info("Filtered Info:")
+			renderFiltered(w, filtered)
+		}
+		items = skipDone(c.Journal, items)
+		if len(items) == 0 {
+			log.Warn("no files found or files have been migrated, no need to migrate")
+			return nil
+		}
+		if settings.Verbose || settings.DryRun {
+			log.Info("Repository Info:")
+			// the repository of a source contains the filtered items
+			if r, ok := src.(Renderer); ok && len(filtered) == 0 {
+				r.Render(w)
+			} else {
+				renderItems(w, items)
+			}
+		}
+		if settings.DryRun {
+			return nil
+		}
+		total = len(items)
+		produce = produceItems(items)
+	}
+
+	// Progress Bar
+	// initialize progress container, with custom width
+	p := mpb.New(mpb.WithWidth(80))
+	bar := newProgressBar(p, total)
+	workers := newWorkerBars(p, settings.Concurrency)
+
+	if stream {
+		produce = func(ctx context.Context, out chan<- *Item) error {
+			defer close(out)
+			return streamer.Stream(ctx, func(item *Item) error {
+				if ok, reason := c.Filter.Match(item); !ok {
+					if settings.Verbose {
+						log.Debug("filtered", logfields.String("item", item.Name), logfields.String("reason", reason))
+					}
+					return nil
+				}
+				if c.Journal.Done(item.Key()) {
+					return nil
+				}
+				bar.SetTotal(atomic.AddInt64(&listed, 1), false)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case out <- item:
+					return nil
+				}
+			})
+		}
+	}
+
+	log.Info("Begin to migrate ...")
+	start := time.Now()
+
+	var mu sync.Mutex
+	var migrated []*Item
+	if settings.Verbose {
+		defer func() {
+			log.Info("Migrate result:")
+			report.RenderV2(w)
+		}()
+	}
+
+	err = parallelForEach(signalutil.Drain(ctx), produce, func(worker int, item *Item) error {
+		defer bar.Increment()
+		workers.start(worker, item)
+		defer workers.finish(worker)
+		begin := time.Now()
+		err := transfer(ctx, c, src, sink, item)
+		useTime := time.Since(begin).Milliseconds()
+		if settings.Sleep > 0 {
+			defer time.Sleep(settings.Sleep)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if c.Observe != nil {
+			c.Observe(item, err)
+		}
+		switch {
+		case err == ErrFileConflict:
+			report.AddSkippedResultV2(item.Name, item.Url, "409 Conflict", item.Size, useTime)
+			return c.Journal.Record(item.Key(), item.Name, journal.StatusSkipped, "409 Conflict")
+		case err != nil:
+			report.AddFailedResultV2(item.Name, item.Url, err.Error(), item.Size, useTime)
+			if jErr := c.Journal.Record(item.Key(), item.Name, journal.StatusFailed, err.Error()); jErr != nil {
+				return jErr
+			}
+			if settings.FailFast {
+				return errors.Wrapf(err, "failed to migrate %s", item.Path)
+			}
+			return nil
+		default:
+			report.AddSucceededResultV2(item.Name, item.Url, "Succeeded", item.Size, useTime)
+			migrated = append(migrated, item)
+			return c.Journal.Record(item.Key(), item.Name, journal.StatusSucceeded, "")
+		}
+	})
+	workers.close()
+	if err != nil {
+		bar.Abort(false)
+		p.Wait()
+		if err == errInterrupted && c.Journal != nil {
+			log.Warn("Migration is interrupted, use --resume to continue it",
+				logfields.Int("migratedCount", report.TotalCount()),
+				logfields.String("journal", c.Journal.Path()))
+		} else if err == errInterrupted {
+			log.Warn("Migration is interrupted", logfields.Int("migratedCount", report.TotalCount()))
+		}
+		return err
+	}
+
+	// wait for our bar to complete and flush
+	if stream {
+		bar.SetTotal(-1, true)
+	}
+	p.Wait()
+
+	if stream && listed == 0 {
+		log.Warn("no files found or files have been migrated, no need to migrate")
+		return nil
+	}
+
+	if settings.Verify {
+		if err = verifyDst(ctx, c, report, migrated); err != nil {
+			return err
+		}
+	}
+
+	log.Info("End to migrate.",
+		logfields.Duration("duration", time.Since(start)),
+		logfields.Int("succeededCount", len(report.SucceededResult)),
+		logfields.Int("skippedCount", len(report.SkippedResult)),
+		logfields.Int("failedCount", len(report.FailedResult)))
+
+	return nil
+}
+
+// producer sends the items to migrate to out until ctx is done, and then closes out.
+type producer func(ctx context.Context, out chan<- *Item) error
+
+// produceItems returns a producer of the listed items.
+func produceItems(items []*Item) producer {
+	return func(ctx context.Context, out chan<- *Item) error {
+		queueutil.Producer(ctx, items, out)
+		return nil
+	}
+}
+
+var errInterrupted = errors.New("migration is interrupted")
+
+// skipDone removes the items which have been migrated by a previous run of the journal.
+func skipDone(j *journal.Journal, items []*Item) []*Item {
+	if j == nil {
+		return items
+	}
+	result := make([]*Item, 0, len(items))
+	for _, item := range items {
+		if !j.Done(item.Key()) {
+			result = append(result, item)
+		}
+	}
+	if done := len(items) - len(result); done > 0 {
+		log.Info("Skip items which have been migrated according to the journal", logfields.Int("count", done))
+	}
+	return result
+}
+
+// transfer migrates a single item, retrying on transient failures with httputil.DefaultRetryPolicy.
+// The item is the only layer of the retries, the requests of an attempt are not retried by themselves.
+func transfer(ctx context.Context, c *Context, src Source, sink Sink, item *Item) error {
+	attemptCtx := httputil.WithoutRetry(ctx)
+	return httputil.DefaultRetryPolicy.Do(ctx, func() error {
+		return transferOnce(attemptCtx, c, src, sink, item)
+	}, func(err error, delay time.Duration) {
+		log.Warnf("failed to migrate %s, retry in %s: %s", item.Path, delay, err)
+	})
+}
+
+func transferOnce(ctx context.Context, c *Context, src Source, sink Sink, item *Item) error {
+	if copier, ok := sink.(Copier); ok {
+		return copier.Copy(ctx, item)
+	}
+
+	rc, err := src.Open(ctx, item)
+	if err != nil {
+		return err
+	}
+	defer ioutils.QuiteClose(rc)
+
+	// the content is streamed from the source to the sink, so limiting the read limits both
+	body := ratelimit.NewReader(ctx, rc, c.Bandwidth)
+	if !settings.Verify {
+		return sink.Put(ctx, item, body)
+	}
+
+	// hash the content while uploading, and compare with the checksums of the source
+	d := newDigestReader(body)
+	if err = sink.Put(ctx, item, d); err != nil {
+		return err
+	}
+	item.digest = d.Checksum()
+	return item.Checksum.Verify(item.digest)
+}
+
+// parallelForEach calls fn with every item of produce by settings.Concurrency workers,
+// worker is the index of the worker which calls fn.
+// When ctx is done, no more items are scheduled and errInterrupted is returned after
+// the in-flight ones finish. The first error of fn stops the scheduling the same way.
+func parallelForEach(ctx context.Context, produce producer, fn func(worker int, item *Item) error) error {
+	concurrency := settings.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	scheduleCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	dataChan := make(chan *Item)
+	produceErr := make(chan error, 1)
+	go func() {
+		produceErr <- produce(scheduleCtx, dataChan)
+	}()
+
+	if settings.Verbose {
+		log.Debug("parallel foreach do migrate artifacts",
+			logfields.Int("concurrency", concurrency))
+	}
+	var wg sync.WaitGroup
+	var goroutineCount int32 = 0
+	errChan := make(chan error)
+	execJobNum := make([]int32, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		worker := i
+		go queueutil.Consumer(scheduleCtx, dataChan, errChan, &wg, &execJobNum[i], func(item *Item) error {
+			atomic.AddInt32(&goroutineCount, 1)
+			defer atomic.AddInt32(&goroutineCount, -1)
+			return fn(worker, item)
+		})
+	}
+
+	if settings.Verbose {
+		go logutil.WriteGoroutineFile(&goroutineCount, execJobNum)
+	}
+
+	go func() {
+		wg.Wait()
+		// 关闭通道，表示所有的 goroutine 已经执行完毕
+		close(errChan)
+	}()
+
+	// wait for the in-flight items even if an error occurs, so that their results are reported
+	var firstErr error
+	for err := range errChan {
+		if err != nil && firstErr == nil {
+			firstErr = err
+			stop()
+		}
+	}
+	// the consumers have exited, stop the producer if it is still listing
+	stop()
+	listErr := <-produceErr
+	switch {
+	case firstErr != nil:
+		return firstErr
+	case ctx.Err() != nil:
+		return errInterrupted
+	default:
+		return listErr
+	}
+}
+
+func newProgressBar(p *mpb.Progress, total int) *mpb.Bar {
+	const pbName = "Pushing:"
+	// adding a single bar, which will inherit container's width
+	return p.Add(
+		int64(total),
+		mpb.NewBarFiller(mpb.BarStyle()),
+		mpb.PrependDecorators(
+			// display our name with one space on the right
+			decor.Name(pbName, decor.WC{W: len(pbName) + 1, C: decor.DidentRight}),
+			// replace ETA decorator with "done" message, OnComplete event
+			decor.OnComplete(
+				decor.AverageETA(decor.ET_STYLE_GO, decor.WC{W: 4}), "Done!",
+			),
+		),
+		mpb.AppendDecorators(
+			// counter
+			decor.Counters(0, "%d / %d  "),
+			// percentage
+			decor.Percentage(),
+		),
+	)
+}
+
+// workerBars show the item which every worker is migrating, and how many items it has migrated.
+// It is nil without concurrency, and all the methods are nil-safe.
+type workerBars struct {
+	bars    []*mpb.Bar
+	current []atomic.Value
+	done    []int64
+}
+
+func newWorkerBars(p *mpb.Progress, concurrency int) *workerBars {
+	if concurrency <= 1 {
+		return nil
+	}
+	w := &workerBars{
+		bars:    make([]*mpb.Bar, concurrency),
+		current: make([]atomic.Value, concurrency),
+		done:    make([]int64, concurrency),
+	}
+	for i := range w.bars {
+		i := i
+		name := fmt.Sprintf("Worker %d:", i+1)
+		w.current[i].Store("idle")
+		// a bar without filler and total is only a status line, it is dropped by close
+		w.bars[i] = p.Add(0, nil,
+			mpb.PrependDecorators(
+				decor.Name(name, decor.WC{W: len(name) + 1, C: decor.DidentRight}),
+				decor.Any(func(decor.Statistics) string {
+					return fmt.Sprintf("%d done  %s", atomic.LoadInt64(&w.done[i]), w.current[i].Load())
+				}),
+			),
+		)
+	}
+	return w
+}
+
+func (w *workerBars) start(worker int, item *Item) {
+	if w == nil {
+		return
+	}
+	status := "migrating " + truncate(item.Name, 60)
+	if item.Size > 0 {
+		status += fmt.Sprintf(" (%.2f MiB)", float64(item.Size)/1024/1024)
+	}
+	w.current[worker].Store(status)
+}
+
+func (w *workerBars) finish(worker int) {
+	if w == nil {
+		return
+	}
+	atomic.AddInt64(&w.done[worker], 1)
+	w.current[worker].Store("idle")
+}
+
+func (w *workerBars) close() {
+	if w == nil {
+		return
+	}
+	for _, bar := range w.bars {
+		bar.Abort(true)
+	}
+}
+
+// truncate keeps the tail of s, which is the most specific part of a name.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n+3:]
+}
+
+func renderFiltered(w io.Writer, filtered []*filteredItem) {
+	data := make([][]string, len(filtered))
+	for i, f := range filtered {
+		data[i] = []string{f.Item.Name, f.Item.Path, f.Reason}
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Artifact", "SrcPath", "Reason"})
+	table.SetFooter([]string{"Filtered", strconv.Itoa(len(filtered)), ""})
+	table.SetRowLine(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func renderItems(w io.Writer, items []*Item) {
+	data := make([][]string, len(items))
+	var sum float64 = 0
+	for i, item := range items {
+		size := float64(item.Size) / 1024 / 1024
+		data[i] = []string{item.Name, item.Path, fmt.Sprintf("%f", size)}
+		sum += size
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Artifact", "SrcPath", "Size(Mb)"})
+	table.SetFooter([]string{"Total", strconv.Itoa(len(items)), fmt.Sprintf("%f", sum)})
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+	table.AppendBulk(data)
+	table.Render()
+}
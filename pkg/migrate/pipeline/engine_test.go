@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func testItems(n int) []*Item {
+	items := make([]*Item, n)
+	for i := range items {
+		items[i] = &Item{Name: "item"}
+	}
+	return items
+}
+
+func TestParallelForEachDrain(t *testing.T) {
+	settings.Concurrency = 4
+	defer func() { settings.Concurrency = 1 }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var started, finished int32
+	err := parallelForEach(ctx, produceItems(testItems(100)), func(_ int, item *Item) error {
+		if atomic.AddInt32(&started, 1) == 4 {
+			cancel()
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&finished, 1)
+		return nil
+	})
+	assert.Equal(t, errInterrupted, err)
+	// the in-flight items are finished, and no more items are scheduled
+	assert.Equal(t, started, finished)
+	assert.Less(t, started, int32(100))
+}
+
+func TestParallelForEachFailFast(t *testing.T) {
+	settings.Concurrency = 4
+	defer func() { settings.Concurrency = 1 }()
+
+	failed := errors.New("failed")
+	var started, finished int32
+	err := parallelForEach(context.Background(), produceItems(testItems(100)), func(_ int, item *Item) error {
+		defer atomic.AddInt32(&finished, 1)
+		if atomic.AddInt32(&started, 1) == 2 {
+			return failed
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	assert.Equal(t, failed, err)
+	assert.Equal(t, started, finished)
+	assert.Less(t, started, int32(100))
+}
+
+func TestForEachDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var count int
+	err := parallelForEach(ctx, produceItems(testItems(10)), func(_ int, item *Item) error {
+		if count++; count == 3 {
+			cancel()
+		}
+		return nil
+	})
+	assert.Equal(t, errInterrupted, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestParallelForEachProduceError(t *testing.T) {
+	settings.Concurrency = 4
+	defer func() { settings.Concurrency = 1 }()
+
+	listErr := errors.New("failed to list")
+	var count int32
+	err := parallelForEach(context.Background(), func(ctx context.Context, out chan<- *Item) error {
+		defer close(out)
+		for _, item := range testItems(5) {
+			out <- item
+		}
+		return listErr
+	}, func(_ int, item *Item) error {
+		atomic.AddInt32(&count, 1)
+		return nil
+	})
+	assert.Equal(t, listErr, err)
+	// the items listed before the error are migrated
+	assert.Equal(t, int32(5), count)
+}
+
+func TestParallelForEachWorkers(t *testing.T) {
+	settings.Concurrency = 3
+	defer func() { settings.Concurrency = 1 }()
+
+	busy := make([]int32, settings.Concurrency)
+	var count int32
+	err := parallelForEach(context.Background(), produceItems(testItems(30)), func(worker int, item *Item) error {
+		// a worker migrates one item at a time
+		if !atomic.CompareAndSwapInt32(&busy[worker], 0, 1) {
+			return errors.Errorf("worker %d is busy", worker)
+		}
+		defer atomic.StoreInt32(&busy[worker], 0)
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&count, 1)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(30), count)
+}
+
+// downloadSource downloads the items from their urls.
+type downloadSource struct{}
+
+func (downloadSource) List(context.Context) ([]*Item, error) { return nil, nil }
+
+func (downloadSource) Open(ctx context.Context, item *Item) (io.ReadCloser, error) {
+	return Download(ctx, item.Url)
+}
+
+type discardSink struct{}
+
+func (discardSink) Put(_ context.Context, _ *Item, body io.Reader) error {
+	_, err := io.Copy(io.Discard, body)
+	return err
+}
+
+func TestTransferRetries(t *testing.T) {
+	defer func(retries int, backoff time.Duration) {
+		httputil.DefaultRetryPolicy.Retries, httputil.DefaultRetryPolicy.Backoff = retries, backoff
+	}(httputil.DefaultRetryPolicy.Retries, httputil.DefaultRetryPolicy.Backoff)
+	httputil.DefaultRetryPolicy.Retries, httputil.DefaultRetryPolicy.Backoff = 2, 0
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := transfer(context.Background(), &Context{}, downloadSource{}, discardSink{}, &Item{Path: "a.jar", Url: server.URL + "/a.jar"})
+	assert.Error(t, err)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls), "the download is retried by the transfer only")
+}
@@ -0,0 +1,266 @@
+package pipeline
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// regexpPrefix marks a filter pattern as a regular expression, otherwise it is a glob.
+const regexpPrefix = "re:"
+
+// Filter selects items by the --include and --exclude patterns,
+// which are matched on the path and the coordinate of an item.
+//
+// A glob supports `*` and `?` which don't match `/`, `**` which matches anything and `[...]`,
+// it must match the whole value, and a glob without `/` also matches the last path element.
+// A pattern prefixed by `re:` is a regular expression, which matches any part of the value.
+//
+// The time window selects items by the Modified and Downloaded times of the source,
+// an item whose time is unknown is filtered.
+type Filter struct {
+	includes []*pattern
+	excludes []*pattern
+
+	modifiedAfter   time.Time
+	modifiedBefore  time.Time
+	downloadedSince time.Time
+}
+
+type pattern struct {
+	raw  string
+	re   *regexp.Regexp
+	base bool
+}
+
+// NewFilter compiles the patterns, nil is returned if there is no pattern.
+func NewFilter(includes, excludes []string) (*Filter, error) {
+	if len(includes) == 0 && len(excludes) == 0 {
+		return nil, nil
+	}
+	f := &Filter{}
+	var err error
+	if f.includes, err = compilePatterns("include", includes); err != nil {
+		return nil, err
+	}
+	if f.excludes, err = compilePatterns("exclude", excludes); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// WithTimeWindow returns a Filter which also selects items by the times, which are dates, RFC3339 times,
+// or durations before now like 720h and 30d. f is returned if all the times are empty.
+func (f *Filter) WithTimeWindow(modifiedAfter, modifiedBefore, downloadedSince string) (*Filter, error) {
+	if modifiedAfter == "" && modifiedBefore == "" && downloadedSince == "" {
+		return f, nil
+	}
+	nf := &Filter{}
+	if f != nil {
+		*nf = *f
+	}
+	now := time.Now()
+	var err error
+	if nf.modifiedAfter, err = ParseTime(modifiedAfter, now); err != nil {
+		return nil, errors.Wrapf(err, "invalid --modified-after=%s", modifiedAfter)
+	}
+	if nf.modifiedBefore, err = ParseTime(modifiedBefore, now); err != nil {
+		return nil, errors.Wrapf(err, "invalid --modified-before=%s", modifiedBefore)
+	}
+	if nf.downloadedSince, err = ParseTime(downloadedSince, now); err != nil {
+		return nil, errors.Wrapf(err, "invalid --downloaded-since=%s", downloadedSince)
+	}
+	if !nf.modifiedAfter.IsZero() && !nf.modifiedBefore.IsZero() && !nf.modifiedAfter.Before(nf.modifiedBefore) {
+		return nil, errors.Errorf("--modified-after=%s is not before --modified-before=%s", modifiedAfter, modifiedBefore)
+	}
+	return nf, nil
+}
+
+// ParseTime parses a date like 2006-01-02, a RFC3339 time, or a duration before now like 720h and 30d.
+// The zero time is returned for an empty string.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, errors.Errorf("invalid days: %s", s)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, errors.Errorf("%s is not a date, a RFC3339 time or a duration", s)
+	}
+	return now.Add(-d), nil
+}
+
+func compilePatterns(flag string, raws []string) ([]*pattern, error) {
+	patterns := make([]*pattern, 0, len(raws))
+	for _, raw := range raws {
+		p, err := compilePattern(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid --%s=%s", flag, raw)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func compilePattern(raw string) (*pattern, error) {
+	if expr, ok := strings.CutPrefix(raw, regexpPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		return &pattern{raw: raw, re: re}, nil
+	}
+	re, err := regexp.Compile(globToRegexp(raw))
+	if err != nil {
+		return nil, err
+	}
+	return &pattern{raw: raw, re: re, base: !strings.Contains(raw, "/")}, nil
+}
+
+// globToRegexp converts a glob to an anchored regular expression.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(regexp.QuoteMeta(glob[i:]))
+				i = len(glob)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func (p *pattern) match(value string) bool {
+	if value == "" {
+		return false
+	}
+	if p.re.MatchString(value) {
+		return true
+	}
+	return p.base && strings.Contains(value, "/") && p.re.MatchString(path.Base(value))
+}
+
+// Match reports whether the item is selected, otherwise why it is filtered.
+// A nil Filter selects every item.
+func (f *Filter) Match(item *Item) (ok bool, reason string) {
+	if f == nil {
+		return true, ""
+	}
+	for _, p := range f.excludes {
+		if p.match(item.Path) || p.match(item.Coordinate) {
+			return false, fmt.Sprintf("excluded by --exclude=%s", p.raw)
+		}
+	}
+	if len(f.includes) != 0 && !f.matchIncludes(item) {
+		return false, "not matched by --include"
+	}
+	return f.matchTimeWindow(item)
+}
+
+func (f *Filter) matchIncludes(item *Item) bool {
+	for _, p := range f.includes {
+		if p.match(item.Path) || p.match(item.Coordinate) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) matchTimeWindow(item *Item) (ok bool, reason string) {
+	if !f.modifiedAfter.IsZero() || !f.modifiedBefore.IsZero() {
+		switch {
+		case item.Modified.IsZero():
+			return false, "modified time is unknown"
+		case !f.modifiedAfter.IsZero() && !item.Modified.After(f.modifiedAfter):
+			return false, fmt.Sprintf("modified at %s, not after --modified-after", formatTime(item.Modified))
+		case !f.modifiedBefore.IsZero() && !item.Modified.Before(f.modifiedBefore):
+			return false, fmt.Sprintf("modified at %s, not before --modified-before", formatTime(item.Modified))
+		}
+	}
+	if !f.downloadedSince.IsZero() {
+		switch {
+		case item.Downloaded == nil:
+			return false, "downloaded time is unknown"
+		case item.Downloaded.IsZero():
+			return false, "never downloaded"
+		case item.Downloaded.Before(f.downloadedSince):
+			return false, fmt.Sprintf("downloaded at %s, not since --downloaded-since", formatTime(*item.Downloaded))
+		}
+	}
+	return true, ""
+}
+
+// NexusDownloaded returns the Item.Downloaded of the lastDownloaded of a Nexus asset,
+// which is null if the asset has never been downloaded.
+func NexusDownloaded(lastDownloaded *time.Time) *time.Time {
+	if lastDownloaded == nil {
+		return &time.Time{}
+	}
+	return lastDownloaded
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format(time.DateTime)
+}
+
+// filteredItem is an item which is not migrated because of the filter.
+type filteredItem struct {
+	Item   *Item
+	Reason string
+}
+
+// filterItems returns the selected items and the filtered ones.
+func filterItems(f *Filter, items []*Item) (selected []*Item, filtered []*filteredItem) {
+	if f == nil {
+		return items, nil
+	}
+	selected = make([]*Item, 0, len(items))
+	for _, item := range items {
+		if ok, reason := f.Match(item); ok {
+			selected = append(selected, item)
+		} else {
+			filtered = append(filtered, &filteredItem{Item: item, Reason: reason})
+		}
+	}
+	return
+}
@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterMatch(t *testing.T) {
+	maven := &Item{Path: "com/example/demo/1.0-SNAPSHOT/demo-1.0-SNAPSHOT.jar", Coordinate: "com.example:demo:1.0-SNAPSHOT"}
+	npm := &Item{Path: "lodash/-/lodash-4.17.21.tgz", Coordinate: "lodash@4.17.21"}
+	docker := &Item{Path: "library/nginx/1.25", Coordinate: "library/nginx:1.25"}
+	generic := &Item{Path: "dir/sub/file.txt"}
+
+	tests := []struct {
+		name     string
+		includes []string
+		excludes []string
+		item     *Item
+		ok       bool
+		reason   string
+	}{
+		{"no patterns", nil, nil, generic, true, ""},
+		{"include path glob", []string{"com/example/**"}, nil, maven, true, ""},
+		{"include coordinate glob", []string{"com.example:*"}, nil, maven, true, ""},
+		{"star does not match slash", []string{"dir/*"}, nil, generic, false, "not matched by --include"},
+		{"double star matches slash", []string{"dir/**"}, nil, generic, true, ""},
+		{"glob without slash matches base name", []string{"*.txt"}, nil, generic, true, ""},
+		{"include regex", []string{`re:^lodash@4\.`}, nil, npm, true, ""},
+		{"include regex not matched", []string{`re:^lodash@3\.`}, nil, npm, false, "not matched by --include"},
+		{"exclude wins", []string{"**"}, []string{"*-SNAPSHOT*"}, maven, false, "excluded by --exclude=*-SNAPSHOT*"},
+		{"exclude docker tag", nil, []string{"library/nginx:1.?5"}, docker, false, "excluded by --exclude=library/nginx:1.?5"},
+		{"char class", []string{"library/nginx:1.[0-9][!0-4]"}, nil, docker, true, ""},
+		{"exclude not matched", nil, []string{"re:SNAPSHOT"}, npm, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.includes, tt.excludes)
+			require.NoError(t, err)
+			ok, reason := f.Match(tt.item)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func TestNewFilterInvalid(t *testing.T) {
+	_, err := NewFilter([]string{"re:("}, nil)
+	assert.ErrorContains(t, err, "invalid --include=re:(")
+}
+
+func TestFilterItems(t *testing.T) {
+	f, err := NewFilter(nil, []string{"*.md"})
+	require.NoError(t, err)
+	items := []*Item{{Path: "a/README.md"}, {Path: "a/b.bin"}}
+	selected, filtered := filterItems(f, items)
+	assert.Equal(t, []*Item{items[1]}, selected)
+	if assert.Len(t, filtered, 1) {
+		assert.Equal(t, items[0], filtered[0].Item)
+		assert.Equal(t, "excluded by --exclude=*.md", filtered[0].Reason)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2023, 6, 30, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s    string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"2023-01-02T15:04:05Z", time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2023-01-02", time.Date(2023, 1, 2, 0, 0, 0, 0, time.Local)},
+		{"30d", now.AddDate(0, 0, -30)},
+		{"36h", now.Add(-36 * time.Hour)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.s, now)
+		if assert.NoError(t, err, tt.s) {
+			assert.True(t, tt.want.Equal(got), "%s: want %s, got %s", tt.s, tt.want, got)
+		}
+	}
+
+	for _, s := range []string{"yesterday", "-3d", "-1h", "2023-13-01"} {
+		_, err := ParseTime(s, now)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestFilterTimeWindow(t *testing.T) {
+	f, err := (*Filter)(nil).WithTimeWindow("2023-01-01T00:00:00Z", "2023-07-01T00:00:00Z", "2023-06-01T00:00:00Z")
+	require.NoError(t, err)
+
+	date := func(month time.Month) *time.Time {
+		t := time.Date(2023, month, 15, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	tests := []struct {
+		name   string
+		item   *Item
+		ok     bool
+		reason string
+	}{
+		{"in window", &Item{Modified: *date(3), Downloaded: date(6)}, true, ""},
+		{"modified unknown", &Item{Downloaded: date(6)}, false, "modified time is unknown"},
+		{"modified too early", &Item{Modified: time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC), Downloaded: date(6)}, false, ""},
+		{"modified too late", &Item{Modified: *date(7), Downloaded: date(6)}, false, ""},
+		{"downloaded unknown", &Item{Modified: *date(3)}, false, "downloaded time is unknown"},
+		{"never downloaded", &Item{Modified: *date(3), Downloaded: &time.Time{}}, false, "never downloaded"},
+		{"downloaded too early", &Item{Modified: *date(3), Downloaded: date(5)}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := f.Match(tt.item)
+			assert.Equal(t, tt.ok, ok)
+			if tt.reason != "" {
+				assert.Equal(t, tt.reason, reason)
+			} else if !tt.ok {
+				assert.NotEmpty(t, reason)
+			}
+		})
+	}
+
+	_, err = f.WithTimeWindow("2023-07-01", "2023-01-01", "")
+	assert.ErrorContains(t, err, "is not before")
+
+	same, err := f.WithTimeWindow("", "", "")
+	require.NoError(t, err)
+	assert.Same(t, f, same)
+}
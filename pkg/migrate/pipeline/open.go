@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/pkg/errors"
+)
+
+// Download opens the content of downloadUrl with the credentials of the source repository.
+func Download(ctx context.Context, downloadUrl string) (io.ReadCloser, error) {
+	resp, err := httputil.DefaultClient.GetWithAuth(ctx, downloadUrl, settings.SrcUsername, settings.SrcPassword)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download from %s", downloadUrl)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer ioutils.QuiteClose(resp.Body)
+		return nil, errors.Wrapf(httputil.NewStatusError(resp), "failed to download from %s", downloadUrl)
+	}
+	return resp.Body, nil
+}
+
+// OpenFile opens the content of a local file.
+func OpenFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s", path)
+	}
+	return f, nil
+}
+
+// CheckPushResponse converts an unexpected push response to an error.
+func CheckPushResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return ErrFileConflict
+	}
+	return httputil.NewStatusError(resp)
+}
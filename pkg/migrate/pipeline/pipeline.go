@@ -0,0 +1,479 @@
+// Package pipeline is the migration engine shared by every artifact type.
+//
+// A Source lists the artifacts of a legacy repository and opens their content,
+// a Sink publishes them to a CODING Artifact Repository, and the engine takes
+// care of authorization, exists checks, concurrency, retries, reporting and dry-run.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/action"
+	"github.com/coding-wepack/carctl/pkg/api"
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/migrate/journal"
+	reportutil "github.com/coding-wepack/carctl/pkg/report"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/diskset"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ratelimit"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SrcTypeLocal is the source type used when --src is a local path.
+	SrcTypeLocal = "local"
+
+	// SrcTypeNexus is the default source type of remote repositories.
+	SrcTypeNexus = "nexus"
+
+	// SrcTypeJfrog is the source type of JFrog Artifactory repositories.
+	SrcTypeJfrog = "jfrog"
+
+	// SrcTypeRegistry is the source type of docker registries of the Registry v2 api.
+	SrcTypeRegistry = "registry"
+)
+
+var (
+	ErrFileConflict = errors.New("failed to put file: 409 conflict")
+)
+
+type (
+	// Item is a single file or image to be migrated.
+	Item struct {
+		// Name is the display name of the artifact, e.g. group:artifact:version
+		Name string `json:"name"`
+
+		// Package is the package name of the artifact, e.g. group:artifact, or a npm package name
+		Package string `json:"package,omitempty"`
+
+		// Version is the version of the artifact
+		Version string `json:"version,omitempty"`
+
+		// Path is the file path relative to the root of the repository
+		Path string `json:"path"`
+
+		// Coordinate is the type specific coordinate matched by the filters,
+		// e.g. Maven group:artifact:version, npm name@version, PyPI name==version, Docker repo:tag
+		Coordinate string `json:"coordinate,omitempty"`
+
+		// Url is where the content is read from, a download url or a local file path
+		Url string `json:"url,omitempty"`
+
+		// Size is the file size in bytes, 0 if unknown
+		Size int64 `json:"size,omitempty"`
+
+		// Checksum is the checksums provided by the source
+		Checksum Checksum `json:"checksum,omitempty"`
+
+		// Modified is the last modified time in the source, zero if unknown
+		Modified time.Time `json:"modified,omitempty"`
+
+		// Downloaded is the last downloaded time in the source, nil if unknown and zero if never downloaded
+		Downloaded *time.Time `json:"downloaded,omitempty"`
+
+		// DstPath is the file path in the destination repository, used to verify its hash
+		DstPath string `json:"dstPath,omitempty"`
+
+		// Extra holds source specific information, e.g. the docker image
+		Extra any `json:"-"`
+
+		// digest is the checksums of the uploaded content in verify mode
+		digest Checksum
+	}
+
+	Checksum struct {
+		Md5    string `json:"md5,omitempty"`
+		Sha1   string `json:"sha1,omitempty"`
+		Sha256 string `json:"sha256,omitempty"`
+		Sha512 string `json:"sha512,omitempty"`
+	}
+)
+
+// Key identifies the item in the journal.
+func (i *Item) Key() string {
+	if i.Url != "" {
+		return i.Url
+	}
+	return i.Path
+}
+
+// Source lists and opens artifacts of a legacy repository.
+type Source interface {
+	// List returns the items which need to be migrated.
+	List(ctx context.Context) ([]*Item, error)
+
+	// Open returns the content of the item.
+	Open(ctx context.Context, item *Item) (io.ReadCloser, error)
+}
+
+// Streamer is implemented by sources which can yield the items while listing the repository
+// page by page, so that migrating overlaps listing and a huge repository is not loaded into
+// memory. Run uses it instead of List unless in dry-run mode.
+type Streamer interface {
+	// Stream calls fn with every item which needs to be migrated, and stops on the first error of fn.
+	Stream(ctx context.Context, fn func(item *Item) error) error
+}
+
+// Sink publishes artifacts to a CODING Artifact Repository.
+type Sink interface {
+	// Put publishes the item with the content read from body.
+	// ErrFileConflict is returned if the item already exists.
+	Put(ctx context.Context, item *Item, body io.Reader) error
+}
+
+// Copier is implemented by sinks which copy an item by themselves
+// instead of reading its content from the source, e.g. docker images.
+type Copier interface {
+	Copy(ctx context.Context, item *Item) error
+}
+
+// Renderer is implemented by sources which render the scanned
+// repository in a type specific table.
+type Renderer interface {
+	Render(w io.Writer)
+}
+
+// Context is passed to the source and sink factories of a migration.
+type Context struct {
+	// Type is the artifact type, e.g. maven
+	Type string
+
+	// Out is the writer for tables
+	Out io.Writer
+
+	// Auth is authorization of the destination repository
+	Auth *config.AuthConfig
+
+	// SrcUrl is the parsed --src, nil if the source is a local path
+	SrcUrl *url.URL
+
+	// ExistsArtifacts are `package:version` which exist in the destination repository
+	ExistsArtifacts map[string]bool
+
+	// ExistsFiles are file paths which exist in the destination repository
+	ExistsFiles diskset.Set
+
+	// Journal records the progress of the migration, nil in dry-run mode
+	Journal *journal.Journal
+
+	// Bandwidth limits the bytes per second of all transfers, nil means unlimited
+	Bandwidth *ratelimit.Limiter
+
+	// Filter selects the items by --include and --exclude, nil means all
+	Filter *Filter
+
+	// Observe is called with every migrated item and the error of migrating it, nil means none.
+	// The calls are serialized.
+	Observe func(item *Item, err error)
+}
+
+type (
+	SourceFactory func(c *Context) (Source, error)
+
+	SinkFactory func(c *Context) (Sink, error)
+)
+
+// Migration describes how an artifact type is migrated.
+type Migration struct {
+	// Type is the artifact type, e.g. maven
+	Type string
+
+	// Sources are source factories keyed by --src-type, SrcTypeLocal for local paths
+	Sources map[string]SourceFactory
+
+	// NewSink creates the sink of the destination repository
+	NewSink SinkFactory
+
+	// ExistsFiles controls whether exists files of the destination are queried
+	ExistsFiles bool
+
+	// SkipExists disables querying exists artifacts of the destination
+	SkipExists bool
+
+	// Exists reports whether the item exists in the destination, nil if the sources don't list
+	// the exists items. It classifies the items of a plan.
+	Exists func(c *Context, item *Item) bool
+
+	// CompareFiles controls whether `migrate verify` compares the items with the files of the
+	// destination by Item.DstPath, or with the `package:version` artifacts otherwise
+	CompareFiles bool
+}
+
+// Migrate migrates the artifacts of settings.Src to settings.Dst.
+// Cancel the signalutil.Drain context of ctx to stop migrating new items, and ctx to abort the in-flight ones.
+func Migrate(ctx context.Context, cfg *action.Configuration, out io.Writer, m *Migration) error {
+	return migrate(ctx, cfg, out, m, mode{})
+}
+
+// WritePlan scans settings.Src and settings.Dst, and writes the plan of the migration to path
+// without migrating anything.
+func WritePlan(ctx context.Context, cfg *action.Configuration, out io.Writer, m *Migration, path string) error {
+	return migrate(ctx, cfg, out, m, mode{planPath: path})
+}
+
+// Apply migrates exactly the items to upload of the plan, settings.Src and settings.Dst must be the ones of the plan.
+func Apply(ctx context.Context, cfg *action.Configuration, out io.Writer, m *Migration, plan *Plan) error {
+	if plan.Type != m.Type {
+		return errors.Errorf("the plan is for %s, not %s", plan.Type, m.Type)
+	}
+	return migrate(ctx, cfg, out, m, mode{plan: plan})
+}
+
+// Compare compares settings.Src with settings.Dst without uploading anything, and reports
+// the items which are missing, extra or checksum-mismatched in the destination.
+// ErrDrift is returned if any difference is found.
+func Compare(ctx context.Context, cfg *action.Configuration, out io.Writer, m *Migration) error {
+	return migrate(ctx, cfg, out, m, mode{compare: true})
+}
+
+// Sync migrates the items of settings.Src like Migrate, but without a journal, since it runs
+// again and again by `carctl sync`. observe is called with every migrated item and its error.
+func Sync(ctx context.Context, cfg *action.Configuration, out io.Writer, m *Migration, observe func(item *Item, err error)) error {
+	return migrate(ctx, cfg, out, m, mode{observe: observe})
+}
+
+// mode is how migrate runs, it migrates by default.
+type mode struct {
+	// planPath is where the plan is written
+	planPath string
+
+	// plan is the plan to apply
+	plan *Plan
+
+	// compare compares the source with the destination
+	compare bool
+
+	// observe is Context.Observe, the migration has no journal if it is set
+	observe func(item *Item, err error)
+}
+
+// scanOnly reports whether nothing is migrated in the mode.
+func (md mode) scanOnly() bool {
+	return md.planPath != "" || md.compare
+}
+
+func migrate(ctx context.Context, cfg *action.Configuration, out io.Writer, m *Migration, md mode) error {
+	if settings.ReportFile != "" {
+		if err := reportutil.CheckFormat(settings.ReportFormat); err != nil {
+			return err
+		}
+	}
+
+	httputil.DefaultRetryPolicy.Retries = settings.Retries
+	httputil.DefaultRetryPolicy.Backoff = settings.RetryBackoff
+	httputil.DefaultHostLimiter.SetRate(settings.MaxRPS)
+	bandwidth, err := ratelimit.ParseBandwidth(settings.MaxBandwidth)
+	if err != nil {
+		return err
+	}
+	filter, err := NewFilter(settings.Include, settings.Exclude)
+	if err != nil {
+		return err
+	}
+	if filter, err = filter.WithTimeWindow(settings.ModifiedAfter, settings.ModifiedBefore, settings.DownloadedSince); err != nil {
+		return err
+	}
+
+	authConfig, err := Authorize(cfg)
+	if err != nil {
+		return err
+	}
+
+	c := &Context{
+		Type:        m.Type,
+		Out:         out,
+		Auth:        authConfig,
+		ExistsFiles: diskset.Map{},
+		Bandwidth:   ratelimit.New(float64(bandwidth), int(bandwidth)),
+		Filter:      filter,
+		Observe:     md.observe,
+	}
+
+	if !settings.DryRun && !md.scanOnly() && md.observe == nil {
+		c.Journal, err = openJournal(m.Type)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = c.Journal.Close() }()
+		log.Info("Migration journal, use --resume to continue an interrupted migration",
+			logfields.String("journal", c.Journal.Path()))
+	}
+
+	// exists artifacts, the ones of a plan are classified when it is written
+	if !settings.Force && !m.SkipExists && md.plan == nil && !md.compare {
+		if err = findExists(ctx, c, m); err != nil {
+			return err
+		}
+		if ds, ok := c.ExistsFiles.(*diskset.DiskSet); ok {
+			defer func() {
+				_ = ds.Close()
+				// the index of a journal is kept for --resume
+				if c.Journal == nil {
+					_ = os.Remove(ds.Path())
+				}
+			}()
+		}
+	}
+	if settings.Verbose {
+		log.Debug("exists artifacts", logfields.Int("count", len(c.ExistsArtifacts)))
+	}
+
+	srcType := settings.SrcType
+	if IsLocalRepository(settings.Src) {
+		srcType = SrcTypeLocal
+	} else {
+		c.SrcUrl, err = url.Parse(settings.Src)
+		if err != nil {
+			log.Warn("Invalid src url", logfields.String("src", settings.Src), logfields.Error(err))
+			return errors.Wrap(err, "invalid src url")
+		}
+		if c.SrcUrl.Scheme == "" {
+			c.SrcUrl.Scheme = "http"
+		}
+		// 默认为 nexus
+		if srcType == "" {
+			srcType = SrcTypeNexus
+		}
+	}
+
+	newSource, ok := m.Sources[srcType]
+	if !ok {
+		if srcType == SrcTypeLocal {
+			return errors.Errorf("unsupported migrate local %s artifacts", m.Type)
+		}
+		return errors.Errorf("This src-type [%s] is not supported", srcType)
+	}
+	src, err := newSource(c)
+	if err != nil {
+		return err
+	}
+	if md.planPath != "" {
+		return writePlan(ctx, c, m, src, md.planPath)
+	}
+	if md.compare {
+		return compare(ctx, c, m, src)
+	}
+	if md.plan != nil {
+		// the plan has been filtered
+		c.Filter = nil
+		src = &planSource{src: src, items: md.plan.UploadItems()}
+	}
+	if settings.DryRun {
+		return Run(ctx, c, src, nil)
+	}
+
+	sink, err := m.NewSink(c)
+	if err != nil {
+		return err
+	}
+	if closer, ok := sink.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
+	}
+
+	return Run(ctx, c, src, sink)
+}
+
+// openJournal opens the journal of --resume, or creates a new one.
+func openJournal(artifactType string) (*journal.Journal, error) {
+	if settings.Resume == "" {
+		path := settings.Journal
+		if path == "" {
+			path = journal.DefaultPath(artifactType)
+		}
+		return journal.Create(path, artifactType, settings.Src, settings.Dst)
+	}
+
+	j, err := journal.Open(settings.Resume)
+	if err != nil {
+		return nil, err
+	}
+	if err = j.Check(artifactType, settings.Src, settings.Dst); err != nil {
+		_ = j.Close()
+		return nil, err
+	}
+	log.Info("Resume migration from journal", logfields.String("journal", settings.Resume))
+	return j, nil
+}
+
+// findExists finds the exists artifacts of the destination, or restores them from the journal.
+func findExists(ctx context.Context, c *Context, m *Migration) (err error) {
+	if artifacts, files, ok := c.Journal.Exists(); ok {
+		c.ExistsArtifacts, c.ExistsFiles = artifacts, files
+		return nil
+	}
+
+	c.ExistsArtifacts, err = api.FindDstExistsArtifacts(ctx, c.Auth, settings.GetDstWithoutSlash(), m.Type)
+	if err != nil {
+		return errors.Wrap(err, "failed to find dst repo exists artifacts")
+	}
+	if m.ExistsFiles {
+		if c.ExistsFiles, err = findExistsFiles(ctx, c, m.Type); err != nil {
+			return errors.Wrap(err, "failed to find dst repo exists files")
+		}
+	}
+	return c.Journal.SaveExists(c.ExistsArtifacts, c.ExistsFiles)
+}
+
+// findExistsFiles returns the file paths of the destination, they are spilled to
+// an on-disk index beside the journal if the destination is huge.
+func findExistsFiles(ctx context.Context, c *Context, artifactType string) (diskset.Set, error) {
+	indexPath := c.Journal.IndexPath()
+	if indexPath == "" {
+		indexPath = filepath.Join(os.TempDir(), fmt.Sprintf("carctl-%s-%d.exists", artifactType, os.Getpid()))
+	}
+	b := diskset.NewBuilder(indexPath, diskset.DefaultThreshold)
+	err := api.EachDstFile(ctx, c.Auth, settings.GetDstWithoutSlash(), artifactType, func(f *api.RepoFile) error {
+		return b.Add(f.Path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	files, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	if ds, ok := files.(*diskset.DiskSet); ok {
+		log.Info("Exists files of the destination are indexed on disk",
+			logfields.Int("count", ds.Len()), logfields.String("index", ds.Path()))
+	}
+	return files, nil
+}
+
+// Authorize returns the authorization of settings.Dst stored by `carctl login`.
+func Authorize(cfg *action.Configuration) (*config.AuthConfig, error) {
+	log.Info("Check authorization of the registry")
+	configFile, err := cfg.RegistryClient.ConfigFile()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config file")
+	}
+
+	has, authConfig, err := configFile.GetAuthConfig(settings.Dst)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get registry authorization info")
+	}
+	if !has {
+		return nil, errors.New("Unauthorized: authentication required. Maybe you haven't logged in before.")
+	}
+
+	if settings.Verbose {
+		log.Debug("Auth config", logfields.String("host", authConfig.ServerAddress),
+			logfields.String("username", authConfig.Username),
+			logfields.String("password", authConfig.Password))
+	}
+	return &authConfig, nil
+}
+
+// IsLocalRepository reports whether src is a local path rather than an url.
+func IsLocalRepository(src string) bool {
+	return !strings.HasPrefix(src, "http")
+}
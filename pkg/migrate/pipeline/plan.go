@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/diskset"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+)
+
+// PlanVersion is the version of the plan file format.
+const PlanVersion = 1
+
+// Actions of the plan items.
+const (
+	ActionUpload       = "upload"
+	ActionSkipExists   = "skip-exists"
+	ActionSkipFiltered = "skip-filtered"
+)
+
+type (
+	// Plan is the reviewed list of items of a migration, which is written by `migrate <type> plan`
+	// and executed by `migrate apply`. The same source and destination always produce the same plan.
+	Plan struct {
+		Version int    `json:"version"`
+		Type    string `json:"type"`
+		Src     string `json:"src"`
+		SrcType string `json:"srcType,omitempty"`
+		Dst     string `json:"dst"`
+
+		Summary []*PlanSummary `json:"summary"`
+		Items   []*PlanItem    `json:"items"`
+	}
+
+	PlanSummary struct {
+		Action string `json:"action"`
+		Count  int    `json:"count"`
+		Size   int64  `json:"size"`
+	}
+
+	PlanItem struct {
+		Action string `json:"action"`
+		Reason string `json:"reason,omitempty"`
+		*Item
+	}
+)
+
+// ReadPlan reads and checks a plan file.
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read plan")
+	}
+	plan := new(Plan)
+	if err = json.Unmarshal(data, plan); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse plan %s", path)
+	}
+	if plan.Version != PlanVersion {
+		return nil, errors.Errorf("unsupported plan version %d of %s", plan.Version, path)
+	}
+	if plan.Type == "" || plan.Src == "" || plan.Dst == "" {
+		return nil, errors.Errorf("invalid plan %s: type, src and dst are required", path)
+	}
+	for i, item := range plan.Items {
+		if item.Item == nil {
+			return nil, errors.Errorf("invalid plan %s: item %d is empty", path, i)
+		}
+		switch item.Action {
+		case ActionUpload, ActionSkipExists, ActionSkipFiltered:
+		default:
+			return nil, errors.Errorf("invalid plan %s: unknown action %q of %s", path, item.Action, item.Name)
+		}
+	}
+	return plan, nil
+}
+
+// Write writes the plan as indented json.
+func (p *Plan) Write(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal plan")
+	}
+	if err = os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "failed to write plan")
+	}
+	return nil
+}
+
+// UploadItems returns the items to upload.
+func (p *Plan) UploadItems() []*Item {
+	var items []*Item
+	for _, item := range p.Items {
+		if item.Action == ActionUpload {
+			items = append(items, item.Item)
+		}
+	}
+	return items
+}
+
+// Render writes the summary table of the plan.
+func (p *Plan) Render(w io.Writer) {
+	data := make([][]string, len(p.Summary))
+	for i, s := range p.Summary {
+		data[i] = []string{s.Action, strconv.Itoa(s.Count), fmt.Sprintf("%f", float64(s.Size)/1024/1024)}
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Action", "Count", "Size(Mb)"})
+	table.SetRowLine(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func (p *Plan) add(action, reason string, item *Item) {
+	p.Items = append(p.Items, &PlanItem{Action: action, Reason: reason, Item: item})
+}
+
+// sort sorts the items by path so that the plan is deterministic, and sums them up.
+func (p *Plan) sort() {
+	sort.SliceStable(p.Items, func(i, j int) bool {
+		a, b := p.Items[i], p.Items[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Key() < b.Key()
+	})
+
+	summary := make(map[string]*PlanSummary)
+	p.Summary = nil
+	for _, action := range []string{ActionUpload, ActionSkipExists, ActionSkipFiltered} {
+		summary[action] = &PlanSummary{Action: action}
+		p.Summary = append(p.Summary, summary[action])
+	}
+	for _, item := range p.Items {
+		summary[item.Action].Count++
+		summary[item.Action].Size += item.Size
+	}
+}
+
+// writePlan lists the source, and writes the plan of every item to path.
+func writePlan(ctx context.Context, c *Context, m *Migration, src Source, path string) error {
+	log.Info("Scanning repository ...")
+	items, err := listAll(ctx, c, m, src)
+	if err != nil {
+		return err
+	}
+	log.Info("Successfully to scan the repository", logfields.Int("files", len(items)))
+
+	plan := &Plan{
+		Version: PlanVersion,
+		Type:    m.Type,
+		Src:     settings.Src,
+		SrcType: settings.SrcType,
+		Dst:     settings.Dst,
+		Items:   make([]*PlanItem, 0, len(items)),
+	}
+	items, filtered := filterItems(c.Filter, items)
+	for _, f := range filtered {
+		plan.add(ActionSkipFiltered, f.Reason, f.Item)
+	}
+	for _, item := range items {
+		if !settings.Force && m.Exists != nil && m.Exists(c, item) {
+			plan.add(ActionSkipExists, "exists in the destination", item)
+		} else {
+			plan.add(ActionUpload, "", item)
+		}
+	}
+	plan.sort()
+
+	if settings.Verbose {
+		renderItems(c.Out, plan.UploadItems())
+	}
+	plan.Render(c.Out)
+	if err = plan.Write(path); err != nil {
+		return err
+	}
+	log.Info("Successfully to write the plan, run `carctl migrate apply` to execute it",
+		logfields.String("plan", path))
+	return nil
+}
+
+// listAll lists the items including the ones which exist in the destination,
+// so that they are classified by Migration.Exists instead of being dropped by the source.
+func listAll(ctx context.Context, c *Context, m *Migration, src Source) ([]*Item, error) {
+	if m.Exists == nil {
+		return src.List(ctx)
+	}
+	artifacts, files := c.ExistsArtifacts, c.ExistsFiles
+	c.ExistsArtifacts, c.ExistsFiles = map[string]bool{}, diskset.Map{}
+	defer func() { c.ExistsArtifacts, c.ExistsFiles = artifacts, files }()
+	return src.List(ctx)
+}
+
+// planSource migrates the items of a plan, which are opened by the source of the migration.
+type planSource struct {
+	src   Source
+	items []*Item
+}
+
+func (s *planSource) List(context.Context) ([]*Item, error) {
+	return s.items, nil
+}
+
+func (s *planSource) Open(ctx context.Context, item *Item) (io.ReadCloser, error) {
+	return s.src.Open(ctx, item)
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/diskset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource lists the items which don't exist in the destination, like the real sources.
+type fakeSource struct {
+	c     *Context
+	items []*Item
+}
+
+func (s *fakeSource) List(context.Context) ([]*Item, error) {
+	var items []*Item
+	for _, item := range s.items {
+		if !s.c.ExistsArtifacts[item.Name] {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (s *fakeSource) Open(context.Context, *Item) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(nil)), nil
+}
+
+func TestWritePlan(t *testing.T) {
+	settings.Src, settings.Dst = "http://nexus/repository/raw/", "https://demo-generic.pkg.coding.net/p/r/"
+	defer func() { settings.Src, settings.Dst = "", "" }()
+
+	filter, err := NewFilter(nil, []string{"*.md"})
+	require.NoError(t, err)
+	c := &Context{
+		Type:            "generic",
+		Out:             io.Discard,
+		ExistsArtifacts: map[string]bool{"b": true},
+		ExistsFiles:     diskset.Map{},
+		Filter:          filter,
+	}
+	m := &Migration{
+		Type: "generic",
+		Exists: func(c *Context, item *Item) bool {
+			return c.ExistsArtifacts[item.Name]
+		},
+	}
+	src := &fakeSource{c: c, items: []*Item{
+		{Name: "c", Path: "dir/c.bin", Size: 3, Checksum: Checksum{Sha256: "cc"}},
+		{Name: "b", Path: "dir/b.bin", Size: 2},
+		{Name: "a", Path: "dir/a.md", Size: 1},
+	}}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plan.json")
+	require.NoError(t, writePlan(context.Background(), c, m, src, path))
+	// the exists of the context are restored
+	assert.True(t, c.ExistsArtifacts["b"])
+
+	plan, err := ReadPlan(path)
+	require.NoError(t, err)
+	assert.Equal(t, "generic", plan.Type)
+	if assert.Len(t, plan.Items, 3) {
+		assert.Equal(t, "dir/a.md", plan.Items[0].Path)
+		assert.Equal(t, ActionSkipFiltered, plan.Items[0].Action)
+		assert.Equal(t, "excluded by --exclude=*.md", plan.Items[0].Reason)
+		assert.Equal(t, ActionSkipExists, plan.Items[1].Action)
+		assert.Equal(t, ActionUpload, plan.Items[2].Action)
+		assert.Equal(t, "cc", plan.Items[2].Checksum.Sha256)
+	}
+	assert.Equal(t, []*PlanSummary{
+		{Action: ActionUpload, Count: 1, Size: 3},
+		{Action: ActionSkipExists, Count: 1, Size: 2},
+		{Action: ActionSkipFiltered, Count: 1, Size: 1},
+	}, plan.Summary)
+	if uploads := plan.UploadItems(); assert.Len(t, uploads, 1) {
+		assert.Equal(t, "c", uploads[0].Name)
+	}
+
+	// the plan is deterministic
+	first, err := os.ReadFile(path)
+	require.NoError(t, err)
+	src.items[0], src.items[2] = src.items[2], src.items[0]
+	require.NoError(t, writePlan(context.Background(), c, m, src, path))
+	second, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(second))
+}
+
+func TestReadPlanInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"version": `{"version": 2, "type": "npm", "src": "s", "dst": "d"}`,
+		"dst":     `{"version": 1, "type": "npm", "src": "s"}`,
+		"action":  `{"version": 1, "type": "npm", "src": "s", "dst": "d", "items": [{"action": "delete", "name": "a"}]}`,
+	}
+	for name, content := range tests {
+		path := filepath.Join(dir, name+".json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := ReadPlan(path)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/coding-wepack/carctl/pkg/api"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/migrate/journal"
+	"github.com/coding-wepack/carctl/pkg/remote"
+	reportutil "github.com/coding-wepack/carctl/pkg/report"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// JfrogChecksum returns the checksums of a jfrog file.
+func JfrogChecksum(f *remote.JfrogFile) Checksum {
+	return Checksum{Md5: f.ActualMd5, Sha1: f.ActualSha1, Sha256: f.Sha256}
+}
+
+// IsEmpty reports whether no checksum is known.
+func (c Checksum) IsEmpty() bool {
+	return c == Checksum{}
+}
+
+// Verify returns ErrChecksumMismatch if a known checksum of c differs from actual.
+func (c Checksum) Verify(actual Checksum) error {
+	for _, pair := range [][3]string{
+		{"md5", c.Md5, actual.Md5},
+		{"sha1", c.Sha1, actual.Sha1},
+		{"sha256", c.Sha256, actual.Sha256},
+		{"sha512", c.Sha512, actual.Sha512},
+	} {
+		if pair[1] != "" && pair[2] != "" && !strings.EqualFold(pair[1], pair[2]) {
+			return errors.Wrapf(ErrChecksumMismatch, "%s expected %s, got %s", pair[0], pair[1], pair[2])
+		}
+	}
+	return nil
+}
+
+// Match reports whether the hex digest is one of the checksums of c,
+// the algorithm of the digest is detected by its length.
+func (c Checksum) Match(digest string) bool {
+	expected := c.Of(digest)
+	return expected != "" && strings.EqualFold(expected, digest)
+}
+
+// Of returns the checksum of c of the same algorithm as the hex digest, empty if unknown.
+func (c Checksum) Of(digest string) string {
+	switch len(digest) {
+	case md5.Size * 2:
+		return c.Md5
+	case sha1.Size * 2:
+		return c.Sha1
+	case sha256.Size * 2:
+		return c.Sha256
+	case sha512.Size * 2:
+		return c.Sha512
+	default:
+		return ""
+	}
+}
+
+// digestReader hashes the content while it is read.
+type digestReader struct {
+	r      io.Reader
+	hashes [4]hash.Hash
+}
+
+func newDigestReader(r io.Reader) *digestReader {
+	d := &digestReader{hashes: [4]hash.Hash{md5.New(), sha1.New(), sha256.New(), sha512.New()}}
+	d.r = io.TeeReader(r, io.MultiWriter(d.hashes[0], d.hashes[1], d.hashes[2], d.hashes[3]))
+	return d
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	return d.r.Read(p)
+}
+
+// Checksum returns the checksums of the content read so far.
+func (d *digestReader) Checksum() Checksum {
+	sum := func(h hash.Hash) string { return hex.EncodeToString(h.Sum(nil)) }
+	return Checksum{Md5: sum(d.hashes[0]), Sha1: sum(d.hashes[1]), Sha256: sum(d.hashes[2]), Sha512: sum(d.hashes[3])}
+}
+
+// verifyDst compares the hashes of the destination files with the uploaded items,
+// and marks the mismatched items as failed.
+func verifyDst(ctx context.Context, c *Context, report *reportutil.Report, items []*Item) error {
+	var verifiable []*Item
+	for _, item := range items {
+		if item.DstPath != "" && !item.digest.IsEmpty() {
+			verifiable = append(verifiable, item)
+		}
+	}
+	if len(verifiable) == 0 {
+		return nil
+	}
+
+	log.Info("Verify hashes of the destination files ...", logfields.Int("files", len(verifiable)))
+	// only the hashes of the verifiable items are kept
+	hashes := make(map[string]string, len(verifiable))
+	for _, item := range verifiable {
+		hashes[item.DstPath] = ""
+	}
+	found := make(map[string]bool, len(verifiable))
+	err := api.EachDstFile(ctx, c.Auth, settings.GetDstWithoutSlash(), c.Type, func(f *api.RepoFile) error {
+		if _, ok := hashes[f.Path]; ok {
+			hashes[f.Path] = f.Hash
+			found[f.Path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to find dst repo file hashes")
+	}
+
+	var mismatched int
+	for _, item := range verifiable {
+		var msg string
+		digest := hashes[item.DstPath]
+		switch {
+		case !found[item.DstPath]:
+			msg = "file not found in the destination repository"
+		case digest == "":
+			continue
+		case !item.digest.Match(digest):
+			msg = errors.Wrapf(ErrChecksumMismatch, "destination hash %s", digest).Error()
+		default:
+			continue
+		}
+		mismatched++
+		report.MarkFailed(item.Url, msg)
+		if err = c.Journal.Record(item.Key(), item.Name, journal.StatusFailed, msg); err != nil {
+			return err
+		}
+	}
+	log.Info("End to verify.", logfields.Int("mismatchedCount", mismatched))
+	return nil
+}
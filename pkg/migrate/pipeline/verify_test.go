@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	helloMd5    = "5d41402abc4b2a76b9719d911017c592"
+	helloSha1   = "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+	helloSha256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+)
+
+func TestDigestReader(t *testing.T) {
+	d := newDigestReader(strings.NewReader("hello"))
+	content, err := io.ReadAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+
+	sum := d.Checksum()
+	assert.Equal(t, helloMd5, sum.Md5)
+	assert.Equal(t, helloSha1, sum.Sha1)
+	assert.Equal(t, helloSha256, sum.Sha256)
+	assert.Len(t, sum.Sha512, 128)
+}
+
+func TestChecksumVerify(t *testing.T) {
+	actual := Checksum{Md5: helloMd5, Sha1: helloSha1, Sha256: helloSha256}
+
+	assert.NoError(t, Checksum{}.Verify(actual))
+	assert.NoError(t, Checksum{Sha1: strings.ToUpper(helloSha1)}.Verify(actual))
+
+	err := Checksum{Md5: helloMd5, Sha256: strings.Repeat("0", 64)}.Verify(actual)
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+}
+
+func TestChecksumMatch(t *testing.T) {
+	c := Checksum{Md5: helloMd5, Sha1: helloSha1, Sha256: helloSha256}
+
+	assert.True(t, c.Match(helloMd5))
+	assert.True(t, c.Match(helloSha1))
+	assert.True(t, c.Match(helloSha256))
+	assert.False(t, c.Match(strings.Repeat("0", 40)))
+	assert.False(t, c.Match(strings.Repeat("0", 128)))
+	assert.False(t, c.Match(""))
+}
@@ -1,165 +1,51 @@
 package pypi
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"mime/multipart"
-	"net/http"
-	"net/url"
 	"path"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/coding-wepack/carctl/pkg/action"
-	"github.com/coding-wepack/carctl/pkg/api"
-	"github.com/coding-wepack/carctl/pkg/config"
 	"github.com/coding-wepack/carctl/pkg/constants"
 	"github.com/coding-wepack/carctl/pkg/log"
-	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
 	"github.com/coding-wepack/carctl/pkg/migrate/pypi/types"
 	"github.com/coding-wepack/carctl/pkg/migrate/pypi/types/nexus"
-	reportutil "github.com/coding-wepack/carctl/pkg/report"
 	"github.com/coding-wepack/carctl/pkg/settings"
-	"github.com/coding-wepack/carctl/pkg/util/httputil"
-	"github.com/coding-wepack/carctl/pkg/util/ioutils"
 	"github.com/pkg/errors"
-	"github.com/vbauerster/mpb/v7"
-	"github.com/vbauerster/mpb/v7/decor"
 )
 
 var (
-	ErrFileConflict = errors.New("failed to put file: 409 conflict")
+	ErrFileConflict = pipeline.ErrFileConflict
 )
 
-func Migrate(cfg *action.Configuration, out io.Writer) error {
-	log.Info("Check authorization of the registry")
-	configFile, err := cfg.RegistryClient.ConfigFile()
-	if err != nil {
-		return errors.Wrap(err, "failed to get config file")
-	}
-
-	has, authConfig, err := configFile.GetAuthConfig(settings.Dst)
-	if err != nil {
-		return errors.Wrap(err, "failed to get registry authorization info")
-	}
-	if !has {
-		return errors.New("Unauthorized: authentication required. Maybe you haven't logged in before.")
-	}
-
-	if settings.Verbose {
-		log.Debug("Auth config", logfields.String("host", authConfig.ServerAddress),
-			logfields.String("username", authConfig.Username),
-			logfields.String("password", authConfig.Password))
-	}
-	// exists artifacts
-	var exists map[string]bool
-	if !settings.Force {
-		exists, err = api.FindDstExistsArtifacts(&authConfig, settings.GetDstWithoutSlash(), constants.TypePypi)
-		if err != nil {
-			return errors.Wrap(err, "failed to find dst repo exists artifacts")
-		}
-	}
-
-	srcUrl, err := url.Parse(settings.Src)
-	if err != nil || srcUrl.Scheme == "" {
-		log.Info("src is not url, only support migrate from remote repository, eg: nexus", logfields.String("src", settings.Src))
-		if settings.Verbose {
-			log.Warn("Can't parse with error", logfields.Error(err))
-		}
-		return errors.New("source repository is not remote repository")
-	} else {
-		return MigrateFromUrl(&authConfig, out, srcUrl, exists)
+// NewMigration returns the migration of pypi repositories.
+func NewMigration() *pipeline.Migration {
+	return &pipeline.Migration{
+		Type: constants.TypePypi,
+		Sources: map[string]pipeline.SourceFactory{
+			pipeline.SrcTypeNexus: newNexusSource,
+		},
+		NewSink: newSink,
+		Exists:  exists,
 	}
 }
 
-func getFileListFromNexus(scheme, nexusHost, repository, continuationToken string) (*nexus.GetAssetsResponse, error) {
-	apiUrl := fmt.Sprintf("%s://%s/service/rest/v1/assets?repository=%s", scheme, nexusHost, repository)
-	if continuationToken != "" {
-		apiUrl = fmt.Sprintf("%s&continuationToken=%s", apiUrl, continuationToken)
-	}
-
-	resp, err := httputil.DefaultClient.GetWithAuth(apiUrl, settings.SrcUsername, settings.SrcPassword)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get components: %s", apiUrl)
-	}
-	if resp.StatusCode != http.StatusOK {
-		// 如果状态码为 404，则尝试兼容老版本的 nexus3.x，API 是带有 /nexus 前缀的
-		if resp.StatusCode == http.StatusNotFound {
-			apiUrl = fmt.Sprintf("%s://%s/nexus/service/rest/v1/assets?repository=%s", scheme, nexusHost, repository)
-			if continuationToken != "" {
-				apiUrl = fmt.Sprintf("%s&continuationToken=%s", apiUrl, continuationToken)
-			}
-			resp, err = httputil.DefaultClient.GetWithAuth(apiUrl, settings.SrcUsername, settings.SrcPassword)
-			if err != nil {
-				return nil, errors.Wrapf(err, "failed to get components: %s", apiUrl)
-			}
-			defer ioutils.QuiteClose(resp.Body)
-
-			if resp.StatusCode != http.StatusOK {
-				return nil, errors.Errorf("failed to get components: %s, status: %s", apiUrl, resp.Status)
-			}
-		} else {
-			return nil, errors.Errorf("failed to get components: %s, status: %s", apiUrl, resp.Status)
-		}
-	}
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to read resp: %s", apiUrl)
-	}
-
-	var getComponentsResp *nexus.GetAssetsResponse
-	err = json.Unmarshal(bodyBytes, &getComponentsResp)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to unmarshal resp: %s", string(bodyBytes))
-	}
-	return getComponentsResp, nil
+func Migrate(ctx context.Context, cfg *action.Configuration, out io.Writer) error {
+	return pipeline.Migrate(ctx, cfg, out, NewMigration())
 }
 
-func MigrateFromUrl(cfg *config.AuthConfig, out io.Writer, srcUrl *url.URL, exists map[string]bool) error {
-	if settings.SrcType == "" {
-		settings.SrcType = "nexus"
-	}
-	switch settings.SrcType {
-	case "nexus":
-		return MigrateFromNexus(cfg, out, srcUrl, exists)
-	default:
-		return errors.Errorf("This src-type [%s] is not supported", settings.SrcType)
-	}
+// Plan writes the plan of the migration to path.
+func Plan(ctx context.Context, cfg *action.Configuration, out io.Writer, path string) error {
+	return pipeline.WritePlan(ctx, cfg, out, NewMigration(), path)
 }
 
-func MigrateFromNexus(cfg *config.AuthConfig, out io.Writer, nexusUrl *url.URL, exists map[string]bool) error {
-	log.Infof("Get file list from source repository [%s] ...", settings.Src)
-
-	nexusScheme := nexusUrl.Scheme
-	nexusHost := nexusUrl.Host
-	urlPath := nexusUrl.Path
-	urlPathStrs := strings.Split(strings.Trim(urlPath, "/"), "/")
-	repoName := urlPathStrs[1]
-	continuationToken := ""
-
-	var nexusItemList []nexus.Item
-	for true {
-		resp, err := getFileListFromNexus(nexusScheme, nexusHost, repoName, continuationToken)
-		if err != nil {
-			log.Errorf("failed to get file list, err: %s, continuationToken: %s", err, continuationToken)
-			break
-		}
-		nexusItemList = append(nexusItemList, resp.Items...)
-		if strings.TrimSpace(resp.ContinuationToken) == "" {
-			break
-		}
-		continuationToken = resp.ContinuationToken
-	}
-
-	if err := migrateNexusRepository(out, nexusItemList, cfg.Username, cfg.Password, exists); err != nil {
-		return err
-	}
-
-	return nil
+func exists(c *pipeline.Context, item *pipeline.Item) bool {
+	return !isNeedMigrate(item.Package, item.Version, c.ExistsArtifacts)
 }
 
 func GetRepositoryFromNexusItems(repositoryUrl string, nexusItemList []nexus.Item, exists map[string]bool) (repository *types.Repository, err error) {
@@ -179,118 +65,11 @@ func GetRepositoryFromNexusItems(repositoryUrl string, nexusItemList []nexus.Ite
 	return
 }
 
-func migrateNexusRepository(w io.Writer, nexusItemList []nexus.Item, username, password string, exists map[string]bool) error {
-	log.Info("Begin to migrate ...")
-
-	repository, err := GetRepositoryFromNexusItems(settings.Src, nexusItemList, exists)
-	if err != nil {
-		return err
-	}
-
-	if repository.FileCount == 0 {
-		log.Warn("no files found, no need to migrate")
-		return nil
-	}
-	if settings.Verbose {
-		log.Info("Repository Info:")
-		repository.Render(w)
-	}
-
-	p := mpb.New(mpb.WithWidth(80))
-	total := repository.FileCount
-	const pbName = "Pushing:"
-	bar := p.Add(
-		int64(total),
-		mpb.NewBarFiller(mpb.BarStyle()),
-		mpb.PrependDecorators(
-			// display our name with one space on the right
-			decor.Name(pbName, decor.WC{W: len(pbName) + 1, C: decor.DidentRight}),
-			// replace ETA decorator with "done" message, OnComplete event
-			decor.OnComplete(
-				decor.AverageETA(decor.ET_STYLE_GO, decor.WC{W: 4}), "Done!",
-			),
-		),
-		mpb.AppendDecorators(
-			// counter
-			decor.Counters(0, "%d / %d  "),
-			// percentage
-			decor.Percentage(),
-			// average
-			// mpb.AppendDecorators(decor.AverageSpeed(decor.UnitKiB, "  % .1f")),
-		),
-	)
-
-	start := time.Now()
-
-	report := reportutil.NewReport()
-	if settings.Verbose {
-		defer func() {
-			log.Info("Migrate result:")
-			report.Render(w)
-		}()
-	}
-
-	if err := repository.ForEach(func(downloadUrl, filePath, name, version, sha256Digest string) error {
-		defer bar.Increment()
-		// doNexusMigrate(downloadUrl, filePath, name, version, sha256Digest, username, password string)
-		if err1 := doNexusMigrate(downloadUrl, filePath, name, version, sha256Digest, username, password); err1 != nil {
-			if err1 == ErrFileConflict {
-				report.AddSkippedResult(strings.Join([]string{name, version}, "="), downloadUrl, "409 Conflict")
-				return types.ErrForEachContinue
-			}
-
-			report.AddFailedResult(strings.Join([]string{name, version}, "="), downloadUrl, err1.Error())
-
-			if settings.FailFast {
-				return errors.Wrapf(err1, "failed to migrate %s", filePath)
-			}
-		} else {
-			report.AddSucceededResult(strings.Join([]string{name, version}, "="), downloadUrl, "Succeeded")
-		}
-
-		return nil
-	}); err != nil {
-		return err
-	}
-
-	// wait for our bar to complete and flush
-	p.Wait()
-
-	log.Info("End to migrate.",
-		logfields.Duration("duration", time.Now().Sub(start)),
-		logfields.Int("succeededCount", len(report.SucceededResult)),
-		logfields.Int("skippedCount", len(report.SkippedResult)),
-		logfields.Int("failedCount", len(report.FailedResult)))
-	return nil
-}
-
-func doNexusMigrate(downloadUrl, filePath, name, version, sha256Digest, username, password string) error {
-	// download
-	getResp, err := httputil.DefaultClient.GetWithAuth(downloadUrl, settings.SrcUsername, settings.SrcPassword)
-	if err != nil {
-		return errors.Wrapf(err, "failed to download from %s", downloadUrl)
-	}
-	defer ioutils.QuiteClose(getResp.Body)
-
-	// post　MultipartForm contains  json and file
-	// json key: "name", "version", "sha256_digest", "filetype": Egg, Wheel, Source
-	pushUrl := getPushUrl(filePath)
-
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("content", filepath.Base(filePath))
-	if err != nil {
-		return errors.Wrapf(err, "failed to parse upload form file %s", filePath)
-	}
-
-	// write file
-	_, err = io.Copy(part, getResp.Body)
-	if err != nil {
-		return errors.Wrapf(err, "failed to copy file stream to upload form %s", downloadUrl)
-	}
-
+// writeForm writes the upload form of a distribution file,
+// which contains fields "name", "version", "sha256_digest", "filetype": Egg, Wheel, Source
+func writeForm(writer *multipart.Writer, item *pipeline.Item, content io.Reader) error {
 	// cover pypi filetype from file extensions
-	base := filepath.Base(filePath)
+	base := filepath.Base(item.Path)
 	ext := strings.ToLower(filepath.Ext(base))
 	// for .tar.gz
 	nonExt := base[:len(base)-len(ext)]
@@ -299,36 +78,29 @@ func doNexusMigrate(downloadUrl, filePath, name, version, sha256Digest, username
 	}
 	fileType, has := DistExtensions[ext]
 	if !has {
-		return errors.Errorf("un support file extension, file: %s", path.Ext(filePath))
+		return errors.Errorf("un support file extension, file: %s", path.Ext(item.Path))
 	}
 
-	err = writer.WriteField("name", name)
-	err = writer.WriteField("version", version)
-	err = writer.WriteField("sha256_digest", sha256Digest)
-	err = writer.WriteField("filetype", fileType)
-	err = writer.Close()
+	part, err := writer.CreateFormFile("content", base)
 	if err != nil {
-		return errors.Wrapf(err, "failed to write json to upload form %s", downloadUrl)
+		return errors.Wrapf(err, "failed to parse upload form file %s", item.Path)
 	}
-
-	resp, err := httputil.DefaultClient.Post(pushUrl, writer.FormDataContentType(), body, username, password)
-	if err != nil {
-		return errors.Wrapf(err, "failed to push to %s", pushUrl)
-	}
-	defer ioutils.QuiteClose(resp.Body)
-	if resp.StatusCode >= http.StatusBadRequest {
-		if resp.StatusCode == http.StatusConflict {
-			return ErrFileConflict
-		}
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatal(err)
+	// write file
+	if _, err = io.Copy(part, content); err != nil {
+		return errors.Wrapf(err, "failed to copy file stream to upload form %s", item.Url)
+	}
+
+	for _, field := range [][2]string{
+		{"name", item.Package},
+		{"version", item.Version},
+		{"sha256_digest", item.Checksum.Sha256},
+		{"filetype", fileType},
+	} {
+		if err = writer.WriteField(field[0], field[1]); err != nil {
+			return errors.Wrapf(err, "failed to write json to upload form %s", item.Url)
 		}
-		bodyString := string(bodyBytes)
-		return errors.Errorf("got an unexpected response status: %s, resp: %s", resp.Status, bodyString)
 	}
-
-	return nil
+	return writer.Close()
 }
 
 func getPushUrl(filePath string) string {
@@ -0,0 +1,122 @@
+package pypi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"strings"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/migrate/pypi/types"
+	"github.com/coding-wepack/carctl/pkg/migrate/pypi/types/nexus"
+	"github.com/coding-wepack/carctl/pkg/remote"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/pkg/errors"
+)
+
+type nexusSource struct {
+	c *pipeline.Context
+
+	repository *types.Repository
+}
+
+func newNexusSource(c *pipeline.Context) (pipeline.Source, error) {
+	return &nexusSource{c: c}, nil
+}
+
+func (s *nexusSource) List(ctx context.Context) ([]*pipeline.Item, error) {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	nexusItemList, err := remote.FindAssetsFromNexus[nexus.Item](ctx, s.c.SrcUrl, s.c.Journal)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get file list")
+	}
+
+	s.repository, err = GetRepositoryFromNexusItems(settings.Src, nexusItemList, s.c.ExistsArtifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*pipeline.Item, 0, len(s.repository.Files))
+	for _, f := range s.repository.Files {
+		items = append(items, newItem(f))
+	}
+	return items, nil
+}
+
+// Stream migrates the files page by page while listing the source repository.
+func (s *nexusSource) Stream(ctx context.Context, fn func(item *pipeline.Item) error) error {
+	log.Infof("Get file list from source repository [%s] ...", settings.Src)
+	err := remote.EachAssetsPageFromNexus(ctx, s.c.SrcUrl, s.c.Journal, func(page []nexus.Item) error {
+		for _, f := range page {
+			if f.Pypi.Name == "" || f.Pypi.Version == "" {
+				continue
+			}
+			if !settings.Force && !isNeedMigrate(f.Pypi.Name, f.Pypi.Version, s.c.ExistsArtifacts) {
+				continue
+			}
+			if err := fn(newItem(f)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "failed to get file list")
+}
+
+func newItem(f nexus.Item) *pipeline.Item {
+	name := strings.Join([]string{f.Pypi.Name, f.Pypi.Version}, "==")
+	return &pipeline.Item{
+		Name:       name,
+		Package:    f.Pypi.Name,
+		Version:    f.Pypi.Version,
+		Path:       f.Path,
+		Coordinate: name,
+		Url:        f.DownloadURL,
+		Modified:   f.LastModified,
+		Downloaded: pipeline.NexusDownloaded(f.LastDownloaded),
+		Checksum: pipeline.Checksum{
+			Md5:    f.Checksum.Md5,
+			Sha1:   f.Checksum.Sha1,
+			Sha256: f.Checksum.Sha256,
+			Sha512: f.Checksum.Sha512,
+		},
+	}
+}
+
+func (s *nexusSource) Open(ctx context.Context, item *pipeline.Item) (io.ReadCloser, error) {
+	return pipeline.Download(ctx, item.Url)
+}
+
+func (s *nexusSource) Render(w io.Writer) {
+	s.repository.Render(w)
+}
+
+type sink struct {
+	username string
+	password string
+}
+
+func newSink(c *pipeline.Context) (pipeline.Sink, error) {
+	return &sink{username: c.Auth.Username, password: c.Auth.Password}, nil
+}
+
+// Put posts a multipart form which contains the fields and the file.
+func (s *sink) Put(ctx context.Context, item *pipeline.Item, body io.Reader) error {
+	form := &bytes.Buffer{}
+	writer := multipart.NewWriter(form)
+	if err := writeForm(writer, item, body); err != nil {
+		return err
+	}
+
+	pushUrl := getPushUrl(item.Path)
+	resp, err := httputil.DefaultClient.Post(ctx, pushUrl, writer.FormDataContentType(), form, s.username, s.password)
+	if err != nil {
+		return errors.Wrapf(err, "failed to push to %s", pushUrl)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	return pipeline.CheckPushResponse(resp)
+}
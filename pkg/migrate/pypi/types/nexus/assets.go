@@ -2,11 +2,6 @@ package nexus
 
 import "time"
 
-type GetAssetsResponse struct {
-	Items             []Item `json:"items"`
-	ContinuationToken string `json:"continuationToken"`
-}
-
 type Item struct {
 	DownloadURL string `json:"downloadUrl"`
 	Path        string `json:"path"`
@@ -19,10 +14,10 @@ type Item struct {
 		Sha256 string `json:"sha256"`
 		Md5    string `json:"md5"`
 	} `json:"checksum"`
-	ContentType    string      `json:"contentType"`
-	LastModified   time.Time   `json:"lastModified"`
-	BlobCreated    time.Time   `json:"blobCreated"`
-	LastDownloaded interface{} `json:"lastDownloaded"`
+	ContentType    string     `json:"contentType"`
+	LastModified   time.Time  `json:"lastModified"`
+	BlobCreated    time.Time  `json:"blobCreated"`
+	LastDownloaded *time.Time `json:"lastDownloaded"`
 	Pypi           struct {
 		Name     string `json:"name"`
 		Version  string `json:"version"`
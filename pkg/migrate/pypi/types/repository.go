@@ -5,11 +5,6 @@ import (
 
 	"github.com/coding-wepack/carctl/pkg/migrate/pypi/types/nexus"
 	"github.com/olekukonko/tablewriter"
-	"github.com/pkg/errors"
-)
-
-var (
-	ErrForEachContinue = errors.New("continue")
 )
 
 type (
@@ -40,18 +35,6 @@ func (r *Repository) Render(w io.Writer) {
 	table.Render()
 }
 
-func (r *Repository) ForEach(fn func(downloadUrl, filePath, name, version, sha256Digest string) error) error {
-	for _, f := range r.Files {
-		if err := fn(f.DownloadURL, f.Path, f.Pypi.Name, f.Pypi.Version, f.Checksum.Sha256); err != nil {
-			if err == ErrForEachContinue {
-				continue
-			}
-			return err
-		}
-	}
-	return nil
-}
-
 func (r *Repository) AddVersionFile(item nexus.Item) {
 	r.Files = append(r.Files, item)
 }
@@ -0,0 +1,106 @@
+package syncer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/config"
+	"github.com/pkg/errors"
+)
+
+const dirName = "sync"
+
+// States of a sync process.
+const (
+	StateSyncing  = "syncing"
+	StateWaiting  = "waiting"
+	StateStopped  = "stopped"
+	StateFinished = "finished"
+)
+
+// Status is the state of a sync process, which is persisted to the status file after every round,
+// so that a restarted process continues from the high-water mark.
+type Status struct {
+	Type string `json:"type"`
+	Src  string `json:"src"`
+	Dst  string `json:"dst"`
+
+	// State is one of StateSyncing, StateWaiting, StateStopped and StateFinished
+	State string `json:"state"`
+	Pid   int    `json:"pid"`
+
+	// HighWaterMark is the latest modified time of the source items which have been synced,
+	// the next round picks up the items modified after it
+	HighWaterMark time.Time `json:"highWaterMark,omitempty"`
+
+	Rounds        int       `json:"rounds"`
+	LastStartTime time.Time `json:"lastStartTime,omitempty"`
+	LastEndTime   time.Time `json:"lastEndTime,omitempty"`
+	NextTime      time.Time `json:"nextTime,omitempty"`
+	LastError     string    `json:"lastError,omitempty"`
+
+	// LastRound is the result of the last round
+	LastRound Counts `json:"lastRound"`
+
+	// Total is the result of all the rounds
+	Total Counts `json:"total"`
+}
+
+type Counts struct {
+	Succeeded int `json:"succeeded"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+}
+
+func (c *Counts) add(o Counts) {
+	c.Succeeded += o.Succeeded
+	c.Skipped += o.Skipped
+	c.Failed += o.Failed
+}
+
+// DefaultStatusPath returns the status file of the sync from src to dst in the config dir.
+func DefaultStatusPath(artifactType, src, dst string) string {
+	sum := sha1.Sum([]byte(src + "\n" + dst))
+	name := fmt.Sprintf("%s-%s.json", artifactType, hex.EncodeToString(sum[:])[:12])
+	return filepath.Join(config.Dir(), dirName, name)
+}
+
+// LoadStatus reads the status file at path, a new status is returned if it doesn't exist.
+// An error is returned if the status file is of another sync.
+func LoadStatus(path, artifactType, src, dst string) (*Status, error) {
+	status := &Status{Type: artifactType, Src: src, Dst: dst}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return status, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read sync status")
+	}
+	if err = json.Unmarshal(data, status); err != nil {
+		return nil, errors.Wrapf(err, "invalid sync status %s", path)
+	}
+	if status.Type != artifactType || status.Src != src || status.Dst != dst {
+		return nil, errors.Errorf("sync status %s is of %s from %s to %s", path, status.Type, status.Src, status.Dst)
+	}
+	return status, nil
+}
+
+// Save writes the status to path atomically.
+func (s *Status) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create sync status dir")
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err = os.WriteFile(temp, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "failed to write sync status")
+	}
+	return os.Rename(temp, path)
+}
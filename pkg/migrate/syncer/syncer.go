@@ -0,0 +1,171 @@
+// Package syncer keeps a CODING Artifact Repository in sync with a legacy repository which is
+// still being published to. It migrates the items modified after the high-water mark of the
+// previous rounds on an interval, until it is interrupted.
+package syncer
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/action"
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/signalutil"
+	"github.com/pkg/errors"
+)
+
+// Options of a sync.
+type Options struct {
+	// Interval is the time between the start of two rounds
+	Interval time.Duration
+
+	// Overlap is subtracted from the high-water mark when a round picks up the modified items,
+	// so that the items indexed late by the source are not missed. The items which have been
+	// synced are skipped by the exists check.
+	Overlap time.Duration
+
+	// StatusPath is the status file, DefaultStatusPath by default
+	StatusPath string
+
+	// Once runs a single round
+	Once bool
+}
+
+// Run syncs settings.Src to settings.Dst on every interval until the signalutil.Drain context of ctx is canceled,
+// settings.ModifiedAfter is the start of the first round if there is no high-water mark.
+func Run(ctx context.Context, cfg *action.Configuration, out io.Writer, m *pipeline.Migration, opts Options) (err error) {
+	if opts.StatusPath == "" {
+		opts.StatusPath = DefaultStatusPath(m.Type, settings.Src, settings.Dst)
+	}
+	status, err := LoadStatus(opts.StatusPath, m.Type, settings.Src, settings.Dst)
+	if err != nil {
+		return err
+	}
+	status.Pid = os.Getpid()
+	log.Info("Sync status", logfields.String("file", opts.StatusPath),
+		logfields.Time("highWaterMark", status.HighWaterMark), logfields.Int("rounds", status.Rounds))
+
+	modifiedAfter := settings.ModifiedAfter
+	defer func() {
+		status.State = StateStopped
+		if opts.Once {
+			status.State = StateFinished
+		}
+		status.NextTime = time.Time{}
+		if sErr := status.Save(opts.StatusPath); sErr != nil && err == nil {
+			err = sErr
+		}
+		log.Info("Sync is stopped", logfields.String("status", opts.StatusPath))
+	}()
+
+	drain := signalutil.Drain(ctx)
+	for {
+		start := time.Now()
+		if !status.HighWaterMark.IsZero() {
+			settings.ModifiedAfter = status.HighWaterMark.Add(-opts.Overlap).Format(time.RFC3339)
+		} else {
+			settings.ModifiedAfter = modifiedAfter
+		}
+		status.State = StateSyncing
+		status.LastStartTime = start
+		if err = status.Save(opts.StatusPath); err != nil {
+			return err
+		}
+
+		log.Info("Begin to sync", logfields.Int("round", status.Rounds+1),
+			logfields.String("modifiedAfter", settings.ModifiedAfter))
+		r := newRound(status.HighWaterMark)
+		roundErr := pipeline.Sync(ctx, cfg, out, m, r.observe)
+		status.Rounds++
+		status.LastEndTime = time.Now()
+		status.LastRound = r.counts
+		status.Total.add(r.counts)
+		status.LastError = ""
+		if roundErr != nil {
+			// the items which have not been listed may be older than the migrated ones
+			status.LastError = roundErr.Error()
+			log.Warn("Failed to sync, the high-water mark is kept", logfields.Error(roundErr))
+		} else {
+			status.HighWaterMark = r.highWaterMark()
+		}
+		log.Info("End to sync", logfields.Int("round", status.Rounds),
+			logfields.Int("succeededCount", r.counts.Succeeded),
+			logfields.Int("skippedCount", r.counts.Skipped),
+			logfields.Int("failedCount", r.counts.Failed),
+			logfields.Time("highWaterMark", status.HighWaterMark))
+
+		if drain.Err() != nil {
+			return nil
+		}
+		if opts.Once {
+			return roundErr
+		}
+
+		status.State = StateWaiting
+		status.NextTime = start.Add(opts.Interval)
+		if err = status.Save(opts.StatusPath); err != nil {
+			return err
+		}
+		select {
+		case <-drain.Done():
+			return nil
+		case <-time.After(time.Until(status.NextTime)):
+		}
+	}
+}
+
+// round tracks the items migrated by a round.
+type round struct {
+	mark      time.Time
+	maxSynced time.Time
+	minFailed time.Time
+	// failedUnknown is true if the modified time of a failed item is unknown
+	failedUnknown bool
+	counts        Counts
+}
+
+func newRound(mark time.Time) *round {
+	return &round{mark: mark}
+}
+
+func (r *round) observe(item *pipeline.Item, err error) {
+	switch {
+	case err == nil || errors.Is(err, pipeline.ErrFileConflict):
+		if err == nil {
+			r.counts.Succeeded++
+		} else {
+			r.counts.Skipped++
+		}
+		if item.Modified.After(r.maxSynced) {
+			r.maxSynced = item.Modified
+		}
+	default:
+		r.counts.Failed++
+		switch {
+		case item.Modified.IsZero():
+			r.failedUnknown = true
+		case r.minFailed.IsZero() || item.Modified.Before(r.minFailed):
+			r.minFailed = item.Modified
+		}
+	}
+}
+
+// highWaterMark returns the new high-water mark after the round completes. It is the latest
+// modified time of the synced items, but before the failed items so that they are retried.
+func (r *round) highWaterMark() time.Time {
+	if r.failedUnknown {
+		return r.mark
+	}
+	mark := r.mark
+	if r.maxSynced.After(mark) {
+		mark = r.maxSynced
+	}
+	if !r.minFailed.IsZero() && !r.minFailed.After(mark) {
+		mark = r.minFailed.Add(-time.Second)
+	}
+	return mark
+}
@@ -0,0 +1,58 @@
+package syncer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/migrate/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundHighWaterMark(t *testing.T) {
+	mark := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *pipeline.Item {
+		return &pipeline.Item{Modified: mark.Add(d)}
+	}
+
+	r := newRound(mark)
+	assert.Equal(t, mark, r.highWaterMark())
+
+	r.observe(at(time.Hour), nil)
+	r.observe(at(2*time.Hour), pipeline.ErrFileConflict)
+	assert.Equal(t, mark.Add(2*time.Hour), r.highWaterMark())
+	assert.Equal(t, Counts{Succeeded: 1, Skipped: 1}, r.counts)
+
+	// the failed items are retried by the next round
+	r.observe(at(90*time.Minute), errors.New("500 Internal Server Error"))
+	assert.Equal(t, mark.Add(90*time.Minute-time.Second), r.highWaterMark())
+	r.observe(at(-time.Minute), errors.New("500 Internal Server Error"))
+	assert.Equal(t, mark.Add(-time.Minute-time.Second), r.highWaterMark())
+
+	r.observe(&pipeline.Item{}, errors.New("500 Internal Server Error"))
+	assert.Equal(t, mark, r.highWaterMark())
+	assert.Equal(t, 3, r.counts.Failed)
+}
+
+func TestStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maven.json")
+	src, dst := "http://nexus/repository/maven/", "https://demo-maven.pkg.coding.net/repository/p/r/"
+
+	status, err := LoadStatus(path, "maven", src, dst)
+	require.NoError(t, err)
+	assert.True(t, status.HighWaterMark.IsZero())
+
+	status.HighWaterMark = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	status.Rounds = 3
+	require.NoError(t, status.Save(path))
+
+	loaded, err := LoadStatus(path, "maven", src, dst)
+	require.NoError(t, err)
+	assert.True(t, status.HighWaterMark.Equal(loaded.HighWaterMark))
+	assert.Equal(t, 3, loaded.Rounds)
+
+	_, err = LoadStatus(path, "maven", src, "https://demo-maven.pkg.coding.net/repository/p/other/")
+	assert.Error(t, err)
+}
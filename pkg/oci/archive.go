@@ -0,0 +1,400 @@
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/pkg/errors"
+)
+
+// Annotations of the images in the index.json of an OCI image layout.
+const (
+	AnnotationRefName       = "org.opencontainers.image.ref.name"
+	AnnotationContainerName = "io.containerd.image.name"
+)
+
+// Media types of the images of a `docker save` tarball.
+const (
+	MediaTypeDockerConfig = "application/vnd.docker.container.image.v1+json"
+	MediaTypeDockerLayer  = "application/vnd.docker.image.rootfs.diff.tar"
+)
+
+const (
+	ociLayoutFile      = "oci-layout"
+	ociIndexFile       = "index.json"
+	dockerManifestFile = "manifest.json"
+)
+
+// ArchiveImage is a tagged image of an archive.
+type ArchiveImage struct {
+	// Name is the repository of the image without the registry, e.g. `team/app`
+	Name string
+	Tag  string
+}
+
+func (i ArchiveImage) String() string {
+	return i.Name + ":" + i.Tag
+}
+
+// Archive is a local image archive: an OCI image layout directory, a tarball of it,
+// or a `docker save` tarball. It is a Source of the images in it.
+type Archive struct {
+	Path string
+
+	fs archiveFS
+
+	mu        sync.Mutex
+	images    []ArchiveImage
+	manifests map[string]*archiveManifest
+	// blobs are the files of the blobs of a docker archive keyed by digest
+	blobs map[string]string
+}
+
+// archiveManifest is the manifest of a tagged image.
+type archiveManifest struct {
+	// desc is the manifest in an OCI image layout
+	desc *Descriptor
+
+	// docker is the image of a docker archive, whose manifest is built when it's copied
+	docker *dockerImage
+	data   []byte
+}
+
+// dockerImage is an image in the manifest.json of a docker archive.
+type dockerImage struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// IsArchive reports whether path is an OCI image layout directory, or a tarball which may be an archive.
+func IsArchive(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		_, err = os.Stat(filepath.Join(path, ociLayoutFile))
+		return err == nil
+	}
+	return strings.HasSuffix(path, ".tar")
+}
+
+// OpenArchive opens the archive at path, the images of an OCI image layout without a name
+// in the annotations are named after the base name of path.
+func OpenArchive(path string) (*Archive, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{Path: path, manifests: make(map[string]*archiveManifest), blobs: make(map[string]string)}
+	if info.IsDir() {
+		a.fs = dirFS(path)
+	} else if a.fs, err = openTarFS(path); err != nil {
+		return nil, err
+	}
+
+	defaultName := strings.TrimSuffix(filepath.Base(path), ".tar")
+	switch {
+	case a.fs.exists(ociLayoutFile) && a.fs.exists(ociIndexFile):
+		err = a.loadIndex(defaultName)
+	case a.fs.exists(dockerManifestFile):
+		err = a.loadDockerManifest()
+	default:
+		err = errors.New("neither an OCI image layout nor a docker archive")
+	}
+	if err != nil {
+		_ = a.Close()
+		return nil, errors.Wrapf(err, "invalid image archive %s", path)
+	}
+	sort.Slice(a.images, func(i, j int) bool { return a.images[i].String() < a.images[j].String() })
+	return a, nil
+}
+
+// Images returns the tagged images of the archive.
+func (a *Archive) Images() []ArchiveImage {
+	return a.images
+}
+
+func (a *Archive) Close() error {
+	return a.fs.Close()
+}
+
+func (a *Archive) loadIndex(defaultName string) error {
+	data, err := a.readFile(ociIndexFile)
+	if err != nil {
+		return err
+	}
+	index := &Manifest{}
+	if err = json.Unmarshal(data, index); err != nil {
+		return errors.Wrap(err, "invalid index.json")
+	}
+	for i := range index.Manifests {
+		desc := &index.Manifests[i]
+		image, ok := annotatedImage(desc.Annotations, defaultName)
+		if !ok {
+			// untagged images are only copied as the platforms of an index
+			continue
+		}
+		a.add(image, &archiveManifest{desc: desc})
+	}
+	return nil
+}
+
+func (a *Archive) loadDockerManifest() error {
+	data, err := a.readFile(dockerManifestFile)
+	if err != nil {
+		return err
+	}
+	var images []*dockerImage
+	if err = json.Unmarshal(data, &images); err != nil {
+		return errors.Wrap(err, "invalid manifest.json")
+	}
+	for _, image := range images {
+		for _, repoTag := range image.RepoTags {
+			name, tag := splitTag(repoTag)
+			a.add(ArchiveImage{Name: trimRegistry(name), Tag: tag}, &archiveManifest{docker: image})
+		}
+	}
+	return nil
+}
+
+func (a *Archive) add(image ArchiveImage, m *archiveManifest) {
+	if _, ok := a.manifests[image.String()]; !ok {
+		a.images = append(a.images, image)
+	}
+	a.manifests[image.String()] = m
+}
+
+// GetManifest returns the manifest of an image of the archive by tag, or any manifest by digest.
+func (a *Archive) GetManifest(_ context.Context, repo, reference string) ([]byte, string, error) {
+	if strings.Contains(reference, ":") {
+		data, err := a.readBlob(reference)
+		return data, "", err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, ok := a.manifests[repo+":"+reference]
+	if !ok {
+		return nil, "", errors.Errorf("image %s:%s is not found in %s", repo, reference, a.Path)
+	}
+	if m.desc != nil {
+		data, err := a.readBlob(m.desc.Digest)
+		return data, m.desc.MediaType, err
+	}
+	if m.data == nil {
+		data, err := a.buildDockerManifest(m.docker)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to read image %s:%s of %s", repo, reference, a.Path)
+		}
+		m.data = data
+	}
+	return m.data, MediaTypeDockerManifest, nil
+}
+
+// GetBlob returns the content of a blob.
+func (a *Archive) GetBlob(_ context.Context, _, digest string) (io.ReadCloser, error) {
+	a.mu.Lock()
+	name, ok := a.blobs[digest]
+	a.mu.Unlock()
+	if !ok {
+		name = blobPath(digest)
+	}
+	rc, err := a.fs.open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "blob %s is not found in %s", digest, a.Path)
+	}
+	return rc, nil
+}
+
+// buildDockerManifest builds the image manifest of an image of a docker archive, the layers are
+// hashed because their file names are not the digests in the legacy format.
+func (a *Archive) buildDockerManifest(image *dockerImage) ([]byte, error) {
+	config, err := a.hashFile(image.Config)
+	if err != nil {
+		return nil, err
+	}
+	config.MediaType = MediaTypeDockerConfig
+	m := &Manifest{SchemaVersion: 2, MediaType: MediaTypeDockerManifest, Config: config}
+	for _, layer := range image.Layers {
+		desc, err := a.hashFile(layer)
+		if err != nil {
+			return nil, err
+		}
+		desc.MediaType = MediaTypeDockerLayer
+		m.Layers = append(m.Layers, *desc)
+	}
+	return json.Marshal(m)
+}
+
+// hashFile returns the descriptor of a file, the file is recorded as the blob of the digest.
+func (a *Archive) hashFile(name string) (*Descriptor, error) {
+	rc, err := a.fs.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer ioutils.QuiteClose(rc)
+	h := sha256.New()
+	size, err := io.Copy(h, rc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", name)
+	}
+	digest := "sha256:" + hex.EncodeToString(h.Sum(nil))
+	a.blobs[digest] = name
+	return &Descriptor{Digest: digest, Size: size}, nil
+}
+
+func (a *Archive) readBlob(digest string) ([]byte, error) {
+	data, err := a.readFile(blobPath(digest))
+	if err != nil {
+		return nil, errors.Wrapf(err, "blob %s is not found in %s", digest, a.Path)
+	}
+	return data, nil
+}
+
+func (a *Archive) readFile(name string) ([]byte, error) {
+	rc, err := a.fs.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer ioutils.QuiteClose(rc)
+	return io.ReadAll(rc)
+}
+
+// blobPath returns the path of a blob in an OCI image layout.
+func blobPath(digest string) string {
+	return "blobs/" + strings.Replace(digest, ":", "/", 1)
+}
+
+// annotatedImage returns the image of the annotations of a manifest in an index.json.
+// The ref name is either a tag of the image named defaultName, or a full name with the tag.
+func annotatedImage(annotations map[string]string, defaultName string) (ArchiveImage, bool) {
+	ref := annotations[AnnotationContainerName]
+	if ref == "" {
+		ref = annotations[AnnotationRefName]
+	}
+	if ref == "" {
+		return ArchiveImage{}, false
+	}
+	if !strings.ContainsAny(ref, ":/") {
+		return ArchiveImage{Name: defaultName, Tag: ref}, true
+	}
+	name, tag := splitTag(ref)
+	return ArchiveImage{Name: trimRegistry(name), Tag: tag}, true
+}
+
+// splitTag splits `name:tag`, the tag is latest if it's absent.
+func splitTag(ref string) (name, tag string) {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
+
+// trimRegistry removes the registry of a full image name, e.g. `docker.io/library/nginx` is `nginx`.
+func trimRegistry(name string) string {
+	i := strings.Index(name, "/")
+	if i < 0 {
+		return name
+	}
+	host := name[:i]
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return name
+	}
+	name = name[i+1:]
+	if host == "docker.io" || host == "index.docker.io" {
+		name = strings.TrimPrefix(name, "library/")
+	}
+	return name
+}
+
+// archiveFS is the files of an archive.
+type archiveFS interface {
+	open(name string) (io.ReadCloser, error)
+	exists(name string) bool
+	Close() error
+}
+
+type dirFS string
+
+func (d dirFS) open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(name)))
+}
+
+func (d dirFS) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(string(d), filepath.FromSlash(name)))
+	return err == nil
+}
+
+func (d dirFS) Close() error {
+	return nil
+}
+
+// tarFS reads the files of a tarball in place, by the offsets indexed when it's opened.
+type tarFS struct {
+	f       *os.File
+	entries map[string]tarEntry
+}
+
+type tarEntry struct {
+	offset int64
+	size   int64
+}
+
+func openTarFS(file string) (*tarFS, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	t := &tarFS{f: f, entries: make(map[string]tarEntry)}
+	// the data of the entries are skipped by seeking the file
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, errors.Wrapf(err, "invalid tarball %s, a compressed one needs to be decompressed first", file)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		t.entries[path.Clean(strings.TrimPrefix(header.Name, "./"))] = tarEntry{offset: offset, size: header.Size}
+	}
+}
+
+func (t *tarFS) open(name string) (io.ReadCloser, error) {
+	e, ok := t.entries[path.Clean(name)]
+	if !ok {
+		return nil, errors.Wrapf(os.ErrNotExist, "open %s", name)
+	}
+	return io.NopCloser(io.NewSectionReader(t.f, e.offset, e.size)), nil
+}
+
+func (t *tarFS) exists(name string) bool {
+	_, ok := t.entries[path.Clean(name)]
+	return ok
+}
+
+func (t *tarFS) Close() error {
+	return t.f.Close()
+}
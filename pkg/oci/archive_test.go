@@ -0,0 +1,124 @@
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTar(t *testing.T, path string, files map[string][]byte) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err = tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+}
+
+func TestArchive_Docker(t *testing.T) {
+	config := []byte(`{"architecture":"amd64"}`)
+	layer := []byte("layer content")
+	manifest, _ := json.Marshal([]map[string]interface{}{{
+		"Config":   "abc.json",
+		"RepoTags": []string{"docker.io/library/nginx:1.25", "registry.example.com/team/app"},
+		"Layers":   []string{"xyz/layer.tar"},
+	}})
+	path := filepath.Join(t.TempDir(), "images.tar")
+	writeTar(t, path, map[string][]byte{"manifest.json": manifest, "abc.json": config, "xyz/layer.tar": layer})
+	assert.True(t, IsArchive(path))
+
+	archive, err := OpenArchive(path)
+	require.NoError(t, err)
+	defer archive.Close()
+	assert.Equal(t, []ArchiveImage{{Name: "nginx", Tag: "1.25"}, {Name: "team/app", Tag: "latest"}}, archive.Images())
+
+	dst := newFakeRegistry(t)
+	copier := NewCopier(NewRegistry(dst.host(), true, "user", "pass'word"), nil)
+	dstRef := Reference{Repository: "project/repo/nginx", Reference: "1.25"}
+	require.NoError(t, copier.Copy(context.Background(), archive, Reference{Repository: "nginx", Reference: "1.25"}, dstRef))
+
+	m, err := ParseManifest(MediaTypeDockerManifest, dst.manifests["project/repo/nginx"]["1.25"])
+	require.NoError(t, err)
+	assert.Equal(t, Digest(config), m.Config.Digest)
+	require.Len(t, m.Layers, 1)
+	assert.Equal(t, MediaTypeDockerLayer, m.Layers[0].MediaType)
+	assert.Equal(t, layer, dst.blobs["project/repo/nginx"][Digest(layer)])
+
+	_, _, err = archive.GetManifest(context.Background(), "nginx", "missing")
+	assert.Error(t, err)
+}
+
+func TestArchive_OCILayout(t *testing.T) {
+	config := []byte(`{}`)
+	layer := []byte("oci layer")
+	manifest, _ := json.Marshal(&Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifest,
+		Config:        &Descriptor{Digest: Digest(config), Size: int64(len(config))},
+		Layers:        []Descriptor{{Digest: Digest(layer), Size: int64(len(layer))}},
+	})
+	index, _ := json.Marshal(&Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIIndex,
+		Manifests: []Descriptor{
+			{MediaType: MediaTypeOCIManifest, Digest: Digest(manifest), Size: int64(len(manifest)),
+				Annotations: map[string]string{AnnotationRefName: "1.0"}},
+			{MediaType: MediaTypeOCIManifest, Digest: Digest(manifest), Size: int64(len(manifest)),
+				Annotations: map[string]string{AnnotationContainerName: "ghcr.io/team/tool:2.0", AnnotationRefName: "2.0"}},
+			{MediaType: MediaTypeOCIManifest, Digest: Digest(manifest), Size: int64(len(manifest))},
+		},
+	})
+
+	dir := filepath.Join(t.TempDir(), "app")
+	files := map[string][]byte{
+		"oci-layout": []byte(`{"imageLayoutVersion":"1.0.0"}`),
+		"index.json": index,
+	}
+	for _, blob := range [][]byte{config, layer, manifest} {
+		files[blobPath(Digest(blob))] = blob
+	}
+	for name, data := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+	tarPath := dir + ".tar"
+	writeTar(t, tarPath, files)
+
+	for _, path := range []string{dir, tarPath} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			assert.True(t, IsArchive(path))
+			archive, err := OpenArchive(path)
+			require.NoError(t, err)
+			defer archive.Close()
+			assert.Equal(t, []ArchiveImage{{Name: "app", Tag: "1.0"}, {Name: "team/tool", Tag: "2.0"}}, archive.Images())
+
+			dst := newFakeRegistry(t)
+			copier := NewCopier(NewRegistry(dst.host(), true, "user", "pass'word"), nil)
+			dstRef := Reference{Repository: "project/repo/tool", Reference: "2.0"}
+			require.NoError(t, copier.Copy(context.Background(), archive, Reference{Repository: "team/tool", Reference: "2.0"}, dstRef))
+			assert.Equal(t, manifest, dst.manifests["project/repo/tool"]["2.0"])
+			assert.Equal(t, layer, dst.blobs["project/repo/tool"][Digest(layer)])
+		})
+	}
+
+	assert.False(t, IsArchive(filepath.Dir(dir)))
+	assert.False(t, IsArchive(strings.TrimSuffix(tarPath, ".tar")+".tgz"))
+}
+
+func TestTrimRegistry(t *testing.T) {
+	assert.Equal(t, "nginx", trimRegistry("docker.io/library/nginx"))
+	assert.Equal(t, "team/app", trimRegistry("team/app"))
+	assert.Equal(t, "app", trimRegistry("localhost:5000/app"))
+	assert.Equal(t, "library/app", trimRegistry("registry.example.com/library/app"))
+}
@@ -0,0 +1,145 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/pkg/errors"
+)
+
+// challenge is a WWW-Authenticate header, e.g.
+// `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:app:pull"`.
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenge parses the first challenge of a WWW-Authenticate header.
+func parseChallenge(header string) (*challenge, error) {
+	header = strings.TrimSpace(header)
+	i := strings.IndexByte(header, ' ')
+	if i < 0 {
+		i = len(header)
+	}
+	ch := &challenge{scheme: strings.ToLower(header[:i]), params: make(map[string]string)}
+	if ch.scheme == "" {
+		return nil, errors.New("the WWW-Authenticate header is missing")
+	}
+
+	s := header[i:]
+	for {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return ch, nil
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			// a quoted value may contain commas, e.g. `scope="repository:app:pull,push"`
+			var b strings.Builder
+			j := 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j < len(s) {
+				j++
+			}
+			value, s = b.String(), s[j:]
+		} else if j := strings.IndexByte(s, ','); j >= 0 {
+			value, s = s[:j], s[j:]
+		} else {
+			value, s = s, ""
+		}
+		ch.params[key] = strings.TrimSpace(value)
+	}
+}
+
+// scope returns the token scope of the repositories, the first one is pushed to if push.
+func scope(push bool, repositories ...string) string {
+	scopes := make([]string, len(repositories))
+	for i, repo := range repositories {
+		actions := "pull"
+		if push && i == 0 {
+			actions = "pull,push"
+		}
+		scopes[i] = "repository:" + repo + ":" + actions
+	}
+	return strings.Join(scopes, " ")
+}
+
+// defaultTokenLifetime is the lifetime of a token without expires_in, as the token spec defines.
+const defaultTokenLifetime = time.Minute
+
+// token is a bearer token, which is refreshed before it expires.
+type token struct {
+	value string
+	// refresh is when to fetch a new token, which is a quarter of the lifetime before it expires,
+	// so that a token isn't expired before the registry receives the request
+	refresh time.Time
+}
+
+// valid reports whether the token can be used at now.
+func (t *token) valid(now time.Time) bool {
+	return now.Before(t.refresh)
+}
+
+// fetchToken gets a bearer token of the scope from the realm of the challenge.
+func (r *Registry) fetchToken(ctx context.Context, ch *challenge, scope string) (*token, error) {
+	start := time.Now()
+	realm, err := url.Parse(ch.params["realm"])
+	if err != nil || realm.Host == "" {
+		return nil, errors.Errorf("invalid realm %q of registry %s", ch.params["realm"], r.Host)
+	}
+	query := realm.Query()
+	if service := ch.params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	for _, s := range strings.Fields(scope) {
+		query.Add("scope", s)
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.Username != "" || r.Password != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get token of registry %s", r.Host)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Wrapf(httputil.NewStatusError(resp), "failed to get token of registry %s", r.Host)
+	}
+
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, errors.Wrapf(err, "invalid token of registry %s", r.Host)
+	}
+	if tokenResp.Token == "" {
+		tokenResp.Token = tokenResp.AccessToken
+	}
+	lifetime := time.Duration(tokenResp.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+	return &token{value: tokenResp.Token, refresh: start.Add(lifetime * 3 / 4)}, nil
+}
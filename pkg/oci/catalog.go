@@ -0,0 +1,115 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/pkg/errors"
+)
+
+const (
+	// catalogScope is the token scope of the catalog api
+	catalogScope = "registry:catalog:*"
+	// pageSize is the number of entries of a page of the catalog and the tags
+	pageSize = "100"
+)
+
+// Catalog returns the repositories of the registry, the registry may only list the ones
+// which the user can access, e.g. Harbor and GitLab.
+func (r *Registry) Catalog(ctx context.Context) ([]string, error) {
+	var repositories []string
+	err := r.eachPage(ctx, r.url("_catalog?n="+pageSize), catalogScope, func(data []byte) error {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		repositories = append(repositories, page.Repositories...)
+		return nil
+	})
+	return repositories, errors.Wrapf(err, "failed to list repositories of %s", r.Host)
+}
+
+// Tags returns the tags of the repository.
+func (r *Registry) Tags(ctx context.Context, repo string) ([]string, error) {
+	var tags []string
+	err := r.eachPage(ctx, r.url(repo+"/tags/list?n="+pageSize), scope(false, repo), func(data []byte) error {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		tags = append(tags, page.Tags...)
+		return nil
+	})
+	return tags, errors.Wrapf(err, "failed to list tags of %s/%s", r.Host, repo)
+}
+
+// eachPage gets the pages from u by following the `Link: <next>; rel="next"` headers.
+func (r *Registry) eachPage(ctx context.Context, u, scope string, fn func(data []byte) error) error {
+	for u != "" {
+		resp, err := r.do(ctx, http.MethodGet, u, nil, nil, 0, scope)
+		if err != nil {
+			return err
+		}
+		data, err := readPage(resp)
+		if err != nil {
+			return err
+		}
+		if err = fn(data); err != nil {
+			return errors.Wrapf(err, "invalid response of %s", u)
+		}
+		u = nextLink(resp.Request.URL, r.BasePath, resp.Header.Get("Link"))
+	}
+	return nil
+}
+
+func readPage(resp *http.Response) ([]byte, error) {
+	defer ioutils.QuiteClose(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, httputil.NewStatusError(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// nextLink returns the url of the next page in a Link header, which may be relative to base,
+// the url of the current page. A registry behind the basePath, e.g. a Nexus repository, links to
+// `/v2/...` without it, which is added back.
+func nextLink(base *url.URL, basePath, header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		isNext := false
+		for _, param := range parts[1:] {
+			if strings.ReplaceAll(strings.TrimSpace(param), " ", "") == `rel="next"` {
+				isNext = true
+			}
+		}
+		target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+		if !isNext || target == "" {
+			continue
+		}
+		next, err := base.Parse(target)
+		if err != nil {
+			return ""
+		}
+		if basePath != "" && next.Host == base.Host && strings.HasPrefix(next.Path, "/v2/") {
+			next.Path = basePath + next.Path
+			if next.RawPath != "" {
+				next.RawPath = basePath + next.RawPath
+			}
+		}
+		return next.String()
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_CatalogAndTags(t *testing.T) {
+	src := newFakeRegistry(t)
+	var want []string
+	for i := 0; i < 150; i++ {
+		repo := fmt.Sprintf("team/app%03d", i)
+		src.manifests[repo] = map[string][]byte{"1.0": nil, "sha256:abc": nil}
+		want = append(want, repo)
+	}
+	for i := 0; i < 120; i++ {
+		src.manifests["team/app000"][fmt.Sprintf("v%03d", i)] = nil
+	}
+
+	r := NewRegistry(src.host(), true, "user", "pass'word")
+	repositories, err := r.Catalog(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, want, repositories)
+	assert.Contains(t, src.scopes, catalogScope)
+
+	tags, err := r.Tags(context.Background(), "team/app000")
+	require.NoError(t, err)
+	assert.Len(t, tags, 121)
+	assert.Equal(t, "1.0", tags[0])
+}
+
+func TestNextLink(t *testing.T) {
+	base, _ := url.Parse("https://registry.example.com/v2/_catalog?n=100")
+	assert.Equal(t, "https://registry.example.com/v2/_catalog?n=100&last=b",
+		nextLink(base, "", `</v2/_catalog?n=100&last=b>; rel="next"`))
+	assert.Equal(t, "https://other.example.com/v2/_catalog?last=b",
+		nextLink(base, "", `<https://other.example.com/v2/_catalog?last=a>; rel="prev", <https://other.example.com/v2/_catalog?last=b>; rel="next"`))
+	assert.Equal(t, "", nextLink(base, "", ""))
+	assert.Equal(t, "", nextLink(base, "", `</v2/_catalog?last=b>; rel="prev"`))
+
+	// the links of a registry behind a path prefix
+	base, _ = url.Parse("https://nexus.example.com/repository/docker/v2/app/tags/list?n=100")
+	assert.Equal(t, "https://nexus.example.com/repository/docker/v2/app/tags/list?n=100&last=b",
+		nextLink(base, "/repository/docker", `</v2/app/tags/list?n=100&last=b>; rel="next"`))
+	assert.Equal(t, "https://nexus.example.com/repository/docker/v2/app/tags/list?n=100&last=b",
+		nextLink(base, "/repository/docker", `</repository/docker/v2/app/tags/list?n=100&last=b>; rel="next"`))
+	assert.Equal(t, "https://nexus.example.com/repository/docker/v2/app/tags/list?n=100&last=b",
+		nextLink(base, "/repository/docker", `<list?n=100&last=b>; rel="next"`))
+}
+
+func TestRegistry_TagsBasePath(t *testing.T) {
+	src := newFakeRegistry(t)
+	src.basePath = "/repository/docker"
+	src.manifests["app"] = map[string][]byte{}
+	for i := 0; i < 120; i++ {
+		src.manifests["app"][fmt.Sprintf("v%03d", i)] = nil
+	}
+
+	r := NewRegistry(src.host(), true, "user", "pass'word")
+	r.BasePath = src.basePath
+	tags, err := r.Tags(context.Background(), "app")
+	require.NoError(t, err)
+	assert.Len(t, tags, 120)
+}
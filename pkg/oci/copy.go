@@ -0,0 +1,134 @@
+package oci
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/log/logfields"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/coding-wepack/carctl/pkg/util/ratelimit"
+	"github.com/pkg/errors"
+)
+
+// Source is where the images are copied from, e.g. a Registry or an Archive.
+type Source interface {
+	// GetManifest returns the content and the media type of the manifest of a tag or a digest,
+	// the media type is empty if it's unknown.
+	GetManifest(ctx context.Context, repo, reference string) (data []byte, mediaType string, err error)
+
+	// GetBlob returns the content of the blob.
+	GetBlob(ctx context.Context, repo, digest string) (io.ReadCloser, error)
+}
+
+// Copier copies images to the Dst registry. The layers which exist in the destination
+// repository are skipped, and the ones copied to another repository of the destination
+// before are mounted from it. It is safe for concurrent use.
+type Copier struct {
+	Dst *Registry
+
+	// Bandwidth limits the bytes per second of the copied blobs, nil means unlimited
+	Bandwidth *ratelimit.Limiter
+
+	mu sync.Mutex
+	// mounts are the destination repositories of the copied blobs keyed by digest
+	mounts map[string]string
+}
+
+// NewCopier returns a copier to dst.
+func NewCopier(dst *Registry, bandwidth *ratelimit.Limiter) *Copier {
+	return &Copier{Dst: dst, Bandwidth: bandwidth, mounts: make(map[string]string)}
+}
+
+// Copy copies the image src of the source to dst, the registries of the references are ignored.
+// If src is a manifest list or an index, the manifests of all the platforms are copied by digest
+// before it, and its content is copied as is, so the digest of the image is preserved.
+func (c *Copier) Copy(ctx context.Context, source Source, src, dst Reference) error {
+	return c.copyManifest(ctx, source, src, dst, "")
+}
+
+// copyManifest copies the manifest of src.Reference, or the manifest of the digest of an index.
+func (c *Copier) copyManifest(ctx context.Context, source Source, src, dst Reference, digest string) error {
+	reference := src.Reference
+	if digest != "" {
+		reference = digest
+	}
+	data, mediaType, err := source.GetManifest(ctx, src.Repository, reference)
+	if err != nil {
+		return err
+	}
+	m, err := ParseManifest(mediaType, data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse manifest %s of %s", reference, src.Repository)
+	}
+	if digest != "" && Digest(data) != digest {
+		return errors.Errorf("digest of manifest %s of %s mismatched", digest, src.Repository)
+	}
+
+	if m.IsIndex() {
+		for _, desc := range m.Manifests {
+			if err = c.copyManifest(ctx, source, src, dst, desc.Digest); err != nil {
+				return errors.Wrapf(err, "failed to copy manifest %s of %s", desc.Digest, src)
+			}
+		}
+		log.Debug("platforms copied", logfields.String("repository", dst.Repository),
+			logfields.String("reference", reference), logfields.Int("count", len(m.Manifests)))
+	}
+	for _, blob := range m.Blobs() {
+		if err = c.copyBlob(ctx, source, src.Repository, dst.Repository, blob); err != nil {
+			return err
+		}
+	}
+	if digest == "" {
+		digest = dst.Reference
+	}
+	return c.Dst.PutManifest(ctx, dst.Repository, digest, m.MediaType, data)
+}
+
+// copyBlob copies a blob unless it exists in the destination repository.
+func (c *Copier) copyBlob(ctx context.Context, source Source, srcRepo, dstRepo string, blob Descriptor) error {
+	exists, err := c.Dst.BlobExists(ctx, dstRepo, blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		log.Debug("blob exists", logfields.String("repository", dstRepo), logfields.String("digest", blob.Digest))
+		c.copied(dstRepo, blob.Digest)
+		return nil
+	}
+
+	c.mu.Lock()
+	from, ok := c.mounts[blob.Digest]
+	c.mu.Unlock()
+	if ok && from != dstRepo {
+		mounted, err := c.Dst.MountBlob(ctx, dstRepo, from, blob.Digest)
+		if err != nil {
+			return err
+		}
+		if mounted {
+			log.Debug("blob mounted", logfields.String("repository", dstRepo),
+				logfields.String("from", from), logfields.String("digest", blob.Digest))
+			return nil
+		}
+	}
+
+	rc, err := source.GetBlob(ctx, srcRepo, blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer ioutils.QuiteClose(rc)
+	if err = c.Dst.PushBlob(ctx, dstRepo, blob, ratelimit.NewReader(ctx, rc, c.Bandwidth)); err != nil {
+		return err
+	}
+	log.Debug("blob copied", logfields.String("repository", dstRepo),
+		logfields.String("digest", blob.Digest), logfields.Int64("size", blob.Size))
+	c.copied(dstRepo, blob.Digest)
+	return nil
+}
+
+func (c *Copier) copied(repo, digest string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mounts[digest] = repo
+}
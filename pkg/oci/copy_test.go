@@ -0,0 +1,380 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRegistry is an in-memory registry which requires bearer tokens.
+type fakeRegistry struct {
+	t      *testing.T
+	server *httptest.Server
+
+	// onToken is called before a token of the scope is issued, without holding mu
+	onToken func(scope string)
+	// basePath is the path prefix of the api, which the registry behind a proxy doesn't know
+	basePath string
+
+	mu        sync.Mutex
+	secret    string                       // the token which is accepted
+	blobs     map[string]map[string][]byte // repo -> digest -> content
+	manifests map[string]map[string][]byte // repo -> reference -> content
+	uploads   int
+	mounts    int
+	scopes    []string
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	r := &fakeRegistry{t: t, secret: "secret", blobs: map[string]map[string][]byte{}, manifests: map[string]map[string][]byte{}}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *fakeRegistry) host() string {
+	return strings.TrimPrefix(r.server.URL, "http://")
+}
+
+func (r *fakeRegistry) put(repo, digest string, data []byte) {
+	if r.blobs[repo] == nil {
+		r.blobs[repo] = map[string][]byte{}
+	}
+	r.blobs[repo][digest] = data
+}
+
+func (r *fakeRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		user, password, _ := req.BasicAuth()
+		if user != "user" || password != "pass'word" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		scope := strings.Join(req.URL.Query()["scope"], " ")
+		if r.onToken != nil {
+			r.onToken(scope)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.scopes = append(r.scopes, scope)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": r.secret, "expires_in": 300})
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if req.Header.Get("Authorization") != "Bearer "+r.secret {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.server.URL+`/token",service="fake",scope="repository:x:pull,push"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !strings.HasPrefix(req.URL.Path, r.basePath+"/v2/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, r.basePath+"/v2/")
+	switch {
+	case path == "":
+	case path == "_catalog":
+		var repos []string
+		for repo := range r.manifests {
+			repos = append(repos, repo)
+		}
+		r.page(w, req, "repositories", repos)
+	case strings.HasSuffix(path, "/tags/list"):
+		var tags []string
+		for ref := range r.manifests[strings.TrimSuffix(path, "/tags/list")] {
+			if !strings.Contains(ref, ":") {
+				tags = append(tags, ref)
+			}
+		}
+		r.page(w, req, "tags", tags)
+	case strings.Contains(path, "/manifests/"):
+		i := strings.Index(path, "/manifests/")
+		repo, ref := path[:i], path[i+len("/manifests/"):]
+		if req.Method == http.MethodPut {
+			data, _ := io.ReadAll(req.Body)
+			if r.manifests[repo] == nil {
+				r.manifests[repo] = map[string][]byte{}
+			}
+			r.manifests[repo][ref] = data
+			r.manifests[repo][Digest(data)] = data
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		data, ok := r.manifests[repo][ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var m Manifest
+		_ = json.Unmarshal(data, &m)
+		w.Header().Set("Content-Type", m.MediaType)
+		_, _ = w.Write(data)
+	case strings.HasSuffix(path, "/blobs/uploads/"):
+		repo := strings.TrimSuffix(path, "/blobs/uploads/")
+		if digest := req.URL.Query().Get("mount"); digest != "" {
+			if data, ok := r.blobs[req.URL.Query().Get("from")][digest]; ok {
+				r.mounts++
+				r.put(repo, digest, data)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/session?state=1")
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(path, "/blobs/uploads/"):
+		repo := path[:strings.Index(path, "/blobs/uploads/")]
+		assert.Equal(r.t, "1", req.URL.Query().Get("state"))
+		data, _ := io.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+		assert.Equal(r.t, Digest(data), digest)
+		r.uploads++
+		r.put(repo, digest, data)
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		i := strings.Index(path, "/blobs/")
+		data, ok := r.blobs[path[:i]][path[i+len("/blobs/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// page writes a page of the sorted entries after the query `last`, with a Link to the next page.
+func (r *fakeRegistry) page(w http.ResponseWriter, req *http.Request, key string, entries []string) {
+	sort.Strings(entries)
+	n, _ := strconv.Atoi(req.URL.Query().Get("n"))
+	if last := req.URL.Query().Get("last"); last != "" {
+		entries = entries[sort.SearchStrings(entries, last)+1:]
+	}
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+		w.Header().Set("Link", fmt.Sprintf(`<%s?n=%d&last=%s>; rel="next"`, strings.TrimPrefix(req.URL.Path, r.basePath), n, entries[n-1]))
+	}
+	_ = json.NewEncoder(w).Encode(map[string][]string{key: entries})
+}
+
+func TestCopier_Copy(t *testing.T) {
+	src := newFakeRegistry(t)
+	dst := newFakeRegistry(t)
+
+	config := []byte(`{"architecture":"amd64"}`)
+	base := []byte("base layer")
+	app := []byte("app layer")
+	for _, blob := range [][]byte{config, base, app} {
+		src.put("team/app", Digest(blob), blob)
+	}
+	manifest, _ := json.Marshal(&Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeDockerManifest,
+		Config:        &Descriptor{MediaType: "application/vnd.docker.container.image.v1+json", Digest: Digest(config), Size: int64(len(config))},
+		Layers: []Descriptor{
+			{MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip", Digest: Digest(base), Size: int64(len(base))},
+			{MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip", Digest: Digest(app), Size: int64(len(app))},
+		},
+	})
+	src.manifests["team/app"] = map[string][]byte{"1.0": manifest}
+	// the base layer exists in the destination
+	dst.put("project/repo/app", Digest(base), base)
+
+	source := NewRegistry(src.host(), true, "user", "pass'word")
+	copier := NewCopier(NewRegistry(dst.host(), true, "user", "pass'word"), nil)
+	ctx := context.Background()
+	srcRef, err := ParseReference(src.host() + "/team/app:1.0")
+	require.NoError(t, err)
+	dstRef, err := ParseReference(dst.host() + "/project/repo/app:1.0")
+	require.NoError(t, err)
+	require.NoError(t, copier.Copy(ctx, source, srcRef, dstRef))
+
+	assert.Equal(t, manifest, dst.manifests["project/repo/app"]["1.0"])
+	assert.Equal(t, app, dst.blobs["project/repo/app"][Digest(app)])
+	assert.Equal(t, 2, dst.uploads, "the existing base layer is skipped")
+
+	// the blobs are mounted to another repository of the destination
+	dstRef.Repository = "project/repo/app-copy"
+	require.NoError(t, copier.Copy(ctx, source, srcRef, dstRef))
+	assert.Equal(t, 2, dst.uploads)
+	assert.Equal(t, 3, dst.mounts)
+	assert.Contains(t, dst.scopes, "repository:project/repo/app-copy:pull,push repository:project/repo/app:pull")
+}
+
+func TestCopier_CopyIndex(t *testing.T) {
+	src := newFakeRegistry(t)
+	dst := newFakeRegistry(t)
+
+	var descs []Descriptor
+	manifests := map[string][]byte{}
+	for _, platform := range []*Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64", Variant: "v8"}} {
+		config := []byte(`{"architecture":"` + platform.Architecture + `"}`)
+		layer := []byte("layer of " + platform.Architecture)
+		src.put("app", Digest(config), config)
+		src.put("app", Digest(layer), layer)
+		manifest, _ := json.Marshal(&Manifest{
+			SchemaVersion: 2,
+			MediaType:     MediaTypeOCIManifest,
+			Config:        &Descriptor{Digest: Digest(config), Size: int64(len(config))},
+			Layers:        []Descriptor{{Digest: Digest(layer), Size: int64(len(layer))}},
+		})
+		manifests[Digest(manifest)] = manifest
+		descs = append(descs, Descriptor{MediaType: MediaTypeOCIManifest, Digest: Digest(manifest), Size: int64(len(manifest)), Platform: platform})
+	}
+	// the index is not re-encoded, e.g. the indents are kept
+	index, _ := json.MarshalIndent(&Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIIndex, Manifests: descs}, "", "   ")
+	src.manifests["app"] = map[string][]byte{"latest": index}
+	for digest, manifest := range manifests {
+		src.manifests["app"][digest] = manifest
+	}
+
+	source := NewRegistry(src.host(), true, "user", "pass'word")
+	copier := NewCopier(NewRegistry(dst.host(), true, "user", "pass'word"), nil)
+	srcRef := Reference{Repository: "app", Reference: "latest"}
+	dstRef := Reference{Repository: "project/repo/app", Reference: "latest"}
+	require.NoError(t, copier.Copy(context.Background(), source, srcRef, dstRef))
+
+	assert.Equal(t, index, dst.manifests["project/repo/app"]["latest"])
+	assert.Equal(t, index, dst.manifests["project/repo/app"][Digest(index)], "the digest of the index is preserved")
+	for digest, manifest := range manifests {
+		assert.Equal(t, manifest, dst.manifests["project/repo/app"][digest])
+	}
+	assert.Equal(t, 4, dst.uploads)
+
+	// a platform missing in the source fails the copy, and the index isn't pushed
+	descs = append(descs, Descriptor{MediaType: MediaTypeOCIManifest, Digest: Digest([]byte("missing")), Platform: &Platform{OS: "plan9", Architecture: "arm"}})
+	index, _ = json.Marshal(&Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIIndex, Manifests: descs})
+	src.manifests["app"]["broken"] = index
+	srcRef.Reference, dstRef.Reference = "broken", "broken"
+	assert.Error(t, copier.Copy(context.Background(), source, srcRef, dstRef))
+	assert.NotContains(t, dst.manifests["project/repo/app"], "broken")
+}
+
+func TestRegistry_Unauthorized(t *testing.T) {
+	src := newFakeRegistry(t)
+	r := NewRegistry(src.host(), true, "user", "wrong")
+	_, _, err := r.GetManifest(context.Background(), "app", "latest")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestRegistry_RotatedToken(t *testing.T) {
+	dst := newFakeRegistry(t)
+	r := NewRegistry(dst.host(), true, "user", "pass'word")
+	data := []byte(`{"schemaVersion":2}`)
+	require.NoError(t, r.PutManifest(context.Background(), "app", "v1", MediaTypeOCIManifest, data))
+
+	// the cached token is rejected, the manifest is put again with a new token
+	dst.mu.Lock()
+	dst.secret = "rotated"
+	dst.mu.Unlock()
+	require.NoError(t, r.PutManifest(context.Background(), "app", "v2", MediaTypeOCIManifest, data))
+	assert.Equal(t, data, dst.manifests["app"]["v2"])
+}
+
+func TestRegistry_TokenRefresh(t *testing.T) {
+	dst := newFakeRegistry(t)
+	r := NewRegistry(dst.host(), true, "user", "pass'word")
+	_, _, _ = r.GetManifest(context.Background(), "app", "latest")
+
+	s := scope(false, "app")
+	r.mu.Lock()
+	tk := r.tokens[s]
+	r.mu.Unlock()
+	require.NotNil(t, tk)
+	// expires_in is 300s, the token is refreshed in the last quarter of it
+	assert.True(t, tk.valid(time.Now().Add(200*time.Second)))
+	assert.False(t, tk.valid(time.Now().Add(230*time.Second)))
+
+	r.mu.Lock()
+	r.tokens[s] = &token{value: "expired", refresh: time.Now().Add(-time.Second)}
+	r.mu.Unlock()
+	_, _, _ = r.GetManifest(context.Background(), "app", "latest")
+	assert.Equal(t, []string{s, s}, dst.scopes, "the expiring token is refreshed before the request")
+}
+
+func TestRegistry_ConcurrentTokens(t *testing.T) {
+	dst := newFakeRegistry(t)
+	release := make(chan struct{})
+	dst.onToken = func(scope string) {
+		if strings.Contains(scope, "slow") {
+			<-release
+		}
+	}
+	r := NewRegistry(dst.host(), true, "user", "pass'word")
+	// challenge the client first
+	_, _, _ = r.GetManifest(context.Background(), "fast", "latest")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = r.GetManifest(context.Background(), "slow", "latest")
+		}()
+	}
+	// a slow token of a scope doesn't block the requests of the other scopes
+	_, err := r.BlobExists(context.Background(), "other", Digest([]byte("x")))
+	require.NoError(t, err)
+	close(release)
+	wg.Wait()
+
+	var slow int
+	for _, s := range dst.scopes {
+		if s == scope(false, "slow") {
+			slow++
+		}
+	}
+	assert.Equal(t, 1, slow, "the concurrent requests of a scope share a token")
+}
+
+func TestParseChallenge(t *testing.T) {
+	ch, err := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a/b:pull,push"`)
+	require.NoError(t, err)
+	assert.Equal(t, "bearer", ch.scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:a/b:pull,push",
+	}, ch.params)
+
+	ch, err = parseChallenge(`Basic realm=Registry`)
+	require.NoError(t, err)
+	assert.Equal(t, "basic", ch.scheme)
+	assert.Equal(t, "Registry", ch.params["realm"])
+
+	_, err = parseChallenge("")
+	assert.Error(t, err)
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Reference
+	}{
+		{"example.com/app:1.0", Reference{"example.com", "app", "1.0"}},
+		{"example.com:5000/team/app", Reference{"example.com:5000", "team/app", "latest"}},
+		{"example.com/team/app@sha256:abc", Reference{"example.com", "team/app", "sha256:abc"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseReference(tt.s)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+	_, err := ParseReference("app:1.0")
+	assert.Error(t, err)
+}
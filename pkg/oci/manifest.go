@@ -0,0 +1,105 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Media types of the manifests.
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// manifestMediaTypes are accepted when getting a manifest.
+var manifestMediaTypes = []string{
+	MediaTypeDockerManifest,
+	MediaTypeDockerManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeOCIIndex,
+}
+
+// Descriptor describes a manifest or a blob.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType,omitempty"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	URLs        []string          `json:"urls,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+// Platform is the platform of an image in an index.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Manifest is an image manifest or an index, which are told apart by IsIndex.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        *Descriptor  `json:"config,omitempty"`
+	Layers        []Descriptor `json:"layers,omitempty"`
+	Manifests     []Descriptor `json:"manifests,omitempty"`
+}
+
+// ParseManifest parses the content of a manifest, mediaType is the Content-Type of the response,
+// the mediaType field of the content is used if it is empty.
+func ParseManifest(mediaType string, data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, errors.Wrap(err, "invalid manifest")
+	}
+	if mediaType = strings.TrimSpace(strings.Split(mediaType, ";")[0]); mediaType != "" && mediaType != "application/json" {
+		m.MediaType = mediaType
+	}
+	if m.MediaType == "" {
+		if m.Manifests != nil {
+			m.MediaType = MediaTypeOCIIndex
+		} else {
+			m.MediaType = MediaTypeOCIManifest
+		}
+	}
+	if m.SchemaVersion != 2 {
+		return nil, errors.Errorf("unsupported manifest of schema version %d", m.SchemaVersion)
+	}
+	if !m.IsIndex() && m.Config == nil {
+		return nil, errors.New("invalid manifest, the config is missing")
+	}
+	return m, nil
+}
+
+// IsIndex reports whether the manifest is a docker manifest list or an OCI index.
+func (m *Manifest) IsIndex() bool {
+	return m.MediaType == MediaTypeDockerManifestList || m.MediaType == MediaTypeOCIIndex
+}
+
+// Blobs returns the config and the layers of an image manifest, without the foreign layers
+// which are not distributed by registries.
+func (m *Manifest) Blobs() []Descriptor {
+	var blobs []Descriptor
+	if m.Config != nil {
+		blobs = append(blobs, *m.Config)
+	}
+	for _, l := range m.Layers {
+		if len(l.URLs) != 0 && (strings.Contains(l.MediaType, "foreign") || strings.Contains(l.MediaType, "nondistributable")) {
+			continue
+		}
+		blobs = append(blobs, l)
+	}
+	return blobs
+}
+
+// Digest returns the sha256 digest of data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
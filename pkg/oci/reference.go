@@ -0,0 +1,56 @@
+// Package oci is an in-process client of the Docker Registry HTTP API V2, which is also the
+// OCI distribution spec. It copies images between registries without docker or skopeo.
+package oci
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Reference is an image in a registry, e.g. `registry.example.com:5000/team/app:1.0`.
+type Reference struct {
+	// Registry is the host of the registry, with the port if any
+	Registry string
+
+	// Repository is the name of the image in the registry, e.g. `team/app`
+	Repository string
+
+	// Reference is the tag, or the digest like `sha256:...`
+	Reference string
+}
+
+// ParseReference parses `host/name:tag` or `host/name@digest`. The host is required,
+// and the tag is `latest` if it is absent.
+func ParseReference(s string) (Reference, error) {
+	var ref Reference
+	i := strings.Index(s, "/")
+	if i <= 0 {
+		return ref, errors.Errorf("invalid image reference %q, the registry is required", s)
+	}
+	ref.Registry, ref.Repository = s[:i], s[i+1:]
+
+	if i = strings.Index(ref.Repository, "@"); i >= 0 {
+		ref.Repository, ref.Reference = ref.Repository[:i], ref.Repository[i+1:]
+	} else if i = strings.LastIndex(ref.Repository, ":"); i > strings.LastIndex(ref.Repository, "/") {
+		ref.Repository, ref.Reference = ref.Repository[:i], ref.Repository[i+1:]
+	} else {
+		ref.Reference = "latest"
+	}
+	if ref.Repository == "" || ref.Reference == "" {
+		return ref, errors.Errorf("invalid image reference %q", s)
+	}
+	return ref, nil
+}
+
+// IsDigest reports whether the reference is a digest.
+func (r Reference) IsDigest() bool {
+	return strings.Contains(r.Reference, ":")
+}
+
+func (r Reference) String() string {
+	if r.IsDigest() {
+		return r.Registry + "/" + r.Repository + "@" + r.Reference
+	}
+	return r.Registry + "/" + r.Repository + ":" + r.Reference
+}
@@ -0,0 +1,316 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/pkg/errors"
+)
+
+// Registry is a client of a registry, which logs in by the WWW-Authenticate challenges of the
+// registry with either basic auth or bearer tokens. It is safe for concurrent use.
+type Registry struct {
+	// Host of the registry, with the port if any
+	Host string
+
+	// PlainHTTP connects to the registry with http instead of https
+	PlainHTTP bool
+
+	// BasePath is the path of the registry api before `/v2/`, e.g. `/repository/docker-hosted`
+	// of a Nexus repository without a port connector, empty for most registries
+	BasePath string
+
+	Username string
+	Password string
+
+	client *httputil.Client
+
+	mu sync.Mutex
+	// basic is true if the registry requires basic auth
+	basic bool
+	// bearer is the challenge of the token server if the registry requires bearer tokens
+	bearer *challenge
+	// tokens are the bearer tokens keyed by scope
+	tokens map[string]*token
+	// fetches are the in-flight token requests keyed by scope, which are shared by the requests of the scope
+	fetches map[string]*tokenFetch
+}
+
+// tokenFetch is an in-flight token request, token and err are set when done is closed.
+type tokenFetch struct {
+	done  chan struct{}
+	token *token
+	err   error
+}
+
+// NewRegistry returns a client of the registry at host.
+func NewRegistry(host string, plainHTTP bool, username, password string) *Registry {
+	return &Registry{
+		Host:      host,
+		PlainHTTP: plainHTTP,
+		Username:  username,
+		Password:  password,
+		client:    httputil.New(),
+		tokens:    make(map[string]*token),
+		fetches:   make(map[string]*tokenFetch),
+	}
+}
+
+func (r *Registry) url(path string) string {
+	scheme := "https"
+	if r.PlainHTTP {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host + r.BasePath + "/v2/" + path
+}
+
+// GetManifest returns the content and the media type of the manifest of a tag or a digest.
+func (r *Registry) GetManifest(ctx context.Context, repo, reference string) (data []byte, mediaType string, err error) {
+	header := http.Header{"Accept": manifestMediaTypes}
+	resp, err := r.do(ctx, http.MethodGet, r.url(repo+"/manifests/"+reference), header, nil, 0, scope(false, repo))
+	if err != nil {
+		return nil, "", err
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", errors.Wrapf(httputil.NewStatusError(resp), "failed to get manifest %s/%s:%s", r.Host, repo, reference)
+	}
+	if data, err = io.ReadAll(resp.Body); err != nil {
+		return nil, "", errors.Wrapf(err, "failed to read manifest %s/%s:%s", r.Host, repo, reference)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// PutManifest uploads the manifest as the tag or the digest.
+func (r *Registry) PutManifest(ctx context.Context, repo, reference, mediaType string, data []byte) error {
+	header := http.Header{"Content-Type": {mediaType}}
+	resp, err := r.do(ctx, http.MethodPut, r.url(repo+"/manifests/"+reference), header,
+		bytes.NewReader(data), int64(len(data)), scope(true, repo))
+	if err != nil {
+		return err
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return errors.Wrapf(httputil.NewStatusError(resp), "failed to put manifest %s/%s:%s", r.Host, repo, reference)
+	}
+	return nil
+}
+
+// BlobExists reports whether the blob exists in the repository.
+func (r *Registry) BlobExists(ctx context.Context, repo, digest string) (bool, error) {
+	resp, err := r.do(ctx, http.MethodHead, r.url(repo+"/blobs/"+digest), nil, nil, 0, scope(false, repo))
+	if err != nil {
+		return false, err
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, errors.Wrapf(httputil.NewStatusError(resp), "failed to check blob %s of %s/%s", digest, r.Host, repo)
+	}
+}
+
+// GetBlob returns the content of the blob, which is streamed from the registry.
+func (r *Registry) GetBlob(ctx context.Context, repo, digest string) (io.ReadCloser, error) {
+	resp, err := r.do(ctx, http.MethodGet, r.url(repo+"/blobs/"+digest), nil, nil, 0, scope(false, repo))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer ioutils.QuiteClose(resp.Body)
+		return nil, errors.Wrapf(httputil.NewStatusError(resp), "failed to get blob %s of %s/%s", digest, r.Host, repo)
+	}
+	return resp.Body, nil
+}
+
+// MountBlob mounts the blob of the repository from into repo, without uploading it again.
+// It returns false if the registry doesn't mount it, e.g. the blob doesn't exist in from.
+func (r *Registry) MountBlob(ctx context.Context, repo, from, digest string) (bool, error) {
+	query := url.Values{"mount": {digest}, "from": {from}}
+	resp, err := r.do(ctx, http.MethodPost, r.url(repo+"/blobs/uploads/?"+query.Encode()), nil, nil, 0, scope(true, repo, from))
+	if err != nil {
+		return false, err
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return true, nil
+	case http.StatusAccepted:
+		// the registry started an upload instead, which is abandoned
+		if location, err := r.location(resp); err == nil {
+			r.cancelUpload(ctx, repo, location)
+		}
+		return false, nil
+	default:
+		return false, errors.Wrapf(httputil.NewStatusError(resp), "failed to mount blob %s to %s/%s", digest, r.Host, repo)
+	}
+}
+
+// PushBlob uploads the blob of the descriptor from content in a single request.
+func (r *Registry) PushBlob(ctx context.Context, repo string, desc Descriptor, content io.Reader) error {
+	resp, err := r.do(ctx, http.MethodPost, r.url(repo+"/blobs/uploads/"), nil, nil, 0, scope(true, repo))
+	if err != nil {
+		return err
+	}
+	ioutils.QuiteClose(resp.Body)
+	if resp.StatusCode != http.StatusAccepted {
+		return errors.Wrapf(httputil.NewStatusError(resp), "failed to start upload of blob %s to %s/%s", desc.Digest, r.Host, repo)
+	}
+	location, err := r.location(resp)
+	if err != nil {
+		return err
+	}
+	query := location.Query()
+	query.Set("digest", desc.Digest)
+	location.RawQuery = query.Encode()
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err = r.do(ctx, http.MethodPut, location.String(), header, content, desc.Size, scope(true, repo))
+	if err != nil {
+		return errors.Wrapf(err, "failed to upload blob %s to %s/%s", desc.Digest, r.Host, repo)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		return errors.Wrapf(httputil.NewStatusError(resp), "failed to upload blob %s to %s/%s", desc.Digest, r.Host, repo)
+	}
+	return nil
+}
+
+// location returns the absolute Location of an upload.
+func (r *Registry) location(resp *http.Response) (*url.URL, error) {
+	location, err := resp.Location()
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid upload location of %s", r.Host)
+	}
+	return location, nil
+}
+
+func (r *Registry) cancelUpload(ctx context.Context, repo string, location *url.URL) {
+	resp, err := r.do(ctx, http.MethodDelete, location.String(), nil, nil, 0, scope(true, repo))
+	if err == nil {
+		ioutils.QuiteClose(resp.Body)
+	}
+}
+
+// do sends the request with the credentials of the scope. If the registry challenges a request
+// whose body can be replayed, e.g. a manifest, the client logs in by the challenge and sends it again.
+// A streamed body can't be replayed, whose request is authorized by the token of a previous one.
+func (r *Registry) do(ctx context.Context, method, u string, header http.Header, body io.Reader, size int64, scope string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u, body)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.ContentLength = size
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if err = r.authorize(ctx, req, scope); err != nil {
+			return nil, err
+		}
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 || (body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		ioutils.QuiteClose(resp.Body)
+		if err = r.login(resp.Header.Get("WWW-Authenticate"), scope); err != nil {
+			return nil, err
+		}
+		if body != nil {
+			if body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// login records how to authorize the requests from the challenge of the registry.
+func (r *Registry) login(header, scope string) error {
+	ch, err := parseChallenge(header)
+	if err != nil {
+		return errors.Wrapf(err, "failed to login to registry %s", r.Host)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch ch.scheme {
+	case "basic":
+		r.basic = true
+	case "bearer":
+		r.bearer = ch
+		// the token may be expired
+		delete(r.tokens, scope)
+	default:
+		return errors.Errorf("unsupported auth scheme %s of registry %s", ch.scheme, r.Host)
+	}
+	return nil
+}
+
+// authorize sets the credentials of the scope to req, the token is fetched if it's not cached or expiring.
+func (r *Registry) authorize(ctx context.Context, req *http.Request, scope string) error {
+	r.mu.Lock()
+	bearer, basic := r.bearer, r.basic
+	t, ok := r.tokens[scope]
+	r.mu.Unlock()
+	switch {
+	case bearer != nil:
+		if !ok || !t.valid(time.Now()) {
+			var err error
+			if t, err = r.token(ctx, bearer, scope); err != nil {
+				return err
+			}
+		}
+		if t.value != "" {
+			req.Header.Set("Authorization", "Bearer "+t.value)
+		}
+	case basic:
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	return nil
+}
+
+// token fetches a token of the scope without holding the lock, the concurrent requests of
+// the scope wait for the same fetch.
+func (r *Registry) token(ctx context.Context, ch *challenge, scope string) (*token, error) {
+	r.mu.Lock()
+	fetch, ok := r.fetches[scope]
+	if !ok {
+		fetch = &tokenFetch{done: make(chan struct{})}
+		r.fetches[scope] = fetch
+	}
+	r.mu.Unlock()
+
+	if ok {
+		select {
+		case <-fetch.done:
+			return fetch.token, fetch.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	fetch.token, fetch.err = r.fetchToken(ctx, ch, scope)
+	r.mu.Lock()
+	delete(r.fetches, scope)
+	if fetch.err == nil {
+		r.tokens[scope] = fetch.token
+	}
+	r.mu.Unlock()
+	close(fetch.done)
+	return fetch.token, fetch.err
+}
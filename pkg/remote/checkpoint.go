@@ -0,0 +1,42 @@
+package remote
+
+import "encoding/json"
+
+// Checkpoint saves the pages of a paged listing, so that an interrupted listing
+// can continue from the cursor of the next page instead of the first page.
+type Checkpoint interface {
+	// EachPage calls fn with every saved page, and returns the cursor of the next page
+	// and whether the last page has been saved.
+	EachPage(fn func(page json.RawMessage) error) (cursor string, listed bool, err error)
+
+	// SavePage saves a page and the cursor of the next page.
+	SavePage(page json.RawMessage, cursor string, last bool) error
+}
+
+// restorePages calls fn with the items of every saved page of cp.
+func restorePages[T any](cp Checkpoint, fn func(items []T) error) (count int, cursor string, listed bool, err error) {
+	if cp == nil {
+		return 0, "", false, nil
+	}
+	cursor, listed, err = cp.EachPage(func(page json.RawMessage) error {
+		var items []T
+		if err := json.Unmarshal(page, &items); err != nil {
+			return err
+		}
+		count += len(items)
+		return fn(items)
+	})
+	return count, cursor, listed, err
+}
+
+// savePage saves items as a page of cp.
+func savePage[T any](cp Checkpoint, items []T, cursor string, last bool) error {
+	if cp == nil {
+		return nil
+	}
+	page, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return cp.SavePage(page, cursor, last)
+}
@@ -1,13 +1,16 @@
 package remote
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/coding-wepack/carctl/pkg/log"
 	"github.com/coding-wepack/carctl/pkg/settings"
 	"github.com/jfrog/jfrog-client-go/artifactory"
 	"github.com/jfrog/jfrog-client-go/artifactory/auth"
@@ -26,6 +29,36 @@ type JfrogFile struct {
 	Modified   string    `json:"modified"`
 	ModifiedBy string    `json:"modified_by"`
 	Updated    time.Time `json:"updated"`
+	ActualMd5  string    `json:"actual_md5,omitempty"`
+	ActualSha1 string    `json:"actual_sha1,omitempty"`
+	Sha256     string    `json:"sha256,omitempty"`
+
+	// Stats are the download statistics, only fetched when --downloaded-since is set
+	Stats []JfrogFileStat `json:"stats,omitempty"`
+}
+
+type JfrogFileStat struct {
+	Downloaded string `json:"downloaded,omitempty"`
+	Downloads  int64  `json:"downloads,omitempty"`
+}
+
+// ModifiedTime returns the modified time of the file, or the updated time if it is not present.
+func (f *JfrogFile) ModifiedTime() time.Time {
+	if t, err := time.Parse(time.RFC3339, f.Modified); err == nil {
+		return t
+	}
+	return f.Updated
+}
+
+// DownloadedTime returns the last downloaded time of the file, zero if it has never been downloaded.
+func (f *JfrogFile) DownloadedTime() *time.Time {
+	var downloaded time.Time
+	for _, stat := range f.Stats {
+		if t, err := time.Parse(time.RFC3339, stat.Downloaded); err == nil && t.After(downloaded) {
+			downloaded = t
+		}
+	}
+	return &downloaded
 }
 
 func (f *JfrogFile) GetFilePath() string {
@@ -85,29 +118,169 @@ func initJfrogArtifactsManager(jfrogUrl *url.URL) (err error) {
 	return nil
 }
 
-// FindFileListFromJfrog 使用 jfrog AQL 来获取文件列表
-func FindFileListFromJfrog(jfrogUrl *url.URL, repository string) (filesInfo *JfrogFileResult, err error) {
+const (
+	// jfrogPageSize is the limit of a jfrog AQL query
+	jfrogPageSize = 10000
+
+	// jfrogIncludeFields are the default fields of items with checksums.
+	// Only items fields can be included, the AQL with sort, offset or limit rejects the fields of other domains
+	jfrogIncludeFields = `"repo", "path", "name", "type", "size", "created", "created_by", "modified", "modified_by", "updated", "actual_md5", "actual_sha1", "sha256"`
+
+	// jfrogStatsBatchSize is the count of files whose download statistics are found by one AQL
+	jfrogStatsBatchSize = 500
+)
+
+// FindFileListFromJfrog 使用 jfrog AQL 来分页获取文件列表，
+// 每一页以及下一页的 offset 都会保存到 cp 中，cp 中已有的页不会重复获取
+func FindFileListFromJfrog(ctx context.Context, jfrogUrl *url.URL, repository string, cp Checkpoint) (filesInfo *JfrogFileResult, err error) {
+	filesInfo = new(JfrogFileResult)
+	err = EachFileListPageFromJfrog(ctx, jfrogUrl, repository, cp, func(page []JfrogFile) error {
+		filesInfo.Res = append(filesInfo.Res, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	filesInfo.Ran.Total = len(filesInfo.Res)
+	return filesInfo, nil
+}
+
+// EachFileListPageFromJfrog 使用 jfrog AQL 来分页获取文件列表，每获取一页就调用 fn，
+// 先以 cp 中已保存的页调用 fn，再从 cp 中的 offset 继续获取
+func EachFileListPageFromJfrog(ctx context.Context, jfrogUrl *url.URL, repository string, cp Checkpoint, fn func(page []JfrogFile) error) (err error) {
 	if jfrogAsManager == nil {
 		err = initJfrogArtifactsManager(jfrogUrl)
 		if err != nil {
-			err = errors.Wrap(err, "failed to init jfrog artifacts manager")
-			return nil, err
+			return errors.Wrap(err, "failed to init jfrog artifacts manager")
 		}
 	}
 
-	// 执行 AQL
-	reader, err := jfrogAsManager.Aql(fmt.Sprintf("items.find({\"repo\": \"%s\"})", repository))
+	count, cursor, listed, err := restorePages[JfrogFile](cp, fn)
 	if err != nil {
-		return nil, errors.Wrap(err, "executed jfrog AQL query failed")
+		return errors.Wrap(err, "failed to restore file list")
 	}
-	content, err := io.ReadAll(reader)
+	if listed {
+		log.Infof("restored file list from checkpoint, count: %d", count)
+		return nil
+	}
+	offset := 0
+	if cursor != "" {
+		if offset, err = strconv.Atoi(cursor); err != nil {
+			return errors.Wrapf(err, "invalid jfrog offset: %s", cursor)
+		}
+		log.Infof("continue to get file list from offset: %d, restored count: %d", offset, count)
+	}
+
+	for {
+		// the AQL of the jfrog client can't be canceled, check ctx between pages
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		page, err := findFileListPageFromJfrog(repository, offset)
+		if err != nil {
+			return err
+		}
+		if settings.DownloadedSince != "" {
+			if err = findStatsFromJfrog(repository, page.Res); err != nil {
+				return err
+			}
+		}
+		offset += len(page.Res)
+		last := len(page.Res) < jfrogPageSize
+		if err = savePage(cp, page.Res, strconv.Itoa(offset), last); err != nil {
+			return errors.Wrap(err, "failed to save checkpoint")
+		}
+		if err = fn(page.Res); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func findFileListPageFromJfrog(repository string, offset int) (*JfrogFileResult, error) {
+	filesInfo := new(JfrogFileResult)
+	if err := execJfrogAql(jfrogPageAql(repository, offset), filesInfo); err != nil {
+		return nil, err
+	}
+	return filesInfo, nil
+}
+
+// findStatsFromJfrog 获取 files 的下载统计并填充到 Stats 中
+func findStatsFromJfrog(repository string, files []JfrogFile) error {
+	for start := 0; start < len(files); start += jfrogStatsBatchSize {
+		end := start + jfrogStatsBatchSize
+		if end > len(files) {
+			end = len(files)
+		}
+		aql, err := jfrogStatsAql(repository, files[start:end])
+		if err != nil {
+			return err
+		}
+		if aql == "" {
+			continue
+		}
+		stats := new(JfrogFileResult)
+		if err = execJfrogAql(aql, stats); err != nil {
+			return errors.Wrap(err, "failed to find download statistics")
+		}
+		fillStats(files[start:end], stats.Res)
+	}
+	return nil
+}
+
+// jfrogPageAql 返回分页获取文件列表的 AQL，按路径排序以保证分页稳定
+func jfrogPageAql(repository string, offset int) string {
+	return fmt.Sprintf(`items.find({"repo": "%s"}).include(%s).sort({"$asc": ["path", "name"]}).offset(%d).limit(%d)`,
+		repository, jfrogIncludeFields, offset, jfrogPageSize)
+}
+
+// jfrogStatsAql 返回获取 files 下载统计的 AQL，不分页，因为 stat 字段不能与 sort、offset、limit 一起使用。
+// files 中没有文件时返回空
+func jfrogStatsAql(repository string, files []JfrogFile) (string, error) {
+	var or []map[string]string
+	for _, f := range files {
+		if f.Type == "folder" {
+			continue
+		}
+		or = append(or, map[string]string{"path": f.Path, "name": f.Name})
+	}
+	if len(or) == 0 {
+		return "", nil
+	}
+	criteria, err := json.Marshal(map[string]any{"repo": repository, "$or": or})
 	if err != nil {
-		return nil, errors.Wrap(err, "read content from jfrog aql result failed")
+		return "", errors.Wrap(err, "failed to marshal jfrog AQL criteria")
 	}
+	return fmt.Sprintf(`items.find(%s).include("repo", "path", "name", "stat.downloaded")`, criteria), nil
+}
 
-	filesInfo = new(JfrogFileResult)
-	if err = json.Unmarshal(content, filesInfo); err != nil {
-		return nil, errors.Wrap(err, "unmarshal jfrog AQL query result failed")
+// fillStats 按路径把 stats 中的下载统计填充到 files 中
+func fillStats(files []JfrogFile, stats []JfrogFile) {
+	byPath := make(map[string][]JfrogFileStat, len(stats))
+	for _, s := range stats {
+		byPath[s.GetFilePath()] = s.Stats
 	}
-	return
+	for i := range files {
+		if s, ok := byPath[files[i].GetFilePath()]; ok {
+			files[i].Stats = s
+		}
+	}
+}
+
+func execJfrogAql(aql string, result *JfrogFileResult) error {
+	reader, err := jfrogAsManager.Aql(aql)
+	if err != nil {
+		return errors.Wrap(err, "executed jfrog AQL query failed")
+	}
+	defer func() { _ = reader.Close() }()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return errors.Wrap(err, "read content from jfrog aql result failed")
+	}
+	if err = json.Unmarshal(content, result); err != nil {
+		return errors.Wrap(err, "unmarshal jfrog AQL query result failed")
+	}
+	return nil
 }
@@ -0,0 +1,61 @@
+package remote
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJfrogPageAql(t *testing.T) {
+	aql := jfrogPageAql("maven-local", 10000)
+
+	assert.Contains(t, aql, `.offset(10000).limit(10000)`)
+	// the fields of other domains are rejected with sort, offset and limit
+	assert.NotContains(t, aql, "stat.")
+	assert.NotContains(t, aql, "property.")
+	assert.NotContains(t, aql, "archive.")
+}
+
+func TestJfrogStatsAql(t *testing.T) {
+	files := []JfrogFile{
+		{Path: "com/example", Name: "app", Type: "folder"},
+		{Path: "com/example/app/1.0", Name: `app "1.0".jar`, Type: "file"},
+	}
+
+	aql, err := jfrogStatsAql("maven-local", files)
+	require.NoError(t, err)
+	assert.Contains(t, aql, `"stat.downloaded"`)
+	assert.NotContains(t, aql, ".sort(")
+	assert.NotContains(t, aql, ".offset(")
+	assert.NotContains(t, aql, ".limit(")
+
+	criteria := strings.TrimSuffix(strings.TrimPrefix(aql, "items.find("), `).include("repo", "path", "name", "stat.downloaded")`)
+	var got struct {
+		Repo string              `json:"repo"`
+		Or   []map[string]string `json:"$or"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(criteria), &got))
+	assert.Equal(t, "maven-local", got.Repo)
+	assert.Equal(t, []map[string]string{{"path": "com/example/app/1.0", "name": `app "1.0".jar`}}, got.Or)
+
+	aql, err = jfrogStatsAql("maven-local", files[:1])
+	require.NoError(t, err)
+	assert.Empty(t, aql)
+}
+
+func TestFillStats(t *testing.T) {
+	files := []JfrogFile{
+		{Path: "a", Name: "1.jar"},
+		{Path: "a", Name: "2.jar"},
+	}
+	fillStats(files, []JfrogFile{
+		{Path: "a", Name: "2.jar", Stats: []JfrogFileStat{{Downloaded: "2024-01-02T03:04:05Z", Downloads: 1}}},
+	})
+
+	assert.True(t, files[0].DownloadedTime().IsZero())
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), files[1].DownloadedTime().UTC())
+}
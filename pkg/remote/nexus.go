@@ -0,0 +1,128 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+	"github.com/coding-wepack/carctl/pkg/settings"
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/pkg/errors"
+)
+
+// NexusAssetsResponse is a page of the nexus3 assets API, T is the format specific asset type.
+type NexusAssetsResponse[T any] struct {
+	Items             []T    `json:"items"`
+	ContinuationToken string `json:"continuationToken"`
+}
+
+// GetNexusRepositoryName returns the repository name of an url like http://host/repository/{name}/
+func GetNexusRepositoryName(nexusUrl *url.URL) (string, error) {
+	urlPathStrs := strings.Split(strings.Trim(nexusUrl.Path, "/"), "/")
+	if len(urlPathStrs) < 2 {
+		return "", errors.Errorf("invalid nexus repository url: %s", nexusUrl)
+	}
+	return urlPathStrs[1], nil
+}
+
+// FindAssetsFromNexus 使用 nexus3 API 来获取全部文件列表，
+// 每一页以及下一页的 continuationToken 都会保存到 cp 中，cp 中已有的页不会重复获取
+func FindAssetsFromNexus[T any](ctx context.Context, nexusUrl *url.URL, cp Checkpoint) (items []T, err error) {
+	err = EachAssetsPageFromNexus(ctx, nexusUrl, cp, func(page []T) error {
+		items = append(items, page...)
+		return nil
+	})
+	return items, err
+}
+
+// EachAssetsPageFromNexus 使用 nexus3 API 来分页获取文件列表，每获取一页就调用 fn，
+// 先以 cp 中已保存的页调用 fn，再从 cp 中的 continuationToken 继续获取
+func EachAssetsPageFromNexus[T any](ctx context.Context, nexusUrl *url.URL, cp Checkpoint, fn func(page []T) error) error {
+	repository, err := GetNexusRepositoryName(nexusUrl)
+	if err != nil {
+		return err
+	}
+
+	count, continuationToken, listed, err := restorePages[T](cp, fn)
+	if err != nil {
+		return errors.Wrap(err, "failed to restore file list")
+	}
+	if listed {
+		log.Infof("restored file list from checkpoint, count: %d", count)
+		return nil
+	}
+	if continuationToken != "" {
+		log.Infof("continue to get file list from continuationToken: %s, restored count: %d", continuationToken, count)
+	}
+
+	for {
+		resp, err := GetAssetsFromNexus[T](ctx, nexusUrl, repository, continuationToken)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// the listing is incomplete, the saved pages let a retry continue from this page
+			return errors.Wrapf(err, "failed to get file list, continuationToken: %s", continuationToken)
+		}
+		last := strings.TrimSpace(resp.ContinuationToken) == ""
+		if err = savePage(cp, resp.Items, resp.ContinuationToken, last); err != nil {
+			return errors.Wrap(err, "failed to save checkpoint")
+		}
+		if err = fn(resp.Items); err != nil {
+			return err
+		}
+		if last {
+			break
+		}
+		continuationToken = resp.ContinuationToken
+	}
+	return nil
+}
+
+// GetAssetsFromNexus 使用 nexus3 API 来获取一页文件列表
+func GetAssetsFromNexus[T any](ctx context.Context, nexusUrl *url.URL, repository, continuationToken string) (*NexusAssetsResponse[T], error) {
+	apiUrl := nexusAssetsApiUrl(nexusUrl, "", repository, continuationToken)
+	resp, err := httputil.DefaultClient.GetWithAuth(ctx, apiUrl, settings.SrcUsername, settings.SrcPassword)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get components: %s", apiUrl)
+	}
+	defer ioutils.QuiteClose(resp.Body)
+
+	// 如果状态码为 404，则尝试兼容老版本的 nexus3.x，API 是带有 /nexus 前缀的
+	if resp.StatusCode == http.StatusNotFound {
+		apiUrl = nexusAssetsApiUrl(nexusUrl, "/nexus", repository, continuationToken)
+		resp, err = httputil.DefaultClient.GetWithAuth(ctx, apiUrl, settings.SrcUsername, settings.SrcPassword)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get components: %s", apiUrl)
+		}
+		defer ioutils.QuiteClose(resp.Body)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to get components: %s, status: %s", apiUrl, resp.Status)
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read resp: %s", apiUrl)
+	}
+
+	var assetsResp *NexusAssetsResponse[T]
+	if err = json.Unmarshal(bodyBytes, &assetsResp); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal resp: %s", string(bodyBytes))
+	}
+	return assetsResp, nil
+}
+
+func nexusAssetsApiUrl(nexusUrl *url.URL, prefix, repository, continuationToken string) string {
+	apiUrl := fmt.Sprintf("%s://%s%s/service/rest/v1/assets?repository=%s", nexusUrl.Scheme, nexusUrl.Host, prefix, repository)
+	if continuationToken != "" {
+		apiUrl = fmt.Sprintf("%s&continuationToken=%s", apiUrl, url.QueryEscape(continuationToken))
+	}
+	return apiUrl
+}
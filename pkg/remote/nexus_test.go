@@ -0,0 +1,76 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coding-wepack/carctl/pkg/util/httputil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type asset struct {
+	Path string `json:"path"`
+}
+
+// memCheckpoint saves the pages in memory.
+type memCheckpoint struct {
+	pages  []json.RawMessage
+	cursor string
+	listed bool
+}
+
+func (cp *memCheckpoint) EachPage(fn func(page json.RawMessage) error) (string, bool, error) {
+	for _, page := range cp.pages {
+		if err := fn(page); err != nil {
+			return "", false, err
+		}
+	}
+	return cp.cursor, cp.listed, nil
+}
+
+func (cp *memCheckpoint) SavePage(page json.RawMessage, cursor string, last bool) error {
+	cp.pages = append(cp.pages, page)
+	cp.cursor, cp.listed = cursor, last
+	return nil
+}
+
+func TestEachAssetsPageFromNexus_PageError(t *testing.T) {
+	defer func(retries int) { httputil.DefaultRetryPolicy.Retries = retries }(httputil.DefaultRetryPolicy.Retries)
+	httputil.DefaultRetryPolicy.Retries = 0
+
+	failing := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("continuationToken") {
+		case "":
+			_, _ = w.Write([]byte(`{"items":[{"path":"a"}],"continuationToken":"page2"}`))
+		case "page2":
+			if failing {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte(`{"items":[{"path":"b"}]}`))
+		}
+	}))
+	defer server.Close()
+	nexusUrl, _ := url.Parse(server.URL + "/repository/maven-releases/")
+
+	cp := &memCheckpoint{}
+	items, err := FindAssetsFromNexus[asset](context.Background(), nexusUrl, cp)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "continuationToken: page2")
+	assert.Equal(t, []asset{{Path: "a"}}, items)
+	assert.Equal(t, "page2", cp.cursor)
+	assert.False(t, cp.listed)
+
+	// a retry continues from the failed page
+	failing = false
+	items, err = FindAssetsFromNexus[asset](context.Background(), nexusUrl, cp)
+	require.NoError(t, err)
+	assert.Equal(t, []asset{{Path: "a"}, {Path: "b"}}, items)
+	assert.True(t, cp.listed)
+}
@@ -0,0 +1,171 @@
+package types
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+)
+
+// Batch is the combined report of the migrations of a batch manifest.
+type Batch struct {
+	StartTime  time.Time         `json:"startTime"`
+	EndTime    time.Time         `json:"endTime"`
+	Version    string            `json:"version"`
+	Migrations []*BatchMigration `json:"migrations"`
+}
+
+// BatchMigration is the result of a migration of a batch.
+type BatchMigration struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Src  string `json:"src"`
+	Dst  string `json:"dst"`
+
+	// Status is StatusFailed if the migration returned an error or any item failed,
+	// StatusSkipped if it has not been started, StatusSucceeded otherwise
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// Report is the report of the migration, nil if it failed before migrating any item
+	Report *Report `json:"report,omitempty"`
+}
+
+// ReadFile reads a JSON report written by WriteFile.
+func ReadFile(path string) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := NewReport()
+	if err = json.NewDecoder(f).Decode(r); err != nil {
+		return nil, errors.Wrapf(err, "invalid report %s", path)
+	}
+	return r, nil
+}
+
+// message is the error of the migration itself, or why it has not been started.
+func (m *BatchMigration) message() string {
+	if m.Error == "" && m.Status == StatusSkipped {
+		return "not started"
+	}
+	return m.Error
+}
+
+// Failed returns how many migrations failed.
+func (b *Batch) Failed() int {
+	var n int
+	for _, m := range b.Migrations {
+		if m.Status == StatusFailed {
+			n++
+		}
+	}
+	return n
+}
+
+// Render writes a table of a row per migration.
+func (b *Batch) Render(w io.Writer) {
+	data := make([][]string, len(b.Migrations))
+	for i, m := range b.Migrations {
+		var succeeded, skipped, failed int
+		if m.Report != nil {
+			succeeded, skipped, failed = len(m.Report.SucceededResult), len(m.Report.SkippedResult), len(m.Report.FailedResult)
+		}
+		data[i] = []string{
+			m.Name, m.Type, m.Status,
+			strconv.Itoa(succeeded), strconv.Itoa(skipped), strconv.Itoa(failed), m.Error,
+		}
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Migration", "Type", "Status", "Succeeded", "Skipped", "Failed", "Error"})
+	table.SetFooter([]string{"Total", strconv.Itoa(len(b.Migrations)), fmt.Sprintf("%d failed", b.Failed()), "", "", "", ""})
+	table.SetRowLine(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+// WriteFile writes the combined report to the file in the format.
+func (b *Batch) WriteFile(path, format string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to create report file")
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return b.Write(f, format)
+}
+
+// Write writes the combined report in the format. The CSV has a leading migration column,
+// and the JUnit XML has a test suite per migration.
+func (b *Batch) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(b)
+	case FormatCSV:
+		return b.writeCSV(w)
+	case FormatJUnit:
+		return b.writeJUnit(w)
+	default:
+		return CheckFormat(format)
+	}
+}
+
+func (b *Batch) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"migration", "status", "name", "path", "bytes", "duration_ms", "message"})
+	for _, m := range b.Migrations {
+		if msg := m.message(); msg != "" {
+			_ = cw.Write([]string{m.Name, m.Status, "", "", "0", "0", msg})
+		}
+		if m.Report == nil {
+			continue
+		}
+		for _, result := range m.Report.mergeIntoOneResult(false) {
+			_ = cw.Write([]string{
+				m.Name, result.Status, result.Name, result.Path,
+				strconv.FormatInt(result.Bytes, 10), strconv.FormatInt(result.Duration, 10), result.Message,
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (b *Batch) writeJUnit(w io.Writer) error {
+	suites := make([]junitTestSuite, 0, len(b.Migrations))
+	for _, m := range b.Migrations {
+		suite := junitTestSuite{}
+		if m.Report != nil {
+			suite = m.Report.junitSuite()
+		}
+		suite.Name = "carctl migrate batch " + m.Name
+		// an error of the migration itself is a failed test case of the suite
+		if msg := m.message(); msg != "" {
+			tc := junitTestCase{Name: m.Name, ClassName: m.Type}
+			if m.Status == StatusSkipped {
+				tc.Skipped = &junitMessage{Message: msg}
+				suite.Skipped++
+			} else {
+				tc.Failure = &junitMessage{Message: msg, Content: m.Src}
+				suite.Failures++
+			}
+			suite.Tests++
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		suites = append(suites, suite)
+	}
+	return writeJUnit(w, suites)
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBatch() *Batch {
+	return &Batch{
+		Version: "0.1.5",
+		Migrations: []*BatchMigration{
+			{Name: "maven-releases", Type: "maven", Status: StatusFailed, Report: newTestReport()},
+			{Name: "npm", Type: "npm", Src: "http://nexus/repository/npm/", Status: StatusFailed, Error: "unauthorized"},
+			{Name: "pypi", Type: "pypi", Status: StatusSkipped},
+		},
+	}
+}
+
+func TestBatchWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, newTestBatch().Write(&buf, FormatCSV))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 6)
+	assert.Equal(t, []string{"maven-releases", "failed", "g:b:1.0", "g/b/1.0/b-1.0.jar", "0", "300", "500 Internal Server Error"}, records[3])
+	assert.Equal(t, []string{"npm", "failed", "", "", "0", "0", "unauthorized"}, records[4])
+	assert.Equal(t, []string{"pypi", "skipped", "", "", "0", "0", "not started"}, records[5])
+}
+
+func TestBatchWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, newTestBatch().Write(&buf, FormatJUnit))
+
+	out := buf.String()
+	assert.Contains(t, out, `<testsuite name="carctl migrate batch maven-releases" tests="3" failures="1" skipped="1" time="90"`)
+	assert.Contains(t, out, `<testsuite name="carctl migrate batch npm" tests="1" failures="1" skipped="0" time="">`)
+	assert.Contains(t, out, `<failure message="unauthorized">http://nexus/repository/npm/</failure>`)
+	assert.Contains(t, out, `<skipped message="not started"></skipped>`)
+	assert.Equal(t, 2, newTestBatch().Failed())
+}
@@ -0,0 +1,190 @@
+package types
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+	FormatJUnit = "junit"
+)
+
+// CheckFormat returns an error if format is not a supported report format.
+func CheckFormat(format string) error {
+	switch format {
+	case FormatJSON, FormatCSV, FormatJUnit:
+		return nil
+	default:
+		return errors.Errorf("unsupported report format %q, available: %s, %s, %s", format, FormatJSON, FormatCSV, FormatJUnit)
+	}
+}
+
+// WriteFile writes the report to the file in the format.
+func (r *Report) WriteFile(path, format string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to create report file")
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return r.Write(f, format)
+}
+
+// Write writes the report in the format.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return r.WriteJSON(w)
+	case FormatCSV:
+		return r.WriteCSV(w)
+	case FormatJUnit:
+		return r.WriteJUnit(w)
+	default:
+		return CheckFormat(format)
+	}
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes a row for every result, the metadata is written as leading comment lines starting with '#'.
+func (r *Report) WriteCSV(w io.Writer) error {
+	if m := r.Metadata; m != nil {
+		for _, kv := range m.pairs() {
+			if _, err := fmt.Fprintf(w, "# %s: %s\n", kv[0], kv[1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"status", "name", "path", "bytes", "duration_ms", "message"})
+	for _, result := range r.mergeIntoOneResult(false) {
+		_ = cw.Write([]string{
+			result.Status, result.Name, result.Path,
+			strconv.FormatInt(result.Bytes, 10), strconv.FormatInt(result.Duration, 10), result.Message,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type (
+	junitTestSuites struct {
+		XMLName xml.Name         `xml:"testsuites"`
+		Suites  []junitTestSuite `xml:"testsuite"`
+	}
+
+	junitTestSuite struct {
+		Name       string          `xml:"name,attr"`
+		Tests      int             `xml:"tests,attr"`
+		Failures   int             `xml:"failures,attr"`
+		Skipped    int             `xml:"skipped,attr"`
+		Time       string          `xml:"time,attr"`
+		Timestamp  string          `xml:"timestamp,attr,omitempty"`
+		Properties []junitProperty `xml:"properties>property,omitempty"`
+		TestCases  []junitTestCase `xml:"testcase"`
+	}
+
+	junitProperty struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	}
+
+	junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitMessage `xml:"failure,omitempty"`
+		Skipped   *junitMessage `xml:"skipped,omitempty"`
+	}
+
+	junitMessage struct {
+		Message string `xml:"message,attr"`
+		Content string `xml:",chardata"`
+	}
+)
+
+// WriteJUnit writes a JUnit XML test suite, every result is a test case.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	return writeJUnit(w, []junitTestSuite{r.junitSuite()})
+}
+
+func (r *Report) junitSuite() junitTestSuite {
+	suite := junitTestSuite{
+		Name:     "carctl migrate",
+		Tests:    r.TotalCount(),
+		Failures: len(r.FailedResult),
+		Skipped:  len(r.SkippedResult),
+	}
+	var className string
+	if m := r.Metadata; m != nil {
+		suite.Name = "carctl migrate " + m.Type
+		suite.Time = seconds(m.EndTime.Sub(m.StartTime).Milliseconds())
+		suite.Timestamp = m.StartTime.Format(time.RFC3339)
+		for _, kv := range m.pairs() {
+			suite.Properties = append(suite.Properties, junitProperty{Name: kv[0], Value: kv[1]})
+		}
+		className = m.Type
+	}
+
+	for _, result := range r.mergeIntoOneResult(false) {
+		tc := junitTestCase{Name: result.Name, ClassName: className, Time: seconds(result.Duration)}
+		if tc.Name == "" {
+			tc.Name = result.Path
+		}
+		switch result.Status {
+		case StatusFailed:
+			tc.Failure = &junitMessage{Message: result.Message, Content: result.Path}
+		case StatusSkipped:
+			tc.Skipped = &junitMessage{Message: result.Message}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	return suite
+}
+
+func writeJUnit(w io.Writer, suites []junitTestSuite) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: suites}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (m *Metadata) pairs() [][2]string {
+	return [][2]string{
+		{"type", m.Type},
+		{"src", m.Src},
+		{"srcType", m.SrcType},
+		{"dst", m.Dst},
+		{"startTime", m.StartTime.Format(time.RFC3339)},
+		{"endTime", m.EndTime.Format(time.RFC3339)},
+		{"version", m.Version},
+	}
+}
+
+func seconds(ms int64) string {
+	return strconv.FormatFloat(float64(ms)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,69 @@
+package types
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestReport() *Report {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := NewReport()
+	r.Metadata = &Metadata{
+		Type:      "maven",
+		Src:       "http://127.0.0.1:8081/repository/maven-releases/",
+		Dst:       "https://demo-maven.pkg.coding.net/repository/p/r/",
+		StartTime: start,
+		EndTime:   start.Add(90 * time.Second),
+		Version:   "0.1.5",
+	}
+	r.AddSucceededResultV2("g:a:1.0", "g/a/1.0/a-1.0.jar", "Succeeded", 2048, 1500)
+	r.AddSkippedResultV2("g:a:1.1", "g/a/1.1/a-1.1.jar", "409 Conflict", 1024, 20)
+	r.AddFailedResultV2("g:b:1.0", "g/b/1.0/b-1.0.jar", "500 Internal Server Error", 0, 300)
+	return r
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, newTestReport().Write(&buf, FormatJSON))
+
+	var r Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &r))
+	assert.Equal(t, "maven", r.Metadata.Type)
+	require.Len(t, r.SucceededResult, 1)
+	assert.Equal(t, StatusSucceeded, r.SucceededResult[0].Status)
+	assert.Equal(t, int64(2048), r.SucceededResult[0].Bytes)
+	assert.Equal(t, int64(1500), r.SucceededResult[0].Duration)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, newTestReport().Write(&buf, FormatCSV))
+
+	cr := csv.NewReader(&buf)
+	cr.Comment = '#'
+	records, err := cr.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"failed", "g:b:1.0", "g/b/1.0/b-1.0.jar", "0", "300", "500 Internal Server Error"}, records[3])
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, newTestReport().Write(&buf, FormatJUnit))
+
+	out := buf.String()
+	assert.Contains(t, out, `<testsuite name="carctl migrate maven" tests="3" failures="1" skipped="1" time="90"`)
+	assert.Contains(t, out, `<property name="version" value="0.1.5"></property>`)
+	assert.Contains(t, out, `<failure message="500 Internal Server Error">g/b/1.0/b-1.0.jar</failure>`)
+}
+
+func TestCheckFormat(t *testing.T) {
+	assert.NoError(t, CheckFormat(FormatJUnit))
+	assert.Error(t, CheckFormat("xml"))
+}
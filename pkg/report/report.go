@@ -5,24 +5,51 @@ import (
 	"io"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 )
 
 type Report struct {
+	Metadata *Metadata `json:"metadata,omitempty"`
+
 	SucceededResult []Result `json:"succeeded,omitempty"`
 	SkippedResult   []Result `json:"skipped,omitempty"`
 	FailedResult    []Result `json:"failed,omitempty"`
 }
 
+// Metadata is the information of a migration run.
+type Metadata struct {
+	Type      string    `json:"type"`
+	Src       string    `json:"src"`
+	SrcType   string    `json:"srcType,omitempty"`
+	Dst       string    `json:"dst"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Version   string    `json:"version"`
+}
+
 type Result struct {
 	Name    string  `json:"name"`
 	Path    string  `json:"path"`
 	Size    float64 `json:"size"`
 	Time    float64 `json:"time"`
 	Message string  `json:"message"`
+
+	// Status is one of StatusSucceeded, StatusSkipped and StatusFailed
+	Status string `json:"status"`
+	// Bytes is the file size in bytes
+	Bytes int64 `json:"bytes"`
+	// Duration is the migrate time in milliseconds
+	Duration int64 `json:"durationMs"`
 }
 
+const (
+	StatusSucceeded = "succeeded"
+	StatusSkipped   = "skipped"
+	StatusFailed    = "failed"
+)
+
 func NewReport() *Report {
 	return &Report{
 		SucceededResult: make([]Result, 0),
@@ -40,6 +67,7 @@ func (r *Report) AddSucceededResult(name, path, msg string) {
 		Name:    name,
 		Path:    path,
 		Message: msg,
+		Status:  StatusSucceeded,
 	})
 }
 
@@ -48,6 +76,7 @@ func (r *Report) AddSkippedResult(name, path, msg string) {
 		Name:    name,
 		Path:    path,
 		Message: msg,
+		Status:  StatusSkipped,
 	})
 }
 
@@ -56,39 +85,62 @@ func (r *Report) AddFailedResult(name, path, msg string) {
 		Name:    name,
 		Path:    path,
 		Message: msg,
+		Status:  StatusFailed,
 	})
 }
 
 func (r *Report) AddSucceededResultV2(name, path, msg string, size, time int64) {
 	r.SucceededResult = append(r.SucceededResult, Result{
-		Name:    name,
-		Path:    path,
-		Size:    float64(size) / 1024 / 1024,
-		Time:    float64(time) / 1000,
-		Message: msg,
+		Name:     name,
+		Path:     path,
+		Size:     float64(size) / 1024 / 1024,
+		Time:     float64(time) / 1000,
+		Message:  msg,
+		Status:   StatusSucceeded,
+		Bytes:    size,
+		Duration: time,
 	})
 }
 
 func (r *Report) AddSkippedResultV2(name, path, msg string, size, time int64) {
 	r.SkippedResult = append(r.SkippedResult, Result{
-		Name:    name,
-		Path:    path,
-		Size:    float64(size) / 1024 / 1024,
-		Time:    float64(time) / 1000,
-		Message: msg,
+		Name:     name,
+		Path:     path,
+		Size:     float64(size) / 1024 / 1024,
+		Time:     float64(time) / 1000,
+		Message:  msg,
+		Status:   StatusSkipped,
+		Bytes:    size,
+		Duration: time,
 	})
 }
 
 func (r *Report) AddFailedResultV2(name, path, msg string, size, time int64) {
 	r.FailedResult = append(r.FailedResult, Result{
-		Name:    name,
-		Path:    path,
-		Size:    float64(size) / 1024 / 1024,
-		Time:    float64(time) / 1000,
-		Message: msg,
+		Name:     name,
+		Path:     path,
+		Size:     float64(size) / 1024 / 1024,
+		Time:     float64(time) / 1000,
+		Message:  msg,
+		Status:   StatusFailed,
+		Bytes:    size,
+		Duration: time,
 	})
 }
 
+// MarkFailed moves the succeeded result of path to the failed results.
+func (r *Report) MarkFailed(path, msg string) {
+	for i, result := range r.SucceededResult {
+		if result.Path == path {
+			r.SucceededResult = append(r.SucceededResult[:i], r.SucceededResult[i+1:]...)
+			result.Status = StatusFailed
+			result.Message = msg
+			r.FailedResult = append(r.FailedResult, result)
+			return
+		}
+	}
+}
+
 func (r *Report) Render(w io.Writer) {
 	totalResult := r.mergeIntoOneResult(true)
 	size := len(totalResult)
@@ -136,7 +188,9 @@ func (r *Report) RenderV2(w io.Writer) {
 }
 
 func (r *Report) mergeIntoOneResult(sortByName bool) []Result {
-	totalResult := append(r.SucceededResult, r.SkippedResult...)
+	totalResult := make([]Result, 0, r.TotalCount())
+	totalResult = append(totalResult, r.SucceededResult...)
+	totalResult = append(totalResult, r.SkippedResult...)
 	totalResult = append(totalResult, r.FailedResult...)
 
 	if sortByName {
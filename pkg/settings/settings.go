@@ -57,11 +57,81 @@ var (
 	// Prefix use to filter generic artifacts.
 	Prefix string
 
+	// Include are glob or regex patterns of paths or coordinates, only matched artifacts are migrated.
+	Include []string
+
+	// Exclude are glob or regex patterns of paths or coordinates, matched artifacts are not migrated.
+	Exclude []string
+
+	// ModifiedAfter selects artifacts modified after the time, e.g. 2023-01-02 or 30d ago.
+	ModifiedAfter string
+
+	// ModifiedBefore selects artifacts modified before the time.
+	ModifiedBefore string
+
+	// DownloadedSince selects artifacts downloaded since the time.
+	DownloadedSince string
+
+	// TagInclude are regexes of docker tags, only matched tags are migrated.
+	TagInclude []string
+
+	// TagExclude are regexes of docker tags, matched tags are not migrated.
+	TagExclude []string
+
+	// TagSemver is a semver range of docker tags, e.g. >=1.0.0, the matched tags are migrated.
+	TagSemver string
+
+	// KeepLast migrates the newest N tags of each docker image besides the TagSemver ones, 0 means all.
+	KeepLast int
+
+	// NameMap is the file of the rules which map the source docker image names to the destination ones.
+	NameMap string
+
 	// DryRun is print need migrate artifacts
 	DryRun bool
 
+	// LargeFileMode uploads all the generic files in chunks.
 	LargeFileMode bool
 
+	// ChunkThreshold is the size above which generic files are uploaded in chunks, e.g. 100MiB.
+	ChunkThreshold string
+
+	// ChunkSize is the size of a chunk of the generic files uploaded in chunks, e.g. 8MiB.
+	ChunkSize string
+
+	// ChunkConcurrency is the number of chunks of a file uploaded in parallel.
+	ChunkConcurrency int
+
+	// Journal is the file path where the progress of migration is recorded.
+	Journal string
+
+	// Resume is the journal path of an interrupted migration to continue.
+	Resume string
+
+	// ReportFile is the file path where the migration report is written.
+	ReportFile string
+
+	// ReportFormat is the format of ReportFile, [json,csv,junit]
+	ReportFormat string
+
+	// Verify controls whether checksums of uploaded files are verified.
+	Verify bool
+
+	// Retries is the max number of retries of a failed request or transfer.
+	Retries int
+
+	// RetryBackoff is the delay before the first retry, it doubles on every next retry.
+	RetryBackoff time.Duration
+
+	// MaxBandwidth limits the bytes per second of all transfers, e.g. 50MiB/s.
+	MaxBandwidth string
+
+	// MaxRPS limits the requests per second to every host.
+	MaxRPS float64
+
+	// Version is the carctl version.
+	Version string
+
 	//
 	DropInvalidKey []string
 )
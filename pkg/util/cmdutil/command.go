@@ -21,16 +21,17 @@ func PreRun(cmd *cobra.Command, args []string) {
 	}
 }
 
-func Command(c string) (output, errOutput string, err error) {
+// Command runs c with the shell, the process is killed if ctx is done before it exits.
+func Command(ctx context.Context, c string) (output, errOutput string, err error) {
 	if settings.Verbose {
 		log.Debug(c)
 	}
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command("cmd.exe", "/c", c)
+		cmd = exec.CommandContext(ctx, "cmd.exe", "/c", c)
 	case "linux", "darwin":
-		cmd = exec.Command("bash", "-c", c)
+		cmd = exec.CommandContext(ctx, "bash", "-c", c)
 	}
 
 	// 显示运行的命令
@@ -44,14 +45,17 @@ func Command(c string) (output, errOutput string, err error) {
 	}
 	var wg sync.WaitGroup
 	wg.Add(2)
-	go read(context.Background(), &wg, stderr, &errOutput)
-	go read(context.Background(), &wg, stdout, &output)
+	go read(ctx, &wg, stderr, &errOutput)
+	go read(ctx, &wg, stdout, &output)
 	err = cmd.Start()
 	if err != nil {
 		return output, errOutput, errors.Wrapf(err, "exec cmd failed")
 	}
 	wg.Wait()
 	_ = cmd.Wait()
+	if ctx.Err() != nil {
+		return output, errOutput, ctx.Err()
+	}
 	if !cmd.ProcessState.Success() {
 		// 执行失败，返回错误信息
 		return output, errOutput, errors.New("failed")
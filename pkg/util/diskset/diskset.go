@@ -0,0 +1,257 @@
+// Package diskset provides string sets which are kept in memory while they are small,
+// and spilled to a sorted on-disk index of key hashes when they grow large,
+// e.g. the file paths of a destination repository with millions of files.
+package diskset
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// hashSize is the size of a key hash in the index, collisions of 128 bits are negligible.
+	hashSize = 16
+
+	bucketCount = 256
+)
+
+// DefaultThreshold is the count of keys above which a Builder spills to disk.
+var DefaultThreshold = 500000
+
+// Set is a read-only set of strings, safe for concurrent use.
+type Set interface {
+	Has(key string) bool
+	Len() int
+}
+
+// Map is an in-memory Set.
+type Map map[string]bool
+
+func (m Map) Has(key string) bool {
+	return m[key]
+}
+
+func (m Map) Len() int {
+	return len(m)
+}
+
+// Keys returns the keys of a Map, or nil if s is a DiskSet.
+func Keys(s Set) []string {
+	m, ok := s.(Map)
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k, v := range m {
+		if v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DiskSet is a Set of the sorted key hashes in a file, which is searched with binary search.
+type DiskSet struct {
+	f    *os.File
+	path string
+	n    int64
+}
+
+// Open opens the index file created by a Builder.
+func Open(path string) (*DiskSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open index")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "failed to stat index")
+	}
+	if info.Size()%hashSize != 0 {
+		_ = f.Close()
+		return nil, errors.Errorf("invalid index %s: size %d", path, info.Size())
+	}
+	return &DiskSet{f: f, path: path, n: info.Size() / hashSize}, nil
+}
+
+func (s *DiskSet) Has(key string) bool {
+	h := hashOf(key)
+	buf := make([]byte, hashSize)
+	i := sort.Search(int(s.n), func(i int) bool {
+		if _, err := s.f.ReadAt(buf, int64(i)*hashSize); err != nil {
+			return true
+		}
+		return bytes.Compare(buf, h[:]) >= 0
+	})
+	if int64(i) >= s.n {
+		return false
+	}
+	if _, err := s.f.ReadAt(buf, int64(i)*hashSize); err != nil {
+		return false
+	}
+	return bytes.Equal(buf, h[:])
+}
+
+func (s *DiskSet) Len() int {
+	return int(s.n)
+}
+
+// Path returns the path of the index file.
+func (s *DiskSet) Path() string {
+	return s.path
+}
+
+func (s *DiskSet) Close() error {
+	return s.f.Close()
+}
+
+// Builder builds a Set. Keys are kept in a Map until their count exceeds the threshold,
+// and then the hashes are partitioned into bucket files, which are sorted one by one into the index.
+type Builder struct {
+	path      string
+	threshold int
+
+	m       Map
+	buckets []*bucket
+	n       int64
+}
+
+type bucket struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewBuilder returns a Builder which writes the index to path if the keys exceed threshold.
+func NewBuilder(path string, threshold int) *Builder {
+	return &Builder{path: path, threshold: threshold, m: make(Map)}
+}
+
+// Add adds key to the set.
+func (b *Builder) Add(key string) error {
+	if b.buckets == nil {
+		b.m[key] = true
+		if len(b.m) <= b.threshold {
+			return nil
+		}
+		// spill to disk
+		if err := b.createBuckets(); err != nil {
+			return err
+		}
+		for k := range b.m {
+			if err := b.write(k); err != nil {
+				return err
+			}
+		}
+		b.m = nil
+		return nil
+	}
+	return b.write(key)
+}
+
+func (b *Builder) createBuckets() error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create index dir")
+	}
+	b.buckets = make([]*bucket, bucketCount)
+	for i := range b.buckets {
+		f, err := os.CreateTemp(filepath.Dir(b.path), filepath.Base(b.path)+".bucket-*")
+		if err != nil {
+			b.removeBuckets()
+			return errors.Wrap(err, "failed to create index bucket")
+		}
+		b.buckets[i] = &bucket{f: f, w: bufio.NewWriter(f)}
+	}
+	return nil
+}
+
+func (b *Builder) write(key string) error {
+	h := hashOf(key)
+	if _, err := b.buckets[h[0]].w.Write(h[:]); err != nil {
+		return errors.Wrap(err, "failed to write index bucket")
+	}
+	b.n++
+	return nil
+}
+
+// Build returns a Map if the keys don't exceed the threshold, otherwise a DiskSet of the index file.
+func (b *Builder) Build() (Set, error) {
+	if b.buckets == nil {
+		return b.m, nil
+	}
+	defer b.removeBuckets()
+
+	out, err := os.Create(b.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create index")
+	}
+	w := bufio.NewWriter(out)
+	for _, bk := range b.buckets {
+		if err = bk.sortTo(w); err != nil {
+			_ = out.Close()
+			return nil, err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		_ = out.Close()
+		return nil, errors.Wrap(err, "failed to write index")
+	}
+	if err = out.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to write index")
+	}
+	return Open(b.path)
+}
+
+// sortTo writes the sorted unique hashes of the bucket to w.
+func (bk *bucket) sortTo(w io.Writer) error {
+	if err := bk.w.Flush(); err != nil {
+		return errors.Wrap(err, "failed to write index bucket")
+	}
+	if _, err := bk.f.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "failed to read index bucket")
+	}
+	data, err := io.ReadAll(bk.f)
+	if err != nil {
+		return errors.Wrap(err, "failed to read index bucket")
+	}
+
+	hashes := make([][]byte, 0, len(data)/hashSize)
+	for i := 0; i+hashSize <= len(data); i += hashSize {
+		hashes = append(hashes, data[i:i+hashSize])
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i], hashes[j]) < 0 })
+	for i, h := range hashes {
+		if i > 0 && bytes.Equal(h, hashes[i-1]) {
+			continue
+		}
+		if _, err = w.Write(h); err != nil {
+			return errors.Wrap(err, "failed to write index")
+		}
+	}
+	return nil
+}
+
+func (b *Builder) removeBuckets() {
+	for _, bk := range b.buckets {
+		if bk != nil {
+			_ = bk.f.Close()
+			_ = os.Remove(bk.f.Name())
+		}
+	}
+	b.buckets = nil
+}
+
+func hashOf(key string) [hashSize]byte {
+	sum := sha256.Sum256([]byte(key))
+	var h [hashSize]byte
+	copy(h[:], sum[:])
+	return h
+}
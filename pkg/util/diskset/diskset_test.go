@@ -0,0 +1,59 @@
+package diskset
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilderMap(t *testing.T) {
+	b := NewBuilder(filepath.Join(t.TempDir(), "exists.index"), 10)
+	require.NoError(t, b.Add("g/a/1.0/a-1.0.jar"))
+	require.NoError(t, b.Add("g/a/1.0/a-1.0.pom"))
+
+	s, err := b.Build()
+	require.NoError(t, err)
+	assert.IsType(t, Map{}, s)
+	assert.Equal(t, 2, s.Len())
+	assert.True(t, s.Has("g/a/1.0/a-1.0.jar"))
+	assert.False(t, s.Has("g/a/1.1/a-1.1.jar"))
+	assert.Equal(t, []string{"g/a/1.0/a-1.0.jar", "g/a/1.0/a-1.0.pom"}, Keys(s))
+}
+
+func TestBuilderDisk(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "exists.index")
+	b := NewBuilder(path, 100)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, b.Add(fmt.Sprintf("file-%d", i)))
+	}
+	// duplicates are counted once
+	require.NoError(t, b.Add("file-1"))
+
+	s, err := b.Build()
+	require.NoError(t, err)
+	ds, ok := s.(*DiskSet)
+	require.True(t, ok)
+	defer func() { _ = ds.Close() }()
+
+	assert.Equal(t, 1000, s.Len())
+	for i := 0; i < 1000; i++ {
+		assert.True(t, s.Has(fmt.Sprintf("file-%d", i)))
+	}
+	assert.False(t, s.Has("file-1000"))
+	assert.Nil(t, Keys(s))
+
+	// only the index is left
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	reopened, err := Open(ds.Path())
+	require.NoError(t, err)
+	defer func() { _ = reopened.Close() }()
+	assert.True(t, reopened.Has("file-999"))
+}
@@ -1,6 +1,7 @@
 package httputil
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/url"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/coding-wepack/carctl/pkg/util/ioutils"
+	"github.com/coding-wepack/carctl/pkg/util/ratelimit"
 	"github.com/pkg/errors"
 )
 
@@ -17,23 +19,31 @@ var (
 		Timeout: time.Minute * 15,
 	}
 
-	DefaultClient = &Client{client: defaultHttpClient}
+	// DefaultHostLimiter limits the requests per second per host, it is configured by the --max-rps flag.
+	DefaultHostLimiter = ratelimit.NewHostLimiter(0)
+
+	DefaultClient = &Client{client: defaultHttpClient, retry: DefaultRetryPolicy, limiter: DefaultHostLimiter}
 )
 
 type Client struct {
 	client *http.Client
+	// retry retries the requests without body, e.g. GET. Uploads are retried
+	// by the caller because their bodies can't be replayed.
+	retry *RetryPolicy
+	// limiter limits the request rate of every host, nil means unlimited.
+	limiter *ratelimit.HostLimiter
 }
 
-func (c *Client) Get(url string) (resp *http.Response, err error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func (c *Client) Get(ctx context.Context, url string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 	return c.Do(req)
 }
 
-func (c *Client) GetWithAuth(url, username, password string) (resp *http.Response, err error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func (c *Client) GetWithAuth(ctx context.Context, url, username, password string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -41,8 +51,8 @@ func (c *Client) GetWithAuth(url, username, password string) (resp *http.Respons
 	return c.Do(req)
 }
 
-func (c *Client) Post(url, contentType string, body io.Reader, username, password string) (resp *http.Response, err error) {
-	req, err := http.NewRequest(http.MethodPost, url, body)
+func (c *Client) Post(ctx context.Context, url, contentType string, body io.Reader, username, password string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -58,8 +68,8 @@ func (c *Client) Post(url, contentType string, body io.Reader, username, passwor
 	return c.Do(req)
 }
 
-func (c *Client) PostWithHeader(url, contentType string, body io.Reader, username, password string, headers map[string]string) (resp *http.Response, err error) {
-	req, err := http.NewRequest(http.MethodPost, url, body)
+func (c *Client) PostWithHeader(ctx context.Context, url, contentType string, body io.Reader, username, password string, headers map[string]string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -79,16 +89,16 @@ func (c *Client) PostWithHeader(url, contentType string, body io.Reader, usernam
 	return c.Do(req)
 }
 
-func (c *Client) PostJson(url string, body io.Reader, username, password string) (resp *http.Response, err error) {
-	return c.Post(url, "application/json;charset=UTF-8", body, username, password)
+func (c *Client) PostJson(ctx context.Context, url string, body io.Reader, username, password string) (resp *http.Response, err error) {
+	return c.Post(ctx, url, "application/json;charset=UTF-8", body, username, password)
 }
 
-func (c *Client) PostForm(url string, data url.Values, username, password string) (resp *http.Response, err error) {
-	return c.Post(url, "application/x-www-form-urlencoded;charset=UTF-8", strings.NewReader(data.Encode()), username, password)
+func (c *Client) PostForm(ctx context.Context, url string, data url.Values, username, password string) (resp *http.Response, err error) {
+	return c.Post(ctx, url, "application/x-www-form-urlencoded;charset=UTF-8", strings.NewReader(data.Encode()), username, password)
 }
 
-func (c *Client) Put(url, contentType string, body io.Reader, username, password string) (resp *http.Response, err error) {
-	req, err := http.NewRequest(http.MethodPut, url, body)
+func (c *Client) Put(ctx context.Context, url, contentType string, body io.Reader, username, password string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -104,22 +114,22 @@ func (c *Client) Put(url, contentType string, body io.Reader, username, password
 	return c.Do(req)
 }
 
-func (c *Client) PutJson(url string, body io.Reader, username, password string) (resp *http.Response, err error) {
-	return c.Put(url, "application/json;charset=UTF-8", body, username, password)
+func (c *Client) PutJson(ctx context.Context, url string, body io.Reader, username, password string) (resp *http.Response, err error) {
+	return c.Put(ctx, url, "application/json;charset=UTF-8", body, username, password)
 }
 
-func (c *Client) PutFile(url, file, username, password string) (resp *http.Response, err error) {
+func (c *Client) PutFile(ctx context.Context, url, file, username, password string) (resp *http.Response, err error) {
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
 	defer ioutils.QuiteClose(f)
 
-	return c.Put(url, "", f, username, password)
+	return c.Put(ctx, url, "", f, username, password)
 }
 
-func (c *Client) Patch(url, contentType string, body io.Reader) (resp *http.Response, err error) {
-	req, err := http.NewRequest(http.MethodPatch, url, body)
+func (c *Client) Patch(ctx context.Context, url, contentType string, body io.Reader) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -136,11 +146,38 @@ func (c *Client) Do(req *http.Request) (resp *http.Response, err error) {
 		return nil, errors.New("nil pointer exception: parameter 'req' is nil")
 	}
 
-	return c.client.Do(req)
+	if c.retry == nil || (req.Body != nil && req.Body != http.NoBody) {
+		if err = c.limiter.Wait(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+		return c.client.Do(req)
+	}
+
+	err = c.retry.Do(req.Context(), func() error {
+		if err := c.limiter.Wait(req.Context(), req.URL.Host); err != nil {
+			return err
+		}
+		resp, err = c.client.Do(req)
+		if err != nil || !IsRetryableStatus(resp.StatusCode) {
+			return err
+		}
+		// keep the response of the last attempt for the caller
+		statusErr := NewStatusError(resp)
+		ioutils.QuiteClose(resp.Body)
+		resp.Body = io.NopCloser(strings.NewReader(statusErr.Body))
+		return statusErr
+	}, nil)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return resp, nil
+	}
+	return resp, err
 }
 
 func New() *Client {
 	return &Client{
-		client: defaultHttpClient,
+		client:  defaultHttpClient,
+		retry:   DefaultRetryPolicy,
+		limiter: DefaultHostLimiter,
 	}
 }
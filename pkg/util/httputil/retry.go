@@ -0,0 +1,164 @@
+package httputil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const maxErrorBodySize = 4 << 10
+
+// RetryPolicy retries failed operations with exponential backoff and jitter.
+type RetryPolicy struct {
+	// Retries is the max number of retries after the first attempt.
+	Retries int
+	// Backoff is the delay before the first retry, it doubles on every next retry.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between two attempts, except the delay of Retry-After.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used by DefaultClient and the migrations,
+// it is configured by the --retries and --retry-backoff flags.
+var DefaultRetryPolicy = &RetryPolicy{Retries: 2, Backoff: time.Second, MaxBackoff: time.Minute}
+
+type withoutRetryKey struct{}
+
+// WithoutRetry returns a context whose operations are attempted only once by RetryPolicy.Do,
+// e.g. the requests of an operation which is retried as a whole by the caller, so that the
+// retries are not nested.
+func WithoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutRetryKey{}, true)
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, the retries are exhausted,
+// or ctx is done. notify is called before every retry if it is not nil.
+// fn is called only once if ctx is a WithoutRetry context.
+func (p *RetryPolicy) Do(ctx context.Context, fn func() error, notify func(err error, delay time.Duration)) (err error) {
+	retries := p.Retries
+	if ctx.Value(withoutRetryKey{}) != nil {
+		retries = 0
+	}
+	for retry := 0; ; retry++ {
+		if err = fn(); err == nil || retry >= retries || !IsRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+		delay := p.Delay(retry, err)
+		if notify != nil {
+			notify(err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Delay returns the delay before the retry (starting from 0) of err.
+// The Retry-After of a StatusError is honoured, otherwise the backoff is
+// doubled on every retry, capped by MaxBackoff, with a random jitter of up to half of it.
+func (p *RetryPolicy) Delay(retry int, err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter
+	}
+
+	d := p.Backoff
+	for i := 0; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// StatusError is an unexpected http response status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	// RetryAfter is the delay of the Retry-After header, 0 if absent.
+	RetryAfter time.Duration
+}
+
+// NewStatusError reads the head of the response body into a StatusError.
+func NewStatusError(resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("got an unexpected response status: %s", e.Status)
+	}
+	return fmt.Sprintf("got an unexpected response status: %s, resp: %s", e.Status, e.Body)
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, which is either
+// seconds or a http date. It returns 0 if the value is empty or invalid.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// IsRetryableStatus reports whether a request with the response status code is worth retrying.
+func IsRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// IsRetryable reports whether err is transient: a 5xx or 429 StatusError,
+// a connection reset or refused, an unexpected EOF, a connection closed by the server
+// before the response, or a timeout. A bare io.EOF is the end of a body, which is not retried.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return IsRetryableStatus(statusErr.StatusCode)
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Err == io.EOF {
+		// e.g. an idle connection closed by the server
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,105 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 120*time.Second, ParseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, ParseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, ParseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Zero(t, ParseRetryAfter("", now))
+	assert.Zero(t, ParseRetryAfter("soon", now))
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(&StatusError{StatusCode: http.StatusServiceUnavailable}))
+	assert.True(t, IsRetryable(errors.Wrap(&StatusError{StatusCode: http.StatusTooManyRequests}, "push")))
+	assert.True(t, IsRetryable(errors.Wrap(syscall.ECONNRESET, "push")))
+	assert.True(t, IsRetryable(io.ErrUnexpectedEOF))
+	assert.True(t, IsRetryable(errors.Wrap(&url.Error{Op: "Put", URL: "http://localhost", Err: io.EOF}, "push")))
+	assert.False(t, IsRetryable(io.EOF))
+	assert.False(t, IsRetryable(errors.Wrap(io.EOF, "read body")))
+	assert.False(t, IsRetryable(&StatusError{StatusCode: http.StatusUnauthorized}))
+	assert.False(t, IsRetryable(errors.New("invalid path")))
+	assert.False(t, IsRetryable(nil))
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &RetryPolicy{Retries: 5, Backoff: time.Second, MaxBackoff: 4 * time.Second}
+	for retry, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		d := p.Delay(retry, errors.New("reset"))
+		assert.GreaterOrEqual(t, d, max/2)
+		assert.LessOrEqual(t, d, max)
+	}
+	assert.Equal(t, time.Minute, p.Delay(0, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}))
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := &RetryPolicy{Retries: 2}
+
+	var calls int
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return &StatusError{StatusCode: http.StatusBadGateway}
+	}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = p.Do(context.Background(), func() error {
+		calls++
+		return &StatusError{StatusCode: http.StatusNotFound}
+	}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	err = p.Do(WithoutRetry(context.Background()), func() error {
+		calls++
+		return &StatusError{StatusCode: http.StatusBadGateway}
+	}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "the caller retries")
+}
+
+func TestClientRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c := &Client{client: server.Client(), retry: &RetryPolicy{Retries: 2}}
+	resp, err := c.Get(context.Background(), server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", string(body))
+	assert.EqualValues(t, 3, calls)
+
+	atomic.StoreInt32(&calls, -10)
+	resp, err = c.Get(context.Background(), server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -1,21 +1,37 @@
 package queueutil
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 )
 
-func Producer[T any](files []T, out chan<- T) {
+// Producer sends files to out until all are sent or ctx is done, and then closes out.
+func Producer[T any](ctx context.Context, files []T, out chan<- T) {
 	defer close(out)
 	for _, file := range files {
-		out <- file
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case out <- file:
+		}
 	}
 }
 
-func Consumer[T any](in <-chan T, ec chan<- error, wg *sync.WaitGroup, count *int32, fn func(file T) error) {
+// Consumer calls fn with the files of in until in is closed or ctx is done,
+// errors of fn are sent to ec. A file which is being processed is not interrupted by ctx.
+func Consumer[T any](ctx context.Context, in <-chan T, ec chan<- error, wg *sync.WaitGroup, count *int32, fn func(file T) error) {
 	defer wg.Done()
 	for {
+		if ctx.Err() != nil {
+			return
+		}
 		select {
+		case <-ctx.Done():
+			return
 		case file, ok := <-in:
 			if !ok {
 				return
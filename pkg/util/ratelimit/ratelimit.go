@@ -0,0 +1,197 @@
+// Package ratelimit throttles bandwidth and request rates with token buckets.
+// All methods of a nil *Limiter or *HostLimiter are no-ops, which means unlimited.
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limiter is a token bucket shared by goroutines.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter of rate tokens per second, which allows bursts of up to burst tokens.
+// It returns nil if rate is not positive.
+func New(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// WaitN blocks until n tokens are available or ctx is done. n may be greater than the burst,
+// the tokens are reserved at once and the waiting time is proportional to n.
+// The tokens are given back if ctx is done before they are available.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+const maxBlockSize = 32 << 10
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *Limiter
+}
+
+// NewReader returns a reader of r whose throughput is limited by l, every byte is a token.
+// A read waiting for the tokens fails with the error of ctx once it's done.
+func NewReader(ctx context.Context, r io.Reader, l *Limiter) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, l: l}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	// read small blocks, so that the workers sharing the limiter take turns
+	if len(p) > maxBlockSize {
+		p = p[:maxBlockSize]
+	}
+	n, err := r.r.Read(p)
+	if waitErr := r.l.WaitN(r.ctx, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+// HostLimiter limits the request rate of every host separately.
+type HostLimiter struct {
+	mu    sync.Mutex
+	rps   float64
+	hosts map[string]*Limiter
+}
+
+// NewHostLimiter returns a limiter of rps requests per second per host.
+func NewHostLimiter(rps float64) *HostLimiter {
+	return &HostLimiter{rps: rps}
+}
+
+// SetRate changes the requests per second per host, 0 means unlimited.
+func (h *HostLimiter) SetRate(rps float64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rps = rps
+	h.hosts = nil
+}
+
+// Wait blocks until a request to host is allowed or ctx is done.
+func (h *HostLimiter) Wait(ctx context.Context, host string) error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	if h.rps <= 0 {
+		h.mu.Unlock()
+		return nil
+	}
+	l, ok := h.hosts[host]
+	if !ok {
+		if h.hosts == nil {
+			h.hosts = make(map[string]*Limiter)
+		}
+		l = New(h.rps, 1)
+		h.hosts[host] = l
+	}
+	h.mu.Unlock()
+
+	return l.Wait(ctx)
+}
+
+var bandwidthRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]*?)(?:/s)?$`)
+
+var units = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1e3,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1e6,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1e9,
+	"gib": 1 << 30,
+}
+
+// ParseBandwidth parses a bandwidth like "50MiB/s", "500KB/s" or "1048576" into bytes per second.
+// The units are B, KB, MB, GB in powers of 1000, and K, KiB, M, MiB, G, GiB in powers of 1024.
+func ParseBandwidth(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	match := bandwidthRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return 0, errors.Errorf("invalid bandwidth %q, e.g., 50MiB/s", s)
+	}
+	unit, ok := units[strings.ToLower(match[2])]
+	if !ok {
+		return 0, errors.Errorf("invalid bandwidth unit %q of %q, available: B, KB, KiB, MB, MiB, GB, GiB", match[2], s)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid bandwidth %q", s)
+	}
+	return int64(value * unit), nil
+}
+
+// ParseSize parses a size like "100MiB", "8MB" or "1048576" into bytes, in the units of ParseBandwidth.
+func ParseSize(s string) (int64, error) {
+	if strings.HasSuffix(strings.TrimSpace(s), "/s") {
+		return 0, errors.Errorf("invalid size %q, e.g., 100MiB", s)
+	}
+	return ParseBandwidth(s)
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"":         0,
+		"1048576":  1 << 20,
+		"50MiB/s":  50 << 20,
+		"500KB/s":  500 * 1000,
+		"1.5G":     3 << 29,
+		"100 mb/s": 100 * 1000 * 1000,
+	} {
+		actual, err := ParseBandwidth(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, actual, s)
+	}
+
+	for _, s := range []string{"fast", "10TB/s", "-1MB/s"} {
+		_, err := ParseBandwidth(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	size, err := ParseSize("8MiB")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8<<20), size)
+
+	_, err = ParseSize("8MiB/s")
+	assert.Error(t, err)
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	assert.NoError(t, l.WaitN(context.Background(), 1<<30))
+	assert.Nil(t, New(0, 1))
+
+	r := bytes.NewReader([]byte("hello"))
+	assert.Same(t, r, NewReader(context.Background(), r, nil))
+
+	var h *HostLimiter
+	assert.NoError(t, h.Wait(context.Background(), "localhost"))
+	h.SetRate(1)
+}
+
+func TestReader(t *testing.T) {
+	// 4KiB at 8KiB/s with a burst of 1KiB takes about 375ms
+	l := New(8<<10, 1<<10)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, NewReader(context.Background(), bytes.NewReader(make([]byte, 4<<10)), l))
+	require.NoError(t, err)
+	assert.EqualValues(t, 4<<10, n)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+}
+
+func TestHostLimiter(t *testing.T) {
+	h := NewHostLimiter(20)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, h.Wait(context.Background(), "a.example.com"))
+	}
+	// the other host is not limited by the requests to a.example.com
+	require.NoError(t, h.Wait(context.Background(), "b.example.com"))
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 90*time.Millisecond)
+	assert.Less(t, elapsed, 500*time.Millisecond)
+
+	h.SetRate(0)
+	start = time.Now()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, h.Wait(context.Background(), "a.example.com"))
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestWaitCanceled(t *testing.T) {
+	// a second is needed for the tokens, which is aborted by the cancellation
+	l := New(1<<10, 1<<10)
+	require.NoError(t, l.WaitN(context.Background(), 1<<10))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, l.WaitN(ctx, 1<<10), context.DeadlineExceeded)
+	_, err := io.Copy(io.Discard, NewReader(ctx, bytes.NewReader(make([]byte, 1<<10)), l))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	h := NewHostLimiter(1)
+	require.NoError(t, h.Wait(context.Background(), "a.example.com"))
+	assert.ErrorIs(t, h.Wait(ctx, "a.example.com"), context.DeadlineExceeded)
+}
@@ -0,0 +1,215 @@
+// Package semver parses semantic versions like `v1.2.3-rc.1` and matches them against
+// range constraints like `>=1.0.0 <2`, `^1.2` or `~1.2.3 || >=3`.
+package semver
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Version is a semantic version, the minor and the patch may be omitted, e.g. `1.2` is `1.2.0`.
+type Version struct {
+	Major, Minor, Patch int64
+	// Pre are the dot separated identifiers of the pre-release
+	Pre []string
+}
+
+// Parse parses a version with an optional `v` prefix, the build metadata is ignored.
+func Parse(s string) (Version, error) {
+	var v Version
+	raw := s
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		if s[i+1:] == "" {
+			return v, errors.Errorf("invalid semantic version %q", raw)
+		}
+		v.Pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, errors.Errorf("invalid semantic version %q", raw)
+	}
+	core := []*int64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 || part[0] == '+' {
+			return v, errors.Errorf("invalid semantic version %q", raw)
+		}
+		*core[i] = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to or greater than o,
+// a pre-release is less than its release.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int64{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case len(v.Pre) == 0 && len(o.Pre) == 0:
+		return 0
+	case len(v.Pre) == 0:
+		return 1
+	case len(o.Pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Pre) && i < len(o.Pre); i++ {
+		if c := comparePre(v.Pre[i], o.Pre[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(int64(len(v.Pre) - len(o.Pre)))
+}
+
+// comparePre compares pre-release identifiers, numeric ones are less than alphanumeric ones.
+func comparePre(a, b string) int {
+	an, aErr := strconv.ParseInt(a, 10, 64)
+	bn, bErr := strconv.ParseInt(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return sign(an - bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(d int64) int {
+	switch {
+	case d < 0:
+		return -1
+	case d > 0:
+		return 1
+	}
+	return 0
+}
+
+// Constraint is a union of ranges, a range is an intersection of comparisons.
+type Constraint struct {
+	ranges [][]comparison
+	// pre is true if a comparison has a pre-release, otherwise pre-releases never match
+	pre bool
+}
+
+type comparison struct {
+	op string
+	v  Version
+}
+
+var operators = []string{">=", "<=", "!=", ">", "<", "=", "^", "~"}
+
+// ParseConstraint parses the ranges separated by `||`, the comparisons of a range are separated
+// by spaces or commas, and the operators are `=`, `!=`, `>`, `>=`, `<`, `<=`, `^` and `~`.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{}
+	for _, raw := range strings.Split(s, "||") {
+		fields := strings.Fields(strings.ReplaceAll(raw, ",", " "))
+		if len(fields) == 0 {
+			return nil, errors.Errorf("invalid semver constraint %q", s)
+		}
+		var r []comparison
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			if isOperator(field) && i+1 < len(fields) {
+				// e.g. `>= 1.0`
+				i++
+				field += fields[i]
+			}
+			cmps, err := parseComparison(field)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid semver constraint %q", s)
+			}
+			r = append(r, cmps...)
+		}
+		for _, cmp := range r {
+			c.pre = c.pre || len(cmp.v.Pre) != 0
+		}
+		c.ranges = append(c.ranges, r)
+	}
+	return c, nil
+}
+
+func isOperator(s string) bool {
+	for _, op := range operators {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseComparison parses a comparison, `^` and `~` are expanded to a `>=` and a `<`.
+func parseComparison(s string) ([]comparison, error) {
+	op := "="
+	for _, o := range operators {
+		if strings.HasPrefix(s, o) {
+			op, s = o, s[len(o):]
+			break
+		}
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "^":
+		upper := Version{Major: v.Major + 1}
+		if v.Major == 0 {
+			upper = Version{Minor: v.Minor + 1}
+		}
+		return []comparison{{">=", v}, {"<", upper}}, nil
+	case "~":
+		return []comparison{{">=", v}, {"<", Version{Major: v.Major, Minor: v.Minor + 1}}}, nil
+	}
+	return []comparison{{op, v}}, nil
+}
+
+// Check reports whether v satisfies any range of the constraint.
+func (c *Constraint) Check(v Version) bool {
+	if len(v.Pre) != 0 && !c.pre {
+		return false
+	}
+	for _, r := range c.ranges {
+		ok := true
+		for _, cmp := range r {
+			if !cmp.check(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmp comparison) check(v Version) bool {
+	c := v.Compare(cmp.v)
+	switch cmp.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.2.3-rc.1+build.5")
+	require.NoError(t, err)
+	assert.Equal(t, Version{Major: 1, Minor: 2, Patch: 3, Pre: []string{"rc", "1"}}, v)
+
+	v, err = Parse("1.2")
+	require.NoError(t, err)
+	assert.Equal(t, Version{Major: 1, Minor: 2}, v)
+
+	for _, s := range []string{"", "latest", "1.2.3.4", "1.x", "1.2.3-", "main-1a2b3c", "-1.0"} {
+		_, err = Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestVersion_Compare(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.2", "2"}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, _ := Parse(ordered[i])
+		b, _ := Parse(ordered[i+1])
+		assert.Equal(t, -1, a.Compare(b), "%s < %s", ordered[i], ordered[i+1])
+		assert.Equal(t, 1, b.Compare(a))
+	}
+	a, _ := Parse("v1.0")
+	b, _ := Parse("1.0.0")
+	assert.Equal(t, 0, a.Compare(b))
+}
+
+func TestConstraint_Check(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		mismatches []string
+	}{
+		{">=1.0.0", []string{"1.0.0", "v2.3", "10.0.0"}, []string{"0.9.9", "1.1.0-rc.1"}},
+		{">= 1.0, <2", []string{"1.0.0", "1.9.9"}, []string{"2.0.0", "0.1.0"}},
+		{"^1.2", []string{"1.2.0", "1.9.0"}, []string{"1.1.9", "2.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"1.0.0 || >=3", []string{"1.0.0", "3.1.0"}, []string{"2.0.0"}},
+		{">=1.0.0-rc.1", []string{"1.0.0-rc.2", "1.0.0"}, []string{"1.0.0-beta.1"}},
+		{"!=1.0.0", []string{"1.0.1"}, []string{"1.0.0"}},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		require.NoError(t, err, tt.constraint)
+		for _, s := range tt.matches {
+			v, _ := Parse(s)
+			assert.True(t, c.Check(v), "%s matches %s", s, tt.constraint)
+		}
+		for _, s := range tt.mismatches {
+			v, _ := Parse(s)
+			assert.False(t, c.Check(v), "%s mismatches %s", s, tt.constraint)
+		}
+	}
+
+	for _, s := range []string{"", ">=", ">=latest", "1.0 ||"} {
+		_, err := ParseConstraint(s)
+		assert.Error(t, err, s)
+	}
+}
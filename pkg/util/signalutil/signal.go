@@ -0,0 +1,58 @@
+// Package signalutil cancels contexts on interrupt signals in two stages:
+// the first interrupt drains, which stops scheduling new work, and the second aborts the work in flight.
+package signalutil
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/coding-wepack/carctl/pkg/log"
+)
+
+type drainKey struct{}
+
+// WithInterrupt returns a context which is canceled on the second SIGINT or SIGTERM,
+// its Drain context is canceled on the first one. After the second signal,
+// the default behaviour is restored, so that a third one kills the process.
+// stop releases the resources and stops relaying signals.
+func WithInterrupt(parent context.Context) (ctx context.Context, stop func()) {
+	abortCtx, abort := context.WithCancel(parent)
+	drainCtx, drain := context.WithCancel(abortCtx)
+
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		defer signal.Stop(sigChan)
+		select {
+		case <-sigChan:
+			log.Warn("Interrupted, waiting for the in-flight items to finish, press Ctrl-C again to abort")
+			drain()
+		case <-done:
+			return
+		}
+		select {
+		case <-sigChan:
+			log.Warn("Aborted, canceling the in-flight items")
+			abort()
+		case <-done:
+		}
+	}()
+
+	return context.WithValue(abortCtx, drainKey{}, drainCtx), func() {
+		close(done)
+		drain()
+		abort()
+	}
+}
+
+// Drain returns the context which is canceled when ctx starts draining.
+// It is ctx itself if ctx is not created by WithInterrupt.
+func Drain(ctx context.Context) context.Context {
+	if drainCtx, ok := ctx.Value(drainKey{}).(context.Context); ok {
+		return drainCtx
+	}
+	return ctx
+}